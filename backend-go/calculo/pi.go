@@ -0,0 +1,524 @@
+package calculo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Metodo es la forma de aproximar π.
+type Metodo string
+
+const (
+	// Regla del punto medio sobre 4/(1+x²) en [0, 1]
+	MetodoPuntoMedio Metodo = "punto_medio"
+	// Regla del trapecio sobre la misma integral
+	MetodoTrapecio Metodo = "trapecio"
+	// Regla de Simpson (la cantidad de intervalos se redondea a par)
+	MetodoSimpson Metodo = "simpson"
+	// Puntos al azar en el cuadrado unidad; cada hilo tiene su generador
+	MetodoMonteCarlo Metodo = "montecarlo"
+	// Serie 4·(1 - 1/3 + 1/5 - ...), de convergencia muy lenta
+	MetodoLeibniz Metodo = "leibniz"
+	// Fórmula de Machin: 16·atan(1/5) - 4·atan(1/239)
+	MetodoMachin Metodo = "machin"
+	// Bailey–Borwein–Plouffe: un dígito hexadecimal por término
+	MetodoBBP Metodo = "bbp"
+)
+
+// Metodos en el orden en que se ofrecen.
+var Metodos = []Metodo{MetodoPuntoMedio, MetodoTrapecio, MetodoSimpson, MetodoMonteCarlo, MetodoLeibniz, MetodoMachin, MetodoBBP}
+
+// ParseMetodo acepta el nombre de un método; vacío es punto medio.
+func ParseMetodo(nombre string) (Metodo, error) {
+	if nombre == "" {
+		return MetodoPuntoMedio, nil
+	}
+	for _, m := range Metodos {
+		if string(m) == nombre {
+			return m, nil
+		}
+	}
+	return "", fmt.Errorf("método desconocido: %q (punto_medio, trapecio, simpson, montecarlo, leibniz, machin o bbp)", nombre)
+}
+
+// Reparto es cómo se dividen los términos entre hilos, como schedule(static)
+// y schedule(static, 1) en OpenMP.
+type Reparto string
+
+const (
+	// Un bloque contiguo por hilo
+	RepartoBloques Reparto = "bloques"
+	// El término j va al hilo j mód hilos
+	RepartoCiclico Reparto = "ciclico"
+)
+
+// ParseReparto acepta bloques o ciclico; vacío es bloques.
+func ParseReparto(nombre string) (Reparto, error) {
+	switch Reparto(nombre) {
+	case "", RepartoBloques:
+		return RepartoBloques, nil
+	case RepartoCiclico:
+		return RepartoCiclico, nil
+	}
+	return "", fmt.Errorf("reparto desconocido: %q (bloques o ciclico)", nombre)
+}
+
+// MaxDigitos es la precisión arbitraria máxima, en dígitos decimales.
+const MaxDigitos = 5000
+
+// OpcionesPi configura un cálculo.
+type OpcionesPi struct {
+	Metodo      Metodo
+	Iteraciones int // intervalos, términos o puntos según el método
+	Hilos       int
+	Reparto     Reparto
+	Kahan       bool  // suma compensada en las sumas parciales y al combinarlas
+	Digitos     int   // > 0 calcula con math/big a esa cantidad de dígitos
+	Semilla     int64 // Monte Carlo; 0 = al azar
+}
+
+// Hilo es lo que hizo cada goroutine: los términos Primero, Primero+Paso...
+type Hilo struct {
+	Hilo       int     `json:"hilo"`
+	Primero    int     `json:"primero"`
+	Paso       int     `json:"paso"`
+	Terminos   int     `json:"terminos"`
+	Parcial    float64 `json:"parcial"`
+	DuracionMs float64 `json:"duracion_ms"`
+}
+
+// ResultadoPi es la aproximación con su error y el tiempo de cada hilo.
+type ResultadoPi struct {
+	Metodo           Metodo  `json:"metodo"`
+	Pi               float64 `json:"pi"`
+	Texto            string  `json:"texto"` // con todos los dígitos calculados
+	Iteraciones      int     `json:"iteraciones"`
+	Terminos         int     `json:"terminos"`
+	Hilos            int     `json:"hilos"`
+	Reparto          Reparto `json:"reparto"`
+	Kahan            bool    `json:"kahan"`
+	Digitos          int     `json:"digitos,omitempty"`
+	Semilla          int64   `json:"semilla,omitempty"`
+	ErrorAbsoluto    float64 `json:"error_absoluto"`
+	ErrorPorcentual  float64 `json:"error"`
+	DigitosCorrectos int     `json:"digitos_correctos"`
+	DuracionMs       float64 `json:"duracion_ms"`
+	PorHilo          []Hilo  `json:"por_hilo"`
+	// Duración del hilo más lento sobre la media: 1 es un reparto perfecto
+	Desbalance float64 `json:"desbalance"`
+}
+
+// CalcularPi reparte los términos del método entre opciones.Hilos goroutines
+// y combina las sumas parciales.
+func CalcularPi(opciones OpcionesPi) (ResultadoPi, error) {
+	if opciones.Iteraciones < 1 || opciones.Hilos < 1 {
+		return ResultadoPi{}, errors.New("iteraciones e hilos deben ser positivos")
+	}
+	if opciones.Digitos < 0 || opciones.Digitos > MaxDigitos {
+		return ResultadoPi{}, fmt.Errorf("digitos debe estar entre 0 y %d", MaxDigitos)
+	}
+	if opciones.Digitos > 0 && opciones.Metodo == MetodoMonteCarlo {
+		return ResultadoPi{}, errors.New("Monte Carlo no admite precisión arbitraria")
+	}
+	if opciones.Reparto == "" {
+		opciones.Reparto = RepartoBloques
+	}
+	s, ok := series[opciones.Metodo]
+	if !ok && opciones.Metodo != MetodoMonteCarlo {
+		return ResultadoPi{}, fmt.Errorf("método desconocido: %q", opciones.Metodo)
+	}
+
+	r := ResultadoPi{
+		Metodo:      opciones.Metodo,
+		Iteraciones: opciones.Iteraciones,
+		Hilos:       opciones.Hilos,
+		Reparto:     opciones.Reparto,
+		Kahan:       opciones.Kahan,
+		Digitos:     opciones.Digitos,
+	}
+	inicio := time.Now()
+	var pi *big.Float
+	switch {
+	case opciones.Metodo == MetodoMonteCarlo:
+		if opciones.Semilla == 0 {
+			opciones.Semilla = time.Now().UnixNano()
+		}
+		r.Semilla = opciones.Semilla
+		pi = big.NewFloat(monteCarlo(&r, opciones))
+	case opciones.Digitos > 0:
+		pi = s.sumarGrande(&r, opciones, precisionDe(opciones.Digitos))
+	default:
+		pi = big.NewFloat(s.sumar(&r, opciones))
+	}
+	r.DuracionMs = milisegundos(time.Since(inicio))
+
+	r.Pi, _ = pi.Float64()
+	if opciones.Digitos > 0 {
+		r.Texto = pi.Text('f', opciones.Digitos)
+	} else {
+		r.Texto = strconv.FormatFloat(r.Pi, 'f', 15, 64)
+	}
+	r.ErrorAbsoluto, r.ErrorPorcentual, r.DigitosCorrectos = errorDe(pi, opciones.Digitos)
+	r.Desbalance = desbalance(r.PorHilo)
+	return r, nil
+}
+
+// -------------------- Series --------------------
+
+// serie describe un método como una suma de términos por un factor.
+type serie struct {
+	// terminos es la cantidad de términos para n iteraciones
+	terminos func(n int) int
+	// necesarios acota los términos con precisión arbitraria a los que
+	// todavía aportan a esa precisión (en bits); nil = sin cota
+	necesarios func(prec uint) int
+	termino    func(j, terminos int) float64
+	grande     func(j, terminos int, prec uint) *big.Float
+	// escala multiplica la suma total (el ancho del intervalo en las reglas)
+	escala func(terminos int) float64
+	// escalaGrande es escala con precisión arbitraria; nil = 1
+	escalaGrande func(terminos int, prec uint) *big.Float
+}
+
+// f es el integrando: ∫₀¹ 4/(1+x²) dx = π
+func f(x float64) float64 {
+	return 4 / (1 + x*x)
+}
+
+func fGrande(x *big.Float) *big.Float {
+	prec := x.Prec()
+	d := new(big.Float).SetPrec(prec).Mul(x, x)
+	d.Add(d, uno)
+	return d.Quo(new(big.Float).SetPrec(prec).SetInt64(4), d)
+}
+
+var uno = big.NewFloat(1)
+
+// cocienteGrande devuelve a/b con prec bits.
+func cocienteGrande(a, b int64, prec uint) *big.Float {
+	return new(big.Float).SetPrec(prec).Quo(new(big.Float).SetPrec(prec).SetInt64(a), new(big.Float).SetPrec(prec).SetInt64(b))
+}
+
+func sinEscala(int) float64 { return 1 }
+
+var series = map[Metodo]serie{
+	MetodoPuntoMedio: {
+		terminos: func(n int) int { return n },
+		termino:  func(j, n int) float64 { return f((float64(j) + 0.5) / float64(n)) },
+		grande: func(j, n int, prec uint) *big.Float {
+			return fGrande(cocienteGrande(2*int64(j)+1, 2*int64(n), prec))
+		},
+		escala:       func(n int) float64 { return 1 / float64(n) },
+		escalaGrande: func(n int, prec uint) *big.Float { return cocienteGrande(1, int64(n), prec) },
+	},
+	// Con n intervalos hay n+1 puntos; los extremos pesan la mitad
+	MetodoTrapecio: {
+		terminos: func(n int) int { return n + 1 },
+		termino: func(j, t int) float64 {
+			v := f(float64(j) / float64(t-1))
+			if j == 0 || j == t-1 {
+				v /= 2
+			}
+			return v
+		},
+		grande: func(j, t int, prec uint) *big.Float {
+			v := fGrande(cocienteGrande(int64(j), int64(t-1), prec))
+			if j == 0 || j == t-1 {
+				v.Quo(v, big.NewFloat(2))
+			}
+			return v
+		},
+		escala:       func(t int) float64 { return 1 / float64(t-1) },
+		escalaGrande: func(t int, prec uint) *big.Float { return cocienteGrande(1, int64(t-1), prec) },
+	},
+	// Pesos 1, 4, 2, 4, ..., 4, 1 sobre un número par de intervalos
+	MetodoSimpson: {
+		terminos: func(n int) int { return n + n%2 + 1 },
+		termino: func(j, t int) float64 {
+			return pesoSimpson(j, t) * f(float64(j)/float64(t-1))
+		},
+		grande: func(j, t int, prec uint) *big.Float {
+			v := fGrande(cocienteGrande(int64(j), int64(t-1), prec))
+			return v.Mul(v, big.NewFloat(pesoSimpson(j, t)))
+		},
+		escala:       func(t int) float64 { return 1 / (3 * float64(t-1)) },
+		escalaGrande: func(t int, prec uint) *big.Float { return cocienteGrande(1, 3*int64(t-1), prec) },
+	},
+	MetodoLeibniz: {
+		terminos: func(n int) int { return n },
+		termino: func(j, _ int) float64 {
+			return signo(j) * 4 / float64(2*j+1)
+		},
+		grande: func(j, _ int, prec uint) *big.Float {
+			return cocienteGrande(int64(signo(j))*4, 2*int64(j)+1, prec)
+		},
+		escala: sinEscala,
+	},
+	// Cada término suma lo que corresponde a atan(1/5) y atan(1/239)
+	MetodoMachin: {
+		terminos: func(n int) int { return n },
+		// Cada término de atan(1/5) aporta 2·log2(5) bits
+		necesarios: func(prec uint) int { return int(float64(prec)/(2*math.Log2(5))) + 2 },
+		termino: func(j, _ int) float64 {
+			k := float64(2*j + 1)
+			return signo(j) * (16/(k*math.Pow(5, k)) - 4/(k*math.Pow(239, k)))
+		},
+		grande: func(j, _ int, prec uint) *big.Float {
+			k := int64(2*j + 1)
+			a := arcotangenteTermino(5, k, prec)
+			a.Mul(a, big.NewFloat(16))
+			b := arcotangenteTermino(239, k, prec)
+			a.Sub(a, b.Mul(b, big.NewFloat(4)))
+			return a.Mul(a, big.NewFloat(signo(j)))
+		},
+		escala: sinEscala,
+	},
+	MetodoBBP: {
+		terminos: func(n int) int { return n },
+		// Cada término aporta un dígito hexadecimal
+		necesarios: func(prec uint) int { return int(prec)/4 + 2 },
+		termino: func(k, _ int) float64 {
+			c := float64(8 * k)
+			return math.Pow(16, -float64(k)) * (4/(c+1) - 2/(c+4) - 1/(c+5) - 1/(c+6))
+		},
+		grande: terminoBBP,
+		escala: sinEscala,
+	},
+}
+
+func pesoSimpson(j, t int) float64 {
+	switch {
+	case j == 0 || j == t-1:
+		return 1
+	case j%2 == 1:
+		return 4
+	}
+	return 2
+}
+
+func signo(j int) float64 {
+	if j%2 == 1 {
+		return -1
+	}
+	return 1
+}
+
+// arcotangenteTermino es 1/(k·x^k) con prec bits.
+func arcotangenteTermino(x, k int64, prec uint) *big.Float {
+	potencia := new(big.Int).Exp(big.NewInt(x), big.NewInt(k), nil)
+	d := new(big.Float).SetPrec(prec).SetInt(potencia)
+	d.Mul(d, new(big.Float).SetPrec(prec).SetInt64(k))
+	return d.Quo(new(big.Float).SetPrec(prec).SetInt64(1), d)
+}
+
+// terminoBBP es 16^-k·(4/(8k+1) - 2/(8k+4) - 1/(8k+5) - 1/(8k+6)).
+func terminoBBP(k, _ int, prec uint) *big.Float {
+	c := 8 * int64(k)
+	v := cocienteGrande(4, c+1, prec)
+	v.Sub(v, cocienteGrande(2, c+4, prec))
+	v.Sub(v, cocienteGrande(1, c+5, prec))
+	v.Sub(v, cocienteGrande(1, c+6, prec))
+	return v.SetMantExp(v, -4*k)
+}
+
+// -------------------- Reparto --------------------
+
+// repartir corre trabajo en una goroutine por hilo con los términos que le
+// tocan y mide cuánto tarda cada una.
+func repartir(r *ResultadoPi, opciones OpcionesPi, terminos int, trabajo func(h *Hilo)) {
+	r.Terminos = terminos
+	r.PorHilo = make([]Hilo, opciones.Hilos)
+	bloque, resto := terminos/opciones.Hilos, terminos%opciones.Hilos
+
+	var wg sync.WaitGroup
+	for i := range r.PorHilo {
+		h := &r.PorHilo[i]
+		h.Hilo = i
+		if opciones.Reparto == RepartoCiclico {
+			h.Primero, h.Paso = i, opciones.Hilos
+			h.Terminos = bloque
+			if i < resto {
+				h.Terminos++
+			}
+		} else {
+			// Los primeros resto hilos llevan un término más
+			h.Primero, h.Paso, h.Terminos = i*bloque+min(i, resto), 1, bloque
+			if i < resto {
+				h.Terminos++
+			}
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			inicio := time.Now()
+			trabajo(h)
+			h.DuracionMs = milisegundos(time.Since(inicio))
+		}()
+	}
+	wg.Wait()
+}
+
+func (s serie) sumar(r *ResultadoPi, opciones OpcionesPi) float64 {
+	terminos := s.terminos(opciones.Iteraciones)
+	repartir(r, opciones, terminos, func(h *Hilo) {
+		var suma kahan
+		for k, j := 0, h.Primero; k < h.Terminos; k, j = k+1, j+h.Paso {
+			suma.sumar(s.termino(j, terminos), opciones.Kahan)
+		}
+		h.Parcial = suma.valor()
+	})
+
+	var total kahan
+	for _, h := range r.PorHilo {
+		total.sumar(h.Parcial, opciones.Kahan)
+	}
+	return total.valor() * s.escala(terminos)
+}
+
+func (s serie) sumarGrande(r *ResultadoPi, opciones OpcionesPi, prec uint) *big.Float {
+	terminos := s.terminos(opciones.Iteraciones)
+	if s.necesarios != nil {
+		terminos = min(terminos, s.necesarios(prec))
+	}
+	parciales := make([]*big.Float, opciones.Hilos)
+	repartir(r, opciones, terminos, func(h *Hilo) {
+		suma := new(big.Float).SetPrec(prec)
+		for k, j := 0, h.Primero; k < h.Terminos; k, j = k+1, j+h.Paso {
+			suma.Add(suma, s.grande(j, terminos, prec))
+		}
+		parciales[h.Hilo] = suma
+		h.Parcial, _ = suma.Float64()
+	})
+
+	total := new(big.Float).SetPrec(prec)
+	for _, p := range parciales {
+		total.Add(total, p)
+	}
+	if s.escalaGrande != nil {
+		total.Mul(total, s.escalaGrande(terminos, prec))
+	}
+	return total
+}
+
+// monteCarlo cuenta los puntos dentro del cuarto de círculo. Cada hilo usa
+// su propio generador (semilla + hilo): uno compartido serializaría a todos
+// en su mutex.
+func monteCarlo(r *ResultadoPi, opciones OpcionesPi) float64 {
+	repartir(r, opciones, opciones.Iteraciones, func(h *Hilo) {
+		azar := rand.New(rand.NewSource(opciones.Semilla + int64(h.Hilo)))
+		dentro := 0
+		for k := 0; k < h.Terminos; k++ {
+			x, y := azar.Float64(), azar.Float64()
+			if x*x+y*y <= 1 {
+				dentro++
+			}
+		}
+		h.Parcial = float64(dentro)
+	})
+
+	dentro := 0.0
+	for _, h := range r.PorHilo {
+		dentro += h.Parcial
+	}
+	return 4 * dentro / float64(opciones.Iteraciones)
+}
+
+// kahan es una suma con compensación de Kahan: c guarda los bits de orden
+// bajo que se pierden en cada suma y se restan en la siguiente.
+type kahan struct {
+	suma, c float64
+}
+
+func (k *kahan) sumar(x float64, compensar bool) {
+	if !compensar {
+		k.suma += x
+		return
+	}
+	y := x - k.c
+	t := k.suma + y
+	k.c = (t - k.suma) - y
+	k.suma = t
+}
+
+func (k *kahan) valor() float64 {
+	return k.suma
+}
+
+// -------------------- Error --------------------
+
+// precisionDe son los bits de mantisa para digitos decimales, con margen
+// para el redondeo de las sumas.
+func precisionDe(digitos int) uint {
+	return uint(math.Ceil(float64(digitos)*math.Log2(10))) + 32
+}
+
+var (
+	referencias   = map[uint]*big.Float{}
+	referenciasMu sync.Mutex
+)
+
+// referenciaPi calcula π con BBP a prec bits (y lo guarda para la próxima).
+func referenciaPi(prec uint) *big.Float {
+	referenciasMu.Lock()
+	defer referenciasMu.Unlock()
+	if pi, ok := referencias[prec]; ok {
+		return pi
+	}
+	pi := new(big.Float).SetPrec(prec)
+	for k := 0; k < int(prec)/4+2; k++ {
+		pi.Add(pi, terminoBBP(k, 0, prec))
+	}
+	referencias[prec] = pi
+	return pi
+}
+
+// errorDe devuelve el error absoluto, el porcentual y los dígitos decimales
+// correctos de pi.
+func errorDe(pi *big.Float, digitos int) (float64, float64, int) {
+	prec := uint(64)
+	limite := 16 // lo que distingue un float64
+	if digitos > 0 {
+		prec, limite = precisionDe(digitos)+32, digitos
+	}
+	referencia := referenciaPi(prec)
+	diferencia := new(big.Float).SetPrec(prec).Sub(pi, referencia)
+	diferencia.Abs(diferencia)
+
+	absoluto, _ := diferencia.Float64()
+	if digitos == 0 {
+		// Con float64 el error se mide contra math.Pi, como lo vería el alumno
+		v, _ := pi.Float64()
+		absoluto = math.Abs(v - math.Pi)
+	}
+	porcentual := absoluto / math.Pi * 100
+
+	if diferencia.Sign() == 0 || absoluto == 0 {
+		return absoluto, porcentual, limite
+	}
+	// |error| < 2^exp, así que hay al menos -exp·log10(2) dígitos correctos
+	exp := diferencia.MantExp(nil)
+	correctos := int(math.Floor(-float64(exp) * math.Log10(2)))
+	return absoluto, porcentual, max(0, min(correctos, limite))
+}
+
+func desbalance(hilos []Hilo) float64 {
+	var suma, maximo float64
+	for _, h := range hilos {
+		suma += h.DuracionMs
+		maximo = math.Max(maximo, h.DuracionMs)
+	}
+	if suma == 0 {
+		return 1
+	}
+	return maximo / (suma / float64(len(hilos)))
+}
+
+func milisegundos(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"formula1-crud-go/config"
+	"formula1-crud-go/database"
+	"formula1-crud-go/handlers"
+	"formula1-crud-go/migraciones"
+	"formula1-crud-go/registro"
+	"formula1-crud-go/telemetria"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// -------------------- Subcomandos --------------------
+
+// ejecutarSubcomando atiende "formula1-crud <subcomando> [opciones]" sin
+// levantar el servidor.
+func ejecutarSubcomando(nombre string, args []string) error {
+	switch nombre {
+	case "generar-telemetria":
+		return generarTelemetria(args)
+	case "migrar":
+		return migrar(args)
+	case "config":
+		return comandoConfig(args)
+	case "token-admin":
+		return tokenAdmin(args)
+	default:
+		return fmt.Errorf("subcomando desconocido %q (disponibles: generar-telemetria, migrar, config, token-admin)", nombre)
+	}
+}
+
+// tomarArgumento separa el primer argumento de un subcomando (una acción,
+// una cantidad, ...) de las banderas de configuración que le siguen.
+func tomarArgumento(args []string) (string, []string, bool) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return "", args, false
+	}
+	return args[0], args[1:], true
+}
+
+var errUsoConfig = errors.New("uso: config print [-config archivo] [banderas del servidor]")
+
+// comandoConfig muestra la configuración efectiva, con el origen de cada
+// valor que no es el por defecto y los secretos ocultos. Acepta las mismas
+// banderas que el servidor, para ver cómo quedaría con ellas.
+func comandoConfig(args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return errUsoConfig
+	}
+	cfg, err := config.Cargar("config print", args[1:])
+	if err != nil {
+		return err
+	}
+	return cfg.Imprimir(os.Stdout)
+}
+
+var errUsoTokenAdmin = errors.New("uso: token-admin [duración, como 1h o 30m] [-config archivo] [banderas del servidor]")
+
+// tokenAdmin imprime un token de administrador para /debug/pprof y
+// /api/runtime, firmado con la clave auth.secreto_jwt de la configuración.
+// Vence en una hora salvo que se pida otra duración.
+func tokenAdmin(args []string) error {
+	duracion := time.Hour
+	if texto, resto, ok := tomarArgumento(args); ok {
+		var err error
+		if duracion, err = time.ParseDuration(texto); err != nil || duracion <= 0 {
+			return errUsoTokenAdmin
+		}
+		args = resto
+	}
+	cfg, err := config.Cargar("token-admin", args)
+	if err != nil {
+		return err
+	}
+	token, err := handlers.NuevoTokenAdmin(cfg.Auth.SecretoJWT, duracion)
+	if err != nil {
+		return err
+	}
+	fmt.Println(token)
+	return nil
+}
+
+// generarTelemetria escribe un archivo de telemetría sintética, por defecto
+// en la salida estándar. La semilla usada se informa en stderr.
+func generarTelemetria(args []string) error {
+	banderas := flag.NewFlagSet("generar-telemetria", flag.ContinueOnError)
+	var opciones telemetria.OpcionesGenerador
+	banderas.StringVar(&opciones.Trazado, "trazado", "monza", "circuito: monaco, monza o silverstone")
+	banderas.StringVar(&opciones.Perfil, "perfil", "f1", "auto: f1, f2 o gt3")
+	banderas.IntVar(&opciones.Vueltas, "vueltas", 3, "vueltas a generar")
+	banderas.Float64Var(&opciones.Frecuencia, "frecuencia", 10, "muestras por segundo")
+	banderas.Float64Var(&opciones.Ruido, "ruido", 1, "ruido de sensor (0 = sin ruido)")
+	banderas.Int64Var(&opciones.Semilla, "semilla", time.Now().UnixNano(), "semilla para reproducir el mismo archivo")
+	nombreFormato := banderas.String("formato", "", "csv o jsonl (por defecto, según la extensión de -salida)")
+	salida := banderas.String("salida", "", "archivo de salida (por defecto, la salida estándar)")
+	if err := banderas.Parse(args); err != nil {
+		return err
+	}
+
+	formato := telemetria.DetectarFormato(*salida)
+	if *nombreFormato != "" {
+		var err error
+		if formato, err = telemetria.ParseFormato(*nombreFormato); err != nil {
+			return err
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if *salida != "" {
+		archivo, err := os.Create(*salida)
+		if err != nil {
+			return err
+		}
+		defer archivo.Close()
+		w = archivo
+	}
+
+	escritor, err := telemetria.NuevoEscritor(w, formato)
+	if err != nil {
+		return err
+	}
+	muestras := 0
+	err = telemetria.Generar(opciones, func(m telemetria.Muestra) error {
+		muestras++
+		return escritor.Escribir(m)
+	})
+	if err != nil {
+		return err
+	}
+	if err := escritor.Cerrar(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%d muestras (%s, %s, %d vueltas, semilla %d)\n", muestras, opciones.Trazado, opciones.Perfil, opciones.Vueltas, opciones.Semilla)
+	return nil
+}
+
+var errUsoMigrar = errors.New("uso: migrar subir | bajar [n] | ir-a <versión> | estado [-config archivo] [banderas del servidor]")
+
+// migrar aplica, revierte o lista las migraciones de la base configurada
+// como la del servidor: archivo (-config o CONFIG_ARCHIVO), variables DB_*
+// y banderas, después de la acción y su argumento.
+func migrar(args []string) error {
+	accion, args, ok := tomarArgumento(args)
+	if !ok {
+		return errUsoMigrar
+	}
+	var (
+		n       = 1
+		version int64
+		err     error
+	)
+	switch accion {
+	case "subir", "estado":
+	case "bajar":
+		if texto, resto, ok := tomarArgumento(args); ok {
+			if n, err = strconv.Atoi(texto); err != nil || n < 1 {
+				return fmt.Errorf("bajar: la cantidad debe ser un entero positivo")
+			}
+			args = resto
+		}
+	case "ir-a":
+		texto, resto, ok := tomarArgumento(args)
+		if !ok {
+			return errUsoMigrar
+		}
+		if version, err = strconv.ParseInt(texto, 10, 64); err != nil {
+			return fmt.Errorf("ir-a: versión inválida %q", texto)
+		}
+		args = resto
+	default:
+		return errUsoMigrar
+	}
+
+	cfg, err := config.Cargar("migrar "+accion, args)
+	if err != nil {
+		return err
+	}
+	registro.Configurar(cfg.Registro.Opciones())
+	db, err := database.Abrir(cfg.BaseDeDatos)
+	if err != nil {
+		return err
+	}
+	migrador, err := database.NuevoMigrador(db)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	var pasos []migraciones.Paso
+	switch accion {
+	case "subir":
+		pasos, err = migrador.Subir(ctx)
+	case "bajar":
+		pasos, err = migrador.Bajar(ctx, n)
+	case "ir-a":
+		pasos, err = migrador.IrA(ctx, version)
+	case "estado":
+		return imprimirEstado(ctx, migrador)
+	}
+	if err != nil {
+		return err
+	}
+	if len(pasos) == 0 {
+		fmt.Fprintln(os.Stderr, "Nada que migrar")
+	}
+	return nil
+}
+
+func imprimirEstado(ctx context.Context, migrador *migraciones.Migrador) error {
+	estados, err := migrador.Estado(ctx)
+	if err != nil {
+		return err
+	}
+	tabla := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tabla, "VERSIÓN\tNOMBRE\tESTADO\tAPLICADA")
+	for _, e := range estados {
+		estado, cuando := "pendiente", "-"
+		if e.Aplicada {
+			estado, cuando = "aplicada", e.AplicadaEn.Local().Format("2006-01-02 15:04:05")
+		}
+		if e.Desconocida {
+			estado = "desconocida"
+		}
+		fmt.Fprintf(tabla, "%04d\t%s\t%s\t%s\n", e.Version, e.Nombre, estado, cuando)
+	}
+	return tabla.Flush()
+}
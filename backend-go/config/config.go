@@ -0,0 +1,412 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"formula1-crud-go/registro"
+	"formula1-crud-go/simulacion"
+	"formula1-crud-go/trazas"
+	"log/slog"
+	"net"
+	"net/url"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Config es toda la configuración del backend. Cada campo se puede fijar,
+// de menor a mayor prioridad, con su valor por defecto, el archivo YAML o
+// TOML, la variable de entorno de su etiqueta env (también desde .env) y la
+// bandera -<sección>.<campo>.
+type Config struct {
+	Servidor    Servidor    `yaml:"servidor"`
+	BaseDeDatos BaseDeDatos `yaml:"base_de_datos"`
+	Simulacion  Simulacion  `yaml:"simulacion"`
+	WebSocket   WebSocket   `yaml:"websocket"`
+	Auth        Auth        `yaml:"auth"`
+	CORS        CORS        `yaml:"cors"`
+	Registro    Registro    `yaml:"registro"`
+	Trazas      Trazas      `yaml:"trazas"`
+}
+
+type Servidor struct {
+	Puerto        int           `yaml:"puerto" env:"PORT" desc:"Puerto HTTP"`
+	EsperaApagado time.Duration `yaml:"espera_apagado" env:"APAGADO_ESPERA" desc:"Tiempo máximo del apagado ordenado"`
+	// ProxiesConfiables son los únicos a los que se les cree X-Forwarded-For
+	// y X-Real-IP; sin ninguno, la IP del cliente es la de la conexión. La
+	// IP identifica al cliente en los límites de simulaciones por cliente.
+	ProxiesConfiables []string `yaml:"proxies_confiables" env:"PROXIES_CONFIABLES" desc:"IPs o redes CIDR de los proxies cuya cabecera X-Forwarded-For se acepta, separadas por comas (vacío = ninguno)"`
+}
+
+type BaseDeDatos struct {
+	Driver          string        `yaml:"driver" env:"DB_DRIVER" desc:"Motor: postgres o sqlite"`
+	Ruta            string        `yaml:"ruta" env:"DB_PATH" desc:"Archivo de la base con driver sqlite"`
+	Host            string        `yaml:"host" env:"DB_HOST" desc:"Host de PostgreSQL"`
+	Puerto          int           `yaml:"puerto" env:"DB_PORT" desc:"Puerto de PostgreSQL"`
+	Usuario         string        `yaml:"usuario" env:"DB_USER" desc:"Usuario de PostgreSQL"`
+	Contrasena      Secreto       `yaml:"contrasena" env:"DB_PASSWORD" desc:"Contraseña de PostgreSQL"`
+	Nombre          string        `yaml:"nombre" env:"DB_NAME" desc:"Nombre de la base de PostgreSQL"`
+	SSLMode         string        `yaml:"sslmode" env:"DB_SSLMODE" desc:"Modo SSL de PostgreSQL"`
+	TimeoutConexion time.Duration `yaml:"timeout_conexion" env:"DB_TIMEOUT_CONEXION" desc:"Tiempo máximo para abrir una conexión a PostgreSQL"`
+	MigrarAlIniciar bool          `yaml:"migrar_al_iniciar" env:"MIGRAR_AL_INICIAR" desc:"Aplicar las migraciones pendientes al iniciar"`
+	Reintentos      int           `yaml:"reintentos" env:"DB_REINTENTOS" desc:"Intentos de conexión al iniciar (0 = sin límite)"`
+	EsperaInicial   time.Duration `yaml:"espera_inicial" env:"DB_ESPERA_INICIAL" desc:"Espera tras el primer intento fallido; se duplica en cada uno"`
+	EsperaMaxima    time.Duration `yaml:"espera_maxima" env:"DB_ESPERA_MAXIMA" desc:"Tope de la espera entre intentos"`
+	MaxConexiones   int           `yaml:"max_conexiones" env:"DB_MAX_CONEXIONES" desc:"Conexiones abiertas como máximo (0 = sin límite)"`
+	MaxInactivas    int           `yaml:"max_inactivas" env:"DB_MAX_INACTIVAS" desc:"Conexiones inactivas que se conservan"`
+	VidaConexion    time.Duration `yaml:"vida_conexion" env:"DB_VIDA_CONEXION" desc:"Vida máxima de una conexión"`
+	MaxInactividad  time.Duration `yaml:"max_inactividad" env:"DB_MAX_INACTIVIDAD" desc:"Tiempo máximo que una conexión puede quedar inactiva"`
+	PeriodoSalud    time.Duration `yaml:"periodo_salud" env:"DB_PERIODO_SALUD" desc:"Cada cuánto se comprueba que la base responde"`
+	ConsultaLenta   time.Duration `yaml:"consulta_lenta" env:"DB_CONSULTA_LENTA" desc:"Las consultas más lentas se registran como warn; el resto solo con nivel_db debug"`
+	SQLConValores   bool          `yaml:"sql_con_valores" env:"DB_SQL_CON_VALORES" desc:"Registrar el SQL con los valores de los parámetros en lugar de $1, ? (expone datos en los logs)"`
+}
+
+type Simulacion struct {
+	Trabajadores  int           `yaml:"trabajadores" env:"SIM_TRABAJADORES" desc:"Simulaciones corriendo a la vez en todo el servidor"`
+	MaxEnCola     int           `yaml:"max_en_cola" env:"SIM_MAX_EN_COLA" desc:"Simulaciones que pueden esperar un trabajador"`
+	MaxPorCliente int           `yaml:"max_por_cliente" env:"SIM_MAX_POR_CLIENTE" desc:"Simulaciones en cola o corriendo por cliente (IP)"`
+	MaxAutos      int           `yaml:"max_autos" env:"SIM_MAX_AUTOS" desc:"Máximo de autos por simulación OpenMP"`
+	MaxSectores   int           `yaml:"max_sectores" env:"SIM_MAX_SECTORES" desc:"Máximo de sectores por simulación MPI"`
+	MaxVueltas    int           `yaml:"max_vueltas" env:"SIM_MAX_VUELTAS" desc:"Máximo de vueltas por simulación"`
+	PasoMPI       time.Duration `yaml:"paso_mpi" env:"SIM_PASO_MPI" desc:"Pausa entre sectores de la simulación MPI"`
+	PasoOpenMP    time.Duration `yaml:"paso_openmp" env:"SIM_PASO_OPENMP" desc:"Pausa entre vueltas de cada auto en la simulación OpenMP"`
+}
+
+type WebSocket struct {
+	PeriodoPing      time.Duration               `yaml:"periodo_ping" env:"WS_PERIODO_PING" desc:"Cada cuánto se envía un ping al cliente"`
+	EsperaPong       time.Duration               `yaml:"espera_pong" env:"WS_ESPERA_PONG" desc:"Sin pong ni mensajes en este tiempo, se cierra la conexión"`
+	EsperaEscritura  time.Duration               `yaml:"espera_escritura" env:"WS_ESPERA_ESCRITURA" desc:"Tiempo máximo para escribir un mensaje"`
+	MaxTamanoMensaje int64                       `yaml:"max_tamano_mensaje" env:"WS_MAX_TAMANO_MENSAJE" desc:"Tamaño máximo (bytes) de un mensaje del cliente"`
+	PoliticaDesborde simulacion.PoliticaDesborde `yaml:"politica_desborde" env:"WS_POLITICA_DESBORDE" desc:"Con un cliente lento: desconectar, descartar_antiguos o coalescer"`
+	GraciaReconexion time.Duration               `yaml:"gracia_reconexion" env:"WS_GRACIA_RECONEXION" desc:"Tiempo para retomar una simulación tras desconectarse"`
+}
+
+type Auth struct {
+	SecretoJWT Secreto `yaml:"secreto_jwt" env:"JWT_SECRETO" desc:"Clave HMAC de los tokens JWT (vacía = rutas con token deshabilitadas)"`
+}
+
+type CORS struct {
+	Origenes []string `yaml:"origenes" env:"CORS_ORIGENES" desc:"Orígenes que pueden llamar a la API desde otro sitio, separados por comas (* = cualquiera)"`
+}
+
+// Registro elige el formato de los logs y el nivel de cada subsistema; un
+// nivel vacío toma el general.
+type Registro struct {
+	Formato   string `yaml:"formato" env:"LOG_FORMATO" desc:"json o texto"`
+	Nivel     string `yaml:"nivel" env:"LOG_NIVEL" desc:"Nivel general: debug, info, warn o error"`
+	NivelHTTP string `yaml:"nivel_http" env:"LOG_NIVEL_HTTP" desc:"Nivel de los pedidos HTTP"`
+	NivelDB   string `yaml:"nivel_db" env:"LOG_NIVEL_DB" desc:"Nivel de la base de datos (debug registra cada consulta)"`
+	NivelWS   string `yaml:"nivel_ws" env:"LOG_NIVEL_WS" desc:"Nivel de las conexiones WebSocket y SSE"`
+	NivelSim  string `yaml:"nivel_sim" env:"LOG_NIVEL_SIM" desc:"Nivel de las simulaciones"`
+}
+
+// Trazas elige adónde van las trazas OpenTelemetry. Las variables
+// OTEL_* son las estándar, las mismas que leen otros servicios.
+type Trazas struct {
+	Exportador string  `yaml:"exportador" env:"TRAZAS_EXPORTADOR" desc:"ninguno, otlp o archivo"`
+	Endpoint   string  `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" desc:"URL del colector OTLP/HTTP"`
+	Archivo    string  `yaml:"archivo" env:"TRAZAS_ARCHIVO" desc:"Archivo de las trazas con exportador archivo (un span JSON por línea)"`
+	Muestreo   float64 `yaml:"muestreo" env:"TRAZAS_MUESTREO" desc:"Fracción de las trazas nuevas que se registran, de 0 a 1"`
+	Servicio   string  `yaml:"servicio" env:"OTEL_SERVICE_NAME" desc:"Nombre del servicio en las trazas"`
+}
+
+// PorDefecto devuelve la configuración sin archivo, entorno ni banderas:
+// la del docker-compose, salvo el host de la base y los secretos, que van
+// solo en docker-compose.yml y .env.
+func PorDefecto() *Config {
+	return &Config{
+		Servidor: Servidor{
+			Puerto:        8080,
+			EsperaApagado: 20 * time.Second,
+		},
+		BaseDeDatos: BaseDeDatos{
+			Driver:          "postgres",
+			Ruta:            "formula1.db",
+			Host:            "localhost",
+			Puerto:          5432,
+			Usuario:         "formula1_user",
+			Nombre:          "formula1_db",
+			SSLMode:         "disable",
+			TimeoutConexion: 5 * time.Second,
+			MigrarAlIniciar: true,
+			Reintentos:      10,
+			EsperaInicial:   500 * time.Millisecond,
+			EsperaMaxima:    30 * time.Second,
+			MaxConexiones:   25,
+			MaxInactivas:    10,
+			VidaConexion:    30 * time.Minute,
+			MaxInactividad:  5 * time.Minute,
+			PeriodoSalud:    5 * time.Second,
+			ConsultaLenta:   200 * time.Millisecond,
+		},
+		// Valores pensados para una demo en clase
+		Simulacion: Simulacion{
+			Trabajadores:  runtime.NumCPU(),
+			MaxEnCola:     50,
+			MaxPorCliente: 2,
+			MaxAutos:      32,
+			MaxSectores:   20,
+			MaxVueltas:    20,
+			PasoMPI:       300 * time.Millisecond,
+			PasoOpenMP:    200 * time.Millisecond,
+		},
+		WebSocket: WebSocket{
+			PeriodoPing:      25 * time.Second,
+			EsperaPong:       60 * time.Second,
+			EsperaEscritura:  10 * time.Second,
+			MaxTamanoMensaje: 8 * 1024,
+			PoliticaDesborde: simulacion.DesbordeDescartarAntiguos,
+			GraciaReconexion: 30 * time.Second,
+		},
+		Registro: Registro{
+			Formato: "json",
+			Nivel:   "info",
+		},
+		Trazas: Trazas{
+			Exportador: trazas.Ninguno,
+			Endpoint:   "http://localhost:4318",
+			Archivo:    "trazas.jsonl",
+			Muestreo:   1,
+			Servicio:   "formula1-crud-go",
+		},
+	}
+}
+
+// Limites son los límites del planificador de simulaciones.
+func (s Simulacion) Limites() simulacion.Limites {
+	return simulacion.Limites{
+		Trabajadores:  s.Trabajadores,
+		MaxEnCola:     s.MaxEnCola,
+		MaxPorCliente: s.MaxPorCliente,
+		MaxAutos:      s.MaxAutos,
+		MaxSectores:   s.MaxSectores,
+		MaxVueltas:    s.MaxVueltas,
+	}
+}
+
+// Ritmo son las pausas de las simulaciones.
+func (s Simulacion) Ritmo() simulacion.Ritmo {
+	return simulacion.Ritmo{PasoMPI: s.PasoMPI, PasoOpenMP: s.PasoOpenMP}
+}
+
+// Opciones son las opciones de los logs. Los niveles ya se validaron.
+func (r Registro) Opciones() registro.Opciones {
+	o := registro.Opciones{Texto: r.Formato == "texto", Niveles: map[registro.Subsistema]slog.Level{}}
+	o.Nivel, _ = parseNivel(r.Nivel)
+	for subsistema, nivel := range r.porSubsistema() {
+		if nivel != "" {
+			o.Niveles[subsistema], _ = parseNivel(nivel)
+		}
+	}
+	return o
+}
+
+func (r Registro) porSubsistema() map[registro.Subsistema]string {
+	return map[registro.Subsistema]string{
+		registro.HTTP: r.NivelHTTP,
+		registro.DB:   r.NivelDB,
+		registro.WS:   r.NivelWS,
+		registro.Sim:  r.NivelSim,
+	}
+}
+
+// Opciones son las opciones de las trazas.
+func (t Trazas) Opciones() trazas.Opciones {
+	return trazas.Opciones{
+		Exportador: t.Exportador,
+		Endpoint:   t.Endpoint,
+		Archivo:    t.Archivo,
+		Muestreo:   t.Muestreo,
+		Servicio:   t.Servicio,
+	}
+}
+
+func parseNivel(texto string) (slog.Level, error) {
+	var nivel slog.Level
+	err := nivel.UnmarshalText([]byte(texto))
+	return nivel, err
+}
+
+// -------------------- Validación --------------------
+
+// ErrorValidacion junta todos los problemas de la configuración, para
+// corregirlos de una vez en lugar de uno por arranque.
+type ErrorValidacion []string
+
+func (e ErrorValidacion) Error() string {
+	return "configuración inválida:\n  - " + strings.Join(e, "\n  - ")
+}
+
+// Validar comprueba rangos y combinaciones de valores.
+func (c *Config) Validar() error {
+	var e ErrorValidacion
+	fallo := func(campo, formato string, args ...any) {
+		e = append(e, campo+": "+fmt.Sprintf(formato, args...))
+	}
+	positiva := func(campo string, d time.Duration) {
+		if d <= 0 {
+			fallo(campo, "debe ser una duración positiva, como 5s o 500ms (es %s)", d)
+		}
+	}
+	minimo := func(campo string, valor, min int) {
+		if valor < min {
+			fallo(campo, "debe ser al menos %d (es %d)", min, valor)
+		}
+	}
+
+	if c.Servidor.Puerto < 1 || c.Servidor.Puerto > 65535 {
+		fallo("servidor.puerto", "debe estar entre 1 y 65535 (es %d)", c.Servidor.Puerto)
+	}
+	positiva("servidor.espera_apagado", c.Servidor.EsperaApagado)
+	for _, proxy := range c.Servidor.ProxiesConfiables {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			fallo("servidor.proxies_confiables", "%q no es una IP ni una red CIDR como 10.0.0.0/8", proxy)
+		}
+	}
+
+	db := c.BaseDeDatos
+	switch db.Driver {
+	case "postgres":
+		if db.Host == "" {
+			fallo("base_de_datos.host", "no puede estar vacío con driver postgres")
+		}
+		if db.Puerto < 1 || db.Puerto > 65535 {
+			fallo("base_de_datos.puerto", "debe estar entre 1 y 65535 (es %d)", db.Puerto)
+		}
+		if db.Usuario == "" || db.Nombre == "" {
+			fallo("base_de_datos", "usuario y nombre no pueden estar vacíos con driver postgres")
+		}
+		positiva("base_de_datos.timeout_conexion", db.TimeoutConexion)
+	case "sqlite":
+		if db.Ruta == "" {
+			fallo("base_de_datos.ruta", "no puede estar vacía con driver sqlite")
+		}
+	default:
+		fallo("base_de_datos.driver", "debe ser postgres o sqlite (es %q)", db.Driver)
+	}
+	minimo("base_de_datos.reintentos", db.Reintentos, 0)
+	positiva("base_de_datos.espera_inicial", db.EsperaInicial)
+	positiva("base_de_datos.espera_maxima", db.EsperaMaxima)
+	if db.EsperaMaxima < db.EsperaInicial {
+		fallo("base_de_datos.espera_maxima", "no puede ser menor que espera_inicial (%s < %s)", db.EsperaMaxima, db.EsperaInicial)
+	}
+	minimo("base_de_datos.max_conexiones", db.MaxConexiones, 0)
+	minimo("base_de_datos.max_inactivas", db.MaxInactivas, 0)
+	positiva("base_de_datos.vida_conexion", db.VidaConexion)
+	positiva("base_de_datos.max_inactividad", db.MaxInactividad)
+	positiva("base_de_datos.periodo_salud", db.PeriodoSalud)
+	positiva("base_de_datos.consulta_lenta", db.ConsultaLenta)
+
+	sim := c.Simulacion
+	minimo("simulacion.trabajadores", sim.Trabajadores, 1)
+	minimo("simulacion.max_en_cola", sim.MaxEnCola, 1)
+	minimo("simulacion.max_por_cliente", sim.MaxPorCliente, 1)
+	minimo("simulacion.max_autos", sim.MaxAutos, 1)
+	minimo("simulacion.max_sectores", sim.MaxSectores, 1)
+	minimo("simulacion.max_vueltas", sim.MaxVueltas, 1)
+	positiva("simulacion.paso_mpi", sim.PasoMPI)
+	positiva("simulacion.paso_openmp", sim.PasoOpenMP)
+
+	ws := c.WebSocket
+	positiva("websocket.periodo_ping", ws.PeriodoPing)
+	positiva("websocket.espera_pong", ws.EsperaPong)
+	positiva("websocket.espera_escritura", ws.EsperaEscritura)
+	if ws.PeriodoPing >= ws.EsperaPong {
+		fallo("websocket.periodo_ping", "debe ser menor que espera_pong (%s >= %s), o los clientes se desconectarían entre pings", ws.PeriodoPing, ws.EsperaPong)
+	}
+	if ws.MaxTamanoMensaje < 1 {
+		fallo("websocket.max_tamano_mensaje", "debe ser al menos 1 (es %d)", ws.MaxTamanoMensaje)
+	}
+	if _, err := simulacion.ParsePoliticaDesborde(string(ws.PoliticaDesborde)); err != nil {
+		fallo("websocket.politica_desborde", "%v (opciones: desconectar, descartar_antiguos, coalescer)", err)
+	}
+	if ws.GraciaReconexion < 0 {
+		fallo("websocket.gracia_reconexion", "no puede ser negativa (es %s)", ws.GraciaReconexion)
+	}
+
+	if n := len(c.Auth.SecretoJWT.Valor()); n > 0 && n < 32 {
+		fallo("auth.secreto_jwt", "debe tener al menos 32 caracteres (tiene %d)", n)
+	}
+
+	for _, origen := range c.CORS.Origenes {
+		if origen == "*" {
+			continue
+		}
+		if u, err := url.Parse(origen); err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			fallo("cors.origenes", "%q no es un origen como https://ejemplo.com o *", origen)
+		}
+	}
+
+	if c.Registro.Formato != "json" && c.Registro.Formato != "texto" {
+		fallo("registro.formato", "debe ser json o texto (es %q)", c.Registro.Formato)
+	}
+	if _, err := parseNivel(c.Registro.Nivel); err != nil {
+		fallo("registro.nivel", "%q no es un nivel (debug, info, warn o error)", c.Registro.Nivel)
+	}
+	niveles := c.Registro.porSubsistema()
+	for _, subsistema := range []registro.Subsistema{registro.HTTP, registro.DB, registro.WS, registro.Sim} {
+		nivel := niveles[subsistema]
+		if _, err := parseNivel(nivel); nivel != "" && err != nil {
+			fallo("registro.nivel_"+string(subsistema), "%q no es un nivel (debug, info, warn o error)", nivel)
+		}
+	}
+
+	tr := c.Trazas
+	switch tr.Exportador {
+	case trazas.Ninguno:
+	case trazas.OTLP:
+		if u, err := url.Parse(tr.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fallo("trazas.endpoint", "%q no es una URL como http://localhost:4318", tr.Endpoint)
+		}
+	case trazas.Archivo:
+		if tr.Archivo == "" {
+			fallo("trazas.archivo", "no puede estar vacío con exportador archivo")
+		}
+	default:
+		fallo("trazas.exportador", "debe ser ninguno, otlp o archivo (es %q)", tr.Exportador)
+	}
+	if tr.Muestreo < 0 || tr.Muestreo > 1 {
+		fallo("trazas.muestreo", "debe estar entre 0 y 1 (es %g)", tr.Muestreo)
+	}
+	if tr.Servicio == "" {
+		fallo("trazas.servicio", "no puede estar vacío")
+	}
+
+	if len(e) > 0 {
+		return e
+	}
+	return nil
+}
+
+// -------------------- Secretos --------------------
+
+// Secreto es un texto que no debe aparecer en logs ni en "config print":
+// al formatearlo o serializarlo se muestra oculto. El valor real se pide
+// explícitamente con Valor.
+type Secreto string
+
+const textoOculto = "[oculto]"
+
+func (s Secreto) Valor() string { return string(s) }
+
+func (s Secreto) String() string {
+	if s == "" {
+		return ""
+	}
+	return textoOculto
+}
+
+func (s Secreto) GoString() string { return `config.Secreto("` + s.String() + `")` }
+
+func (s Secreto) MarshalText() ([]byte, error) { return []byte(s.String()), nil }
+
+// UnmarshalText rechaza el texto que muestra un secreto oculto, para que
+// una salida vieja de "config print" usada como archivo no deje a
+// "[oculto]" como contraseña.
+func (s *Secreto) UnmarshalText(texto []byte) error {
+	if string(texto) == textoOculto {
+		return errors.New(textoOculto + " es como se muestra un secreto, no su valor")
+	}
+	*s = Secreto(texto)
+	return nil
+}
@@ -0,0 +1,301 @@
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	toml "github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// VariableArchivo es la variable de entorno con la ruta del archivo de
+// configuración cuando no se pasa -config.
+const VariableArchivo = "CONFIG_ARCHIVO"
+
+// Origen de cada valor, para "config print"
+const (
+	OrigenArchivo = "archivo"
+	OrigenEntorno = "entorno"
+	OrigenBandera = "bandera"
+)
+
+// Cargada es una configuración válida junto con el origen de cada campo
+// que no tiene su valor por defecto.
+type Cargada struct {
+	*Config
+	Archivo string
+	// Origenes va de la ruta del campo (base_de_datos.host) a su origen,
+	// por ejemplo "entorno (DB_HOST)"
+	Origenes map[string]string
+}
+
+// Cargar arma la configuración con los valores por defecto, el archivo
+// (-config o CONFIG_ARCHIVO, .yaml/.yml o .toml), el entorno (incluido el
+// .env del directorio actual, que no pisa variables ya definidas) y las
+// banderas de args, en ese orden de prioridad creciente, y la valida.
+// Con -h devuelve flag.ErrHelp después de mostrar la ayuda.
+func Cargar(nombre string, args []string) (*Cargada, error) {
+	cfg := PorDefecto()
+	cargada := &Cargada{Config: cfg, Origenes: map[string]string{}}
+
+	banderas, valores := nuevasBanderas(nombre, cfg)
+	archivo := banderas.String("config", "", "archivo de configuración YAML o TOML (o la variable "+VariableArchivo+")")
+	if err := banderas.Parse(args); err != nil {
+		return nil, err
+	}
+	if banderas.NArg() > 0 {
+		return nil, fmt.Errorf("argumento inesperado %q (las banderas van como -seccion.campo valor)", banderas.Arg(0))
+	}
+
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf(".env: %w", err)
+	}
+
+	cargada.Archivo = *archivo
+	if cargada.Archivo == "" {
+		cargada.Archivo = os.Getenv(VariableArchivo)
+	}
+	if cargada.Archivo != "" {
+		if err := leerArchivo(cargada, cargada.Archivo); err != nil {
+			return nil, fmt.Errorf("archivo de configuración %s: %w", cargada.Archivo, err)
+		}
+	}
+
+	var errores ErrorValidacion
+	recorrer(cfg, func(ruta string, campo reflect.Value, sf reflect.StructField) {
+		variable := sf.Tag.Get("env")
+		texto, ok := os.LookupEnv(variable)
+		if variable == "" || !ok || texto == "" {
+			return
+		}
+		if err := asignar(campo, texto); err != nil {
+			errores = append(errores, fmt.Sprintf("variable %s (%s): %v", variable, ruta, err))
+			return
+		}
+		cargada.Origenes[ruta] = OrigenEntorno + " (" + variable + ")"
+	})
+	recorrer(cfg, func(ruta string, campo reflect.Value, _ reflect.StructField) {
+		texto, ok := valores[ruta]
+		if !ok {
+			return
+		}
+		if err := asignar(campo, texto); err != nil {
+			errores = append(errores, fmt.Sprintf("bandera -%s: %v", ruta, err))
+			return
+		}
+		cargada.Origenes[ruta] = OrigenBandera
+	})
+	// Los valores que no se pudieron leer quedan con el anterior; se
+	// informan junto con el resto de los problemas
+	var invalida ErrorValidacion
+	if errors.As(cfg.Validar(), &invalida) {
+		errores = append(errores, invalida...)
+	}
+	if len(errores) > 0 {
+		return nil, errores
+	}
+	return cargada, nil
+}
+
+// -------------------- Archivo --------------------
+
+// leerArchivo aplica un archivo YAML o TOML. El TOML se pasa al mismo árbol
+// que el YAML para que ambos se decodifiquen con las mismas reglas: las
+// duraciones se escriben como "5s" y una clave desconocida es un error.
+func leerArchivo(cargada *Cargada, ruta string) error {
+	datos, err := os.ReadFile(ruta)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(ruta)) {
+	case ".yaml", ".yml":
+	case ".toml":
+		var arbol map[string]any
+		if err := toml.Unmarshal(datos, &arbol); err != nil {
+			return err
+		}
+		if datos, err = yaml.Marshal(arbol); err != nil {
+			return err
+		}
+	default:
+		return errors.New("la extensión debe ser .yaml, .yml o .toml")
+	}
+
+	decodificador := yaml.NewDecoder(bytes.NewReader(datos))
+	decodificador.KnownFields(true)
+	if err := decodificador.Decode(cargada.Config); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	var nodo yaml.Node
+	if err := yaml.Unmarshal(datos, &nodo); err != nil {
+		return err
+	}
+	for _, ruta := range rutasDelNodo(&nodo, "") {
+		cargada.Origenes[ruta] = OrigenArchivo
+	}
+	return nil
+}
+
+// rutasDelNodo lista las claves hoja de un documento YAML como sección.campo.
+func rutasDelNodo(nodo *yaml.Node, prefijo string) []string {
+	if nodo.Kind == yaml.DocumentNode && len(nodo.Content) > 0 {
+		return rutasDelNodo(nodo.Content[0], prefijo)
+	}
+	if nodo.Kind != yaml.MappingNode {
+		return []string{prefijo}
+	}
+	var rutas []string
+	for i := 0; i+1 < len(nodo.Content); i += 2 {
+		ruta := nodo.Content[i].Value
+		if prefijo != "" {
+			ruta = prefijo + "." + ruta
+		}
+		rutas = append(rutas, rutasDelNodo(nodo.Content[i+1], ruta)...)
+	}
+	return rutas
+}
+
+// -------------------- Banderas --------------------
+
+// valorBandera guarda el texto de una bandera para aplicarlo después del
+// archivo y el entorno; flag solo llama a Set con las que se pasaron.
+type valorBandera struct {
+	ruta    string
+	defecto string
+	esBool  bool
+	valores map[string]string
+}
+
+// IsBoolFlag permite escribir -base_de_datos.migrar_al_iniciar sin "=true".
+func (v *valorBandera) IsBoolFlag() bool { return v.esBool }
+
+func (v *valorBandera) String() string {
+	if v == nil {
+		return ""
+	}
+	return v.defecto
+}
+
+func (v *valorBandera) Set(texto string) error {
+	v.valores[v.ruta] = texto
+	return nil
+}
+
+// nuevasBanderas define una bandera -<sección>.<campo> por cada campo de cfg.
+func nuevasBanderas(nombre string, cfg *Config) (*flag.FlagSet, map[string]string) {
+	banderas := flag.NewFlagSet(nombre, flag.ContinueOnError)
+	valores := map[string]string{}
+	recorrer(cfg, func(ruta string, campo reflect.Value, sf reflect.StructField) {
+		uso := sf.Tag.Get("desc")
+		if variable := sf.Tag.Get("env"); variable != "" {
+			uso += " (" + variable + ")"
+		}
+		banderas.Var(&valorBandera{ruta: ruta, defecto: texto(campo), esBool: campo.Kind() == reflect.Bool, valores: valores}, ruta, uso)
+	})
+	return banderas, valores
+}
+
+// -------------------- Reflexión --------------------
+
+var tipoDuracion = reflect.TypeOf(time.Duration(0))
+
+// recorrer llama a f con cada campo hoja de cfg y su ruta en el YAML.
+func recorrer(cfg *Config, f func(ruta string, campo reflect.Value, sf reflect.StructField)) {
+	var visitar func(v reflect.Value, prefijo string)
+	visitar = func(v reflect.Value, prefijo string) {
+		for i := 0; i < v.NumField(); i++ {
+			sf := v.Type().Field(i)
+			ruta := strings.Split(sf.Tag.Get("yaml"), ",")[0]
+			if prefijo != "" {
+				ruta = prefijo + "." + ruta
+			}
+			if sf.Type.Kind() == reflect.Struct {
+				visitar(v.Field(i), ruta)
+				continue
+			}
+			f(ruta, v.Field(i), sf)
+		}
+	}
+	visitar(reflect.ValueOf(cfg).Elem(), "")
+}
+
+// asignar interpreta texto según el tipo del campo.
+func asignar(campo reflect.Value, texto string) error {
+	if campo.Type() == tipoDuracion {
+		d, err := time.ParseDuration(texto)
+		if err != nil {
+			return fmt.Errorf("%q no es una duración (ejemplos: 500ms, 5s, 2m)", texto)
+		}
+		campo.SetInt(int64(d))
+		return nil
+	}
+
+	// Los tipos propios, como Secreto, validan su texto
+	if campo.CanAddr() {
+		if u, ok := campo.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u.UnmarshalText([]byte(texto))
+		}
+	}
+
+	switch campo.Kind() {
+	case reflect.String:
+		campo.SetString(texto)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(texto, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q no es un número entero", texto)
+		}
+		campo.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(texto, 64)
+		if err != nil {
+			return fmt.Errorf("%q no es un número", texto)
+		}
+		campo.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(texto)
+		if err != nil {
+			return fmt.Errorf("%q no es true ni false", texto)
+		}
+		campo.SetBool(b)
+	case reflect.Slice:
+		var lista []string
+		for _, parte := range strings.Split(texto, ",") {
+			if parte = strings.TrimSpace(parte); parte != "" {
+				lista = append(lista, parte)
+			}
+		}
+		campo.Set(reflect.ValueOf(lista))
+	default:
+		return fmt.Errorf("tipo %s no soportado", campo.Type())
+	}
+	return nil
+}
+
+// texto es el inverso de asignar, para mostrar valores por defecto.
+func texto(campo reflect.Value) string {
+	if s, ok := campo.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	if campo.Kind() == reflect.Slice {
+		partes := make([]string, campo.Len())
+		for i := range partes {
+			partes[i] = fmt.Sprint(campo.Index(i).Interface())
+		}
+		return strings.Join(partes, ",")
+	}
+	return fmt.Sprint(campo.Interface())
+}
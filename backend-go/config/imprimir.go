@@ -0,0 +1,56 @@
+package config
+
+import (
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Imprimir escribe la configuración efectiva como YAML, con los secretos
+// vacíos y un comentario con el origen de cada valor que no es el de por
+// defecto. La salida sirve de punto de partida para un archivo -config.
+func (c *Cargada) Imprimir(w io.Writer) error {
+	var nodo yaml.Node
+	if err := nodo.Encode(c.Config); err != nil {
+		return err
+	}
+	comentarOrigenes(&nodo, "", c.Origenes)
+
+	if c.Archivo != "" {
+		if _, err := io.WriteString(w, "# archivo: "+c.Archivo+"\n"); err != nil {
+			return err
+		}
+	}
+	codificador := yaml.NewEncoder(w)
+	codificador.SetIndent(2)
+	if err := codificador.Encode(&nodo); err != nil {
+		return err
+	}
+	return codificador.Close()
+}
+
+func comentarOrigenes(nodo *yaml.Node, prefijo string, origenes map[string]string) {
+	for i := 0; i+1 < len(nodo.Content); i += 2 {
+		clave, valor := nodo.Content[i], nodo.Content[i+1]
+		ruta := clave.Value
+		if prefijo != "" {
+			ruta = prefijo + "." + ruta
+		}
+		if valor.Kind == yaml.ScalarNode && valor.Value == textoOculto {
+			// Un secreto sale vacío, no como "[oculto]": la salida se
+			// puede usar como archivo sin que ese texto pase por el valor
+			valor.Value, valor.Style = "", yaml.DoubleQuotedStyle
+			clave.LineComment = strings.TrimSpace(origenes[ruta] + "; oculto, se omite el valor")
+			continue
+		}
+		if valor.Kind == yaml.MappingNode {
+			comentarOrigenes(valor, ruta, origenes)
+		} else if origen, ok := origenes[ruta]; ok && valor.Kind == yaml.SequenceNode && len(valor.Content) == 0 {
+			// En la clave, yaml.v3 lo escribe después de la lista vacía []
+			valor.LineComment = origen
+		} else if ok {
+			clave.LineComment = origen
+		}
+	}
+}
@@ -1,147 +1,187 @@
 package database
 
 import (
-    "formula1-crud-go/models"
-    "log"
-    "os"
+    "context"
+    "fmt"
+    "formula1-crud-go/config"
+    "formula1-crud-go/migraciones"
+    "math/rand"
+    "strconv"
     "time"
 
-    "github.com/joho/godotenv"
+    "github.com/glebarez/sqlite"
     "gorm.io/driver/postgres"
     "gorm.io/gorm"
-    "gorm.io/gorm/logger"
 )
 
 var DB *gorm.DB
 
-func ConectarBaseDeDatos() {
-    // Cargar variables de entorno
-    err := godotenv.Load()
+// ConectarBaseDeDatos abre DB y aplica las migraciones pendientes
+// (migrar_al_iniciar: false lo deja para "formula1-crud migrar subir").
+func ConectarBaseDeDatos(cfg config.BaseDeDatos) error {
+    var err error
+    DB, err = Conectar(context.Background(), cfg)
     if err != nil {
-        log.Println("⚠️  No se encontró archivo .env, usando variables de entorno del sistema")
+        return fmt.Errorf("conectando a la base de datos: %w", err)
     }
 
-    // Configurar conexión PostgreSQL
-    dsn := obtenerDSN()
+    registroDB.Info("conectado a la base de datos", "driver", DB.Dialector.Name())
 
-    // Configurar logger de GORM
-    newLogger := logger.New(
-        log.New(os.Stdout, "\r\n", log.LstdFlags),
-        logger.Config{
-            SlowThreshold: time.Second,
-            LogLevel:      logger.Info,
-            Colorful:      true,
-        },
-    )
+    if !cfg.MigrarAlIniciar {
+        return nil
+    }
+    if err := Migrar(DB); err != nil {
+        return fmt.Errorf("migrando la base de datos: %w", err)
+    }
+    return nil
+}
 
-    // Conectar a PostgreSQL
-    DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
-        Logger: newLogger,
-    })
-    if err != nil {
-        log.Fatal("❌ Error conectando a PostgreSQL:", err)
+// -------------------- Reintentos --------------------
+
+// Conectar llama a Abrir hasta que la base responde, duplicando la espera
+// entre intentos (con un poco de azar, para que varias réplicas no
+// reintenten a la vez) hasta EsperaMaxima. Reintentos en 0 no tiene límite.
+func Conectar(ctx context.Context, cfg config.BaseDeDatos) (*gorm.DB, error) {
+    espera := cfg.EsperaInicial
+    for intento := 1; ; intento++ {
+        db, err := Abrir(cfg)
+        if err == nil {
+            return db, nil
+        }
+        cerrar(db)
+        if cfg.Reintentos > 0 && intento >= cfg.Reintentos {
+            return nil, fmt.Errorf("sin conexión tras %d intentos: %w", intento, err)
+        }
+
+        pausa := espera/2 + time.Duration(rand.Int63n(int64(espera/2)+1))
+        registroDB.Warn("base de datos no disponible, reintentando", "intento", intento, "error", err, "espera", pausa.Round(time.Millisecond).String())
+        select {
+        case <-ctx.Done():
+            return nil, ctx.Err()
+        case <-time.After(pausa):
+        }
+        espera = min(espera*2, cfg.EsperaMaxima)
+    }
+}
+
+// cerrar libera el pool de un intento fallido (gorm.Open lo devuelve aunque
+// el ping falle).
+func cerrar(db *gorm.DB) {
+    if db == nil {
+        return
+    }
+    if sqlDB, err := db.DB(); err == nil {
+        sqlDB.Close()
     }
+}
 
-    log.Println("✅ Conectado a PostgreSQL exitosamente")
+// Abrir conecta a la base de cfg, sin migrar. Driver elige el motor:
+// postgres o sqlite, que guarda todo en el archivo Ruta y no necesita
+// ningún servicio externo.
+func Abrir(cfg config.BaseDeDatos) (*gorm.DB, error) {
+    var dialector gorm.Dialector
+    switch cfg.Driver {
+    case "postgres":
+        dialector = postgres.Open(obtenerDSN(cfg))
+    case "sqlite":
+        dialector = sqlite.Open(obtenerRutaSQLite(cfg.Ruta))
+    default:
+        return nil, fmt.Errorf("driver %q no soportado (postgres o sqlite)", cfg.Driver)
+    }
 
-    // Migrar el schema
-    err = DB.AutoMigrate(&models.Piloto{})
+    db, err := gorm.Open(dialector, &gorm.Config{
+        Logger: registroSQL{lenta: cfg.ConsultaLenta, conValores: cfg.SQLConValores},
+        // Errores comunes (clave duplicada, ...) como errores de gorm
+        TranslateError: true,
+    })
     if err != nil {
-        log.Fatal("❌ Error migrando la base de datos:", err)
+        return db, err
     }
+    if err := db.Use(trazasSQL{}); err != nil {
+        return db, err
+    }
+    if err := configurarPool(db, cfg); err != nil {
+        return db, err
+    }
+    return db, nil
+}
 
-    log.Println("✅ Esquema migrado exitosamente")
-
-    // Insertar datos de ejemplo
-    insertarDatosEjemplo()
+// configurarPool ajusta el pool de database/sql. Renovar las conexiones
+// cada tanto evita quedarse con sockets que un balanceador o un reinicio de
+// PostgreSQL dejaron muertos.
+func configurarPool(db *gorm.DB, cfg config.BaseDeDatos) error {
+    sqlDB, err := db.DB()
+    if err != nil {
+        return err
+    }
+    sqlDB.SetMaxOpenConns(cfg.MaxConexiones)
+    sqlDB.SetMaxIdleConns(cfg.MaxInactivas)
+    sqlDB.SetConnMaxLifetime(cfg.VidaConexion)
+    sqlDB.SetConnMaxIdleTime(cfg.MaxInactividad)
+    return nil
 }
 
-func obtenerDSN() string {
-    host := getEnv("DB_HOST", "localhost")
-    port := getEnv("DB_PORT", "5432")
-    user := getEnv("DB_USER", "formula1_user")
-    password := getEnv("DB_PASSWORD", "formula1_password")
-    dbname := getEnv("DB_NAME", "formula1_db")
-    sslmode := getEnv("DB_SSLMODE", "disable")
-
-    return "host=" + host + " port=" + port + " user=" + user + 
-           " password=" + password + " dbname=" + dbname + 
-           " sslmode=" + sslmode + " TimeZone=UTC"
+// NuevoMigrador prepara las migraciones del motor de db (migraciones/sql/<motor>).
+func NuevoMigrador(db *gorm.DB) (*migraciones.Migrador, error) {
+    sqlDB, err := db.DB()
+    if err != nil {
+        return nil, err
+    }
+    migrador, err := migraciones.NuevoMigrador(sqlDB, migraciones.Dialecto(db.Dialector.Name()))
+    if err != nil {
+        return nil, err
+    }
+    migrador.Registro = func(p migraciones.Paso) {
+        mensaje := "migración aplicada"
+        if !p.Subida {
+            mensaje = "migración revertida"
+        }
+        registroDB.Info(mensaje, "version", p.Version, "nombre", p.Nombre, "duracion", p.Duracion.Round(time.Millisecond).String())
+    }
+    return migrador, nil
 }
 
-func getEnv(key, defaultValue string) string {
-    value := os.Getenv(key)
-    if value == "" {
-        return defaultValue
+// Cerrar cierra el pool de conexiones; va al final del apagado, cuando ya
+// no queda nadie que use la base.
+func Cerrar() error {
+    if DB == nil {
+        return nil
+    }
+    sqlDB, err := DB.DB()
+    if err != nil {
+        return err
     }
-    return value
+    return sqlDB.Close()
 }
 
-func insertarDatosEjemplo() {
-    pilotosEjemplo := []models.Piloto{
-        {
-            Nombre: "Max Verstappen", 
-            Equipo: "Red Bull", 
-            Nacionalidad: "Holandés", 
-            Numero: 1, 
-            Victorias: 54, 
-            Puntos: 575.5, 
-            Podios: 98, 
-            Poles: 33, 
-            VueltasRapidas: 30,
-        },
-        {
-            Nombre: "Lewis Hamilton", 
-            Equipo: "Mercedes", 
-            Nacionalidad: "Británico", 
-            Numero: 44, 
-            Victorias: 103, 
-            Puntos: 4637.5, 
-            Podios: 197, 
-            Poles: 104, 
-            VueltasRapidas: 65,
-        },
-        {
-            Nombre: "Charles Leclerc", 
-            Equipo: "Ferrari", 
-            Nacionalidad: "Monegasco", 
-            Numero: 16, 
-            Victorias: 5, 
-            Puntos: 1074, 
-            Podios: 32, 
-            Poles: 23, 
-            VueltasRapidas: 9,
-        },
-        {
-            Nombre: "Fernando Alonso", 
-            Equipo: "Aston Martin", 
-            Nacionalidad: "Español", 
-            Numero: 14, 
-            Victorias: 32, 
-            Puntos: 2267, 
-            Podios: 106, 
-            Poles: 22, 
-            VueltasRapidas: 24,
-        },
-        {
-            Nombre: "Carlos Sainz", 
-            Equipo: "Ferrari", 
-            Nacionalidad: "Español", 
-            Numero: 55, 
-            Victorias: 2, 
-            Puntos: 782.5, 
-            Podios: 18, 
-            Poles: 5, 
-            VueltasRapidas: 3,
-        },
-    }
-
-    for _, piloto := range pilotosEjemplo {
-        var existe models.Piloto
-        result := DB.Where("nombre = ?", piloto.Nombre).First(&existe)
-        if result.Error != nil {
-            DB.Create(&piloto)
-            log.Printf("✅ Insertado piloto: %s", piloto.Nombre)
-        }
+// Migrar aplica todas las migraciones pendientes.
+func Migrar(db *gorm.DB) error {
+    migrador, err := NuevoMigrador(db)
+    if err != nil {
+        return err
     }
+    pasos, err := migrador.Subir(context.Background())
+    if err != nil {
+        return err
+    }
+    registroDB.Info("esquema al día", "version", migrador.Ultima(), "aplicadas", len(pasos))
+    return nil
+}
+
+func obtenerDSN(cfg config.BaseDeDatos) string {
+    // connect_timeout va en segundos enteros; sin él, un host que no
+    // responde deja colgado cada intento
+    timeout := max(int(cfg.TimeoutConexion/time.Second), 1)
+
+    return "host=" + cfg.Host + " port=" + strconv.Itoa(cfg.Puerto) + " user=" + cfg.Usuario + 
+           " password=" + cfg.Contrasena.Valor() + " dbname=" + cfg.Nombre + 
+           " sslmode=" + cfg.SSLMode + " TimeZone=UTC" +
+           " connect_timeout=" + strconv.Itoa(timeout)
+}
+
+// obtenerRutaSQLite arma el DSN del archivo SQLite: claves foráneas activas,
+// espera en vez de fallar si el archivo está ocupado y WAL para que las
+// lecturas no bloqueen a las escrituras.
+func obtenerRutaSQLite(ruta string) string {
+    return "file:" + ruta + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
 }
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"formula1-crud-go/registro"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var registroDB = registro.Para(registro.DB)
+
+// registroSQL lleva los logs de GORM al subsistema db. Cada consulta se
+// registra con el ID del pedido que la originó: como debug, como warn si
+// tarda más que lenta o como error si falla. Salvo conValores, el SQL se
+// registra con los marcadores ($1, ?) en lugar de los valores, que pueden
+// ser datos de los usuarios.
+type registroSQL struct {
+	lenta      time.Duration
+	conValores bool
+}
+
+// LogMode no hace nada: el nivel es el del subsistema db.
+func (r registroSQL) LogMode(logger.LogLevel) logger.Interface { return r }
+
+func (r registroSQL) Info(ctx context.Context, msg string, datos ...any) {
+	registroDB.InfoContext(ctx, fmt.Sprintf(msg, datos...))
+}
+
+func (r registroSQL) Warn(ctx context.Context, msg string, datos ...any) {
+	registroDB.WarnContext(ctx, fmt.Sprintf(msg, datos...))
+}
+
+func (r registroSQL) Error(ctx context.Context, msg string, datos ...any) {
+	registroDB.ErrorContext(ctx, fmt.Sprintf(msg, datos...))
+}
+
+func (r registroSQL) Trace(ctx context.Context, inicio time.Time, fc func() (string, int64), err error) {
+	duracion := time.Since(inicio)
+	nivel, mensaje := slog.LevelDebug, "consulta"
+	switch {
+	case err != nil && !esperado(err):
+		nivel, mensaje = slog.LevelError, "consulta fallida"
+	case duracion > r.lenta:
+		nivel, mensaje = slog.LevelWarn, "consulta lenta"
+	}
+	// Armar el SQL cuesta: solo si se va a registrar
+	if !registroDB.Enabled(ctx, nivel) {
+		return
+	}
+
+	sql, filas := fc()
+	attrs := []slog.Attr{
+		slog.String("sql", sql),
+		slog.Float64("duracion_ms", float64(duracion.Microseconds())/1000),
+	}
+	if filas >= 0 {
+		attrs = append(attrs, slog.Int64("filas", filas))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	registroDB.LogAttrs(ctx, nivel, mensaje, attrs...)
+}
+
+// ParamsFilter quita los valores antes de que GORM los escriba en el SQL.
+func (r registroSQL) ParamsFilter(_ context.Context, sql string, valores ...any) (string, []any) {
+	if r.conValores {
+		return sql, valores
+	}
+	return sql, nil
+}
+
+// esperado son los errores que los manejadores convierten en 404 o 409: no
+// son fallas del servidor.
+func esperado(err error) bool {
+	return errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, gorm.ErrDuplicatedKey) || errors.Is(err, gorm.ErrForeignKeyViolated)
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"formula1-crud-go/metricas"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+)
+
+var baseDisponible = promauto.With(metricas.Registro).NewGauge(prometheus.GaugeOpts{
+	Namespace: metricas.Espacio,
+	Subsystem: "db",
+	Name:      "disponible",
+	Help:      "1 si el último ping del monitor a la base respondió, 0 si no.",
+})
+
+// Monitor hace ping a la base cada cierto tiempo y recuerda el resultado,
+// para que los manejadores respondan 503 al instante cuando está caída en
+// vez de esperar el timeout de cada consulta.
+type Monitor struct {
+	db      *sql.DB
+	periodo time.Duration
+	revisar chan struct{}
+
+	mu  sync.RWMutex
+	err error
+}
+
+// NuevoMonitor parte suponiendo que la base está disponible: se crea después
+// de conectar.
+func NuevoMonitor(db *gorm.DB, periodo time.Duration) (*Monitor, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	baseDisponible.Set(1)
+	return &Monitor{db: sqlDB, periodo: periodo, revisar: make(chan struct{}, 1)}, nil
+}
+
+// Iniciar comprueba la base periódicamente, o antes si alguien llama a
+// Revisar, hasta que ctx se cancela.
+func (m *Monitor) Iniciar(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(m.periodo)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-m.revisar:
+			}
+			espera, cancelar := context.WithTimeout(ctx, m.periodo)
+			m.Comprobar(espera)
+			cancelar()
+		}
+	}()
+}
+
+// Comprobar hace ping ahora, guarda el resultado y lo devuelve.
+func (m *Monitor) Comprobar(ctx context.Context) error {
+	err := m.db.PingContext(ctx)
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		// Cancelado por quien llamó, no es un síntoma de la base
+		return err
+	}
+
+	m.mu.Lock()
+	anterior := m.err
+	m.err = err
+	m.mu.Unlock()
+
+	switch {
+	case err != nil && anterior == nil:
+		baseDisponible.Set(0)
+		registroDB.Warn("base de datos no disponible", "error", err)
+	case err == nil && anterior != nil:
+		baseDisponible.Set(1)
+		registroDB.Info("base de datos disponible de nuevo")
+	}
+	return err
+}
+
+// Disponible devuelve el error del último ping, o nil si respondió.
+func (m *Monitor) Disponible() error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.err
+}
+
+// Revisar pide una comprobación sin esperar al próximo período; no bloquea.
+func (m *Monitor) Revisar() {
+	select {
+	case m.revisar <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"errors"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+var trazador = otel.Tracer("formula1-crud-go/database")
+
+// trazasSQL es un plugin de GORM que abre un span por consulta, hijo del
+// que venga en el contexto (db.WithContext(ctx)). Como en los logs, el
+// SQL va con los marcadores ($1, ?) y sin los valores.
+type trazasSQL struct{}
+
+func (trazasSQL) Name() string { return "trazas" }
+
+func (trazasSQL) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("trazas:antes_create", antesDeConsulta),
+		cb.Create().After("gorm:create").Register("trazas:despues_create", despuesDeConsulta),
+		cb.Query().Before("gorm:query").Register("trazas:antes_query", antesDeConsulta),
+		cb.Query().After("gorm:query").Register("trazas:despues_query", despuesDeConsulta),
+		cb.Update().Before("gorm:update").Register("trazas:antes_update", antesDeConsulta),
+		cb.Update().After("gorm:update").Register("trazas:despues_update", despuesDeConsulta),
+		cb.Delete().Before("gorm:delete").Register("trazas:antes_delete", antesDeConsulta),
+		cb.Delete().After("gorm:delete").Register("trazas:despues_delete", despuesDeConsulta),
+		cb.Row().Before("gorm:row").Register("trazas:antes_row", antesDeConsulta),
+		cb.Row().After("gorm:row").Register("trazas:despues_row", despuesDeConsulta),
+		cb.Raw().Before("gorm:raw").Register("trazas:antes_raw", antesDeConsulta),
+		cb.Raw().After("gorm:raw").Register("trazas:despues_raw", despuesDeConsulta),
+	)
+}
+
+// antesDeConsulta deja el span en el contexto de la sentencia, así el log
+// de la consulta lleva su traza_id.
+func antesDeConsulta(db *gorm.DB) {
+	ctx, _ := trazador.Start(db.Statement.Context, "consulta", trace.WithSpanKind(trace.SpanKindClient))
+	db.Statement.Context = ctx
+}
+
+// despuesDeConsulta nombra el span cuando ya se conoce el SQL, como
+// "SELECT pilotos", y lo cierra.
+func despuesDeConsulta(db *gorm.DB) {
+	span := trace.SpanFromContext(db.Statement.Context)
+	if !span.IsRecording() {
+		span.End()
+		return
+	}
+
+	sql := db.Statement.SQL.String()
+	operacion, _, _ := strings.Cut(strings.TrimSpace(sql), " ")
+	operacion = strings.ToUpper(operacion)
+	attrs := []attribute.KeyValue{
+		sistemaDB(db.Dialector.Name()),
+		semconv.DBQueryText(sql),
+		semconv.DBOperationName(operacion),
+		attribute.Int64("db.filas", db.Statement.RowsAffected),
+	}
+	nombre := operacion
+	if tabla := db.Statement.Table; tabla != "" {
+		nombre += " " + tabla
+		attrs = append(attrs, semconv.DBCollectionName(tabla))
+	}
+	// Sin SQL (la consulta falló antes de armarlo) queda "consulta"
+	if operacion != "" {
+		span.SetName(nombre)
+	}
+	span.SetAttributes(attrs...)
+	if err := db.Error; err != nil && !esperado(err) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func sistemaDB(dialecto string) attribute.KeyValue {
+	switch dialecto {
+	case "postgres":
+		return semconv.DBSystemNamePostgreSQL
+	case "sqlite":
+		return semconv.DBSystemNameSQLite
+	}
+	return semconv.DBSystemNameKey.String(dialecto)
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+	"formula1-crud-go/config"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// -------------------- Administración --------------------
+
+// rolAdmin es el valor del claim "rol" que abre las rutas de administración.
+const rolAdmin = "admin"
+
+var errSinSecreto = errors.New("administración deshabilitada: falta auth.secreto_jwt (JWT_SECRETO)")
+
+type reclamosAdmin struct {
+	Rol string `json:"rol"`
+	jwt.RegisteredClaims
+}
+
+// SoloAdmin deja pasar los pedidos con un JWT firmado con la clave de la
+// configuración y el claim rol "admin", en la cabecera Authorization
+// (con o sin "Bearer "). Sin clave configurada rechaza todo.
+func SoloAdmin(secreto config.Secreto) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if secreto.Valor() == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": errSinSecreto.Error()})
+			return
+		}
+
+		texto := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if texto == "" {
+			c.Header("WWW-Authenticate", "Bearer")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Falta el token de administración"})
+			return
+		}
+
+		var reclamos reclamosAdmin
+		token, err := jwt.ParseWithClaims(texto, &reclamos, func(*jwt.Token) (any, error) {
+			return []byte(secreto.Valor()), nil
+		}, jwt.WithValidMethods([]string{"HS256", "HS384", "HS512"}))
+		if err != nil || !token.Valid {
+			c.Header("WWW-Authenticate", "Bearer")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token inválido o vencido"})
+			return
+		}
+		if reclamos.Rol != rolAdmin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Se necesita el rol admin"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// NuevoTokenAdmin firma un token con rol admin que vence en duracion.
+func NuevoTokenAdmin(secreto config.Secreto, duracion time.Duration) (string, error) {
+	if secreto.Valor() == "" {
+		return "", errSinSecreto
+	}
+	ahora := time.Now()
+	reclamos := reclamosAdmin{
+		Rol: rolAdmin,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   rolAdmin,
+			IssuedAt:  jwt.NewNumericDate(ahora),
+			ExpiresAt: jwt.NewNumericDate(ahora.Add(duracion)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, reclamos).SignedString([]byte(secreto.Valor()))
+}
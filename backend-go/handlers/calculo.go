@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"fmt"
+	"formula1-crud-go/calculo"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// Límites del laboratorio de π
+	maxIteracionesPi = 200_000_000
+	maxHilosPi       = 64
+	// Con precisión arbitraria el costo crece con los dígitos: se acota
+	// iteraciones × dígitos
+	maxTrabajoPrecision = 100_000_000
+)
+
+type ManejadorCalculo struct{}
+
+func NuevoManejadorCalculo() *ManejadorCalculo {
+	return &ManejadorCalculo{}
+}
+
+// Calcular π en paralelo. Parámetros: metodo (punto_medio, trapecio, simpson,
+// montecarlo, leibniz, machin o bbp), iteraciones, hilos, reparto (bloques o
+// ciclico), kahan, digitos (precisión arbitraria) y semilla (Monte Carlo).
+// La respuesta incluye el tiempo y la suma parcial de cada hilo.
+func (m *ManejadorCalculo) CalcularPi(c *gin.Context) {
+	opciones, err := opcionesPiDesde(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	medir := medirCalculo("pi", string(opciones.Metodo))
+	resultado, err := calculo.CalcularPi(opciones)
+	medir()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, resultado)
+}
+
+func opcionesPiDesde(parametros url.Values) (calculo.OpcionesPi, error) {
+	opciones := calculo.OpcionesPi{Iteraciones: 1_000_000, Hilos: 4}
+	var err error
+	if opciones.Metodo, err = calculo.ParseMetodo(parametros.Get("metodo")); err != nil {
+		return opciones, err
+	}
+	if opciones.Reparto, err = calculo.ParseReparto(parametros.Get("reparto")); err != nil {
+		return opciones, err
+	}
+	if texto := parametros.Get("iteraciones"); texto != "" {
+		if opciones.Iteraciones, err = strconv.Atoi(texto); err != nil || opciones.Iteraciones < 1 || opciones.Iteraciones > maxIteracionesPi {
+			return opciones, fmt.Errorf("iteraciones debe ser un entero entre 1 y %d", maxIteracionesPi)
+		}
+	}
+	if texto := parametros.Get("hilos"); texto != "" {
+		if opciones.Hilos, err = strconv.Atoi(texto); err != nil || opciones.Hilos < 1 || opciones.Hilos > maxHilosPi {
+			return opciones, fmt.Errorf("hilos debe ser un entero entre 1 y %d", maxHilosPi)
+		}
+	}
+	if texto := parametros.Get("kahan"); texto != "" {
+		if opciones.Kahan, err = strconv.ParseBool(texto); err != nil {
+			return opciones, fmt.Errorf("kahan inválido: %q", texto)
+		}
+	}
+	if texto := parametros.Get("digitos"); texto != "" {
+		if opciones.Digitos, err = strconv.Atoi(texto); err != nil || opciones.Digitos < 0 || opciones.Digitos > calculo.MaxDigitos {
+			return opciones, fmt.Errorf("digitos debe ser un entero entre 0 y %d", calculo.MaxDigitos)
+		}
+		if opciones.Digitos > 0 && opciones.Iteraciones*max(opciones.Digitos, 100) > maxTrabajoPrecision {
+			return opciones, fmt.Errorf("con %d dígitos se admiten hasta %d iteraciones", opciones.Digitos, maxTrabajoPrecision/max(opciones.Digitos, 100))
+		}
+	}
+	if texto := parametros.Get("semilla"); texto != "" {
+		if opciones.Semilla, err = strconv.ParseInt(texto, 10, 64); err != nil {
+			return opciones, fmt.Errorf("semilla inválida: %q", texto)
+		}
+	}
+	return opciones, nil
+}
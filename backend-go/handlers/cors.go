@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"formula1-crud-go/config"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// -------------------- CORS --------------------
+
+// CORS deja llamar a la API desde los orígenes de cfg. Sin orígenes solo
+// funciona el frontend servido por el mismo backend; "*" admite cualquiera.
+func CORS(cfg config.CORS) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origen := c.GetHeader("Origin")
+		c.Writer.Header().Add("Vary", "Origin")
+		if origen == "" || !origenPermitido(cfg, origen) {
+			c.Next()
+			return
+		}
+
+		if permiteCualquiera(cfg) {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Access-Control-Allow-Origin", origen)
+		}
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Header("Access-Control-Expose-Headers", "Location, X-Semilla")
+
+		if c.Request.Method == http.MethodOptions {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
+
+// origenPermitido compara sin distinguir mayúsculas ni la barra final.
+func origenPermitido(cfg config.CORS, origen string) bool {
+	origen = strings.TrimSuffix(origen, "/")
+	for _, permitido := range cfg.Origenes {
+		if permitido == "*" || strings.EqualFold(strings.TrimSuffix(permitido, "/"), origen) {
+			return true
+		}
+	}
+	return false
+}
+
+func permiteCualquiera(cfg config.CORS) bool {
+	for _, permitido := range cfg.Origenes {
+		if permitido == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// mismoOrigen es la comprobación por defecto de gorilla/websocket: el
+// Origin del navegador apunta al mismo host que atiende el pedido.
+func mismoOrigen(r *http.Request) bool {
+	origen := r.Header.Get("Origin")
+	if origen == "" {
+		return true
+	}
+	u, err := url.Parse(origen)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
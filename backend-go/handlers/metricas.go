@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"formula1-crud-go/metricas"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// -------------------- Métricas --------------------
+
+var (
+	pedidosHTTP = promauto.With(metricas.Registro).NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricas.Espacio,
+		Subsystem: "http",
+		Name:      "pedidos_total",
+		Help:      "Pedidos HTTP atendidos, por ruta de gin (la plantilla, como /api/pilotos/:id), método y estado.",
+	}, []string{"metodo", "ruta", "estado"})
+
+	duracionHTTP = promauto.With(metricas.Registro).NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricas.Espacio,
+		Subsystem: "http",
+		Name:      "pedido_duracion_seconds",
+		Help:      "Duración de los pedidos HTTP, por ruta de gin y método.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"metodo", "ruta"})
+
+	conexionesAbiertas = promauto.With(metricas.Registro).NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricas.Espacio,
+		Subsystem: "ws",
+		Name:      "conexiones_activas",
+		Help:      "Conexiones de simulaciones abiertas, por transporte (websocket o sse).",
+	}, []string{"transporte"})
+
+	mensajesEnviados = promauto.With(metricas.Registro).NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricas.Espacio,
+		Subsystem: "ws",
+		Name:      "mensajes_enviados_total",
+		Help:      "Mensajes enviados a los clientes de simulaciones, por transporte y tipo de mensaje.",
+	}, []string{"transporte", "tipo"})
+
+	duracionCalculo = promauto.With(metricas.Registro).NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricas.Espacio,
+		Subsystem: "calculo",
+		Name:      "duracion_seconds",
+		Help:      "Duración de los cálculos pedidos por la API: pi (por método) y el procesamiento de telemetría (por operación).",
+		Buckets:   metricas.BucketsCalculo,
+	}, []string{"calculo", "variante"})
+)
+
+const (
+	transporteWS  = "websocket"
+	transporteSSE = "sse"
+)
+
+// rutaSinPlantilla agrupa los pedidos que no coinciden con ninguna ruta,
+// para que un barrido de URLs al azar no cree una serie por cada una.
+const rutaSinPlantilla = "sin_ruta"
+
+// MedirPedidos cuenta los pedidos y mide su duración por plantilla de ruta.
+func MedirPedidos() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		inicio := time.Now()
+		c.Next()
+
+		ruta := c.FullPath()
+		if ruta == "" {
+			ruta = rutaSinPlantilla
+		}
+		metodo := c.Request.Method
+		pedidosHTTP.WithLabelValues(metodo, ruta, strconv.Itoa(c.Writer.Status())).Inc()
+		duracionHTTP.WithLabelValues(metodo, ruta).Observe(time.Since(inicio).Seconds())
+	}
+}
+
+// medirCalculo devuelve una función que registra la duración al llamarla:
+// defer medirCalculo("pi", metodo)().
+func medirCalculo(calculo, variante string) func() {
+	inicio := time.Now()
+	return func() {
+		duracionCalculo.WithLabelValues(calculo, variante).Observe(time.Since(inicio).Seconds())
+	}
+}
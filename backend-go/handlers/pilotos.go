@@ -1,27 +1,29 @@
 package handlers
 
 import (
+	"errors"
 	"formula1-crud-go/models"
+	"formula1-crud-go/repositorios"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 type ManejadorPilotos struct {
-	DB *gorm.DB
+	Pilotos repositorios.RepositorioPilotos
 }
 
-func NuevoManejadorPilotos(db *gorm.DB) *ManejadorPilotos {
-	return &ManejadorPilotos{DB: db}
+func NuevoManejadorPilotos(pilotos repositorios.RepositorioPilotos) *ManejadorPilotos {
+	return &ManejadorPilotos{Pilotos: pilotos}
 }
 
-// Obtener todos los pilotos
+// Obtener todos los pilotos, opcionalmente filtrados por equipo y nacionalidad
 func (m *ManejadorPilotos) ObtenerPilotos(c *gin.Context) {
-	var pilotos []models.Piloto
-	resultado := m.DB.Find(&pilotos)
-	if resultado.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": resultado.Error.Error()})
+	filtro := repositorios.FiltroPilotos{Equipo: c.Query("equipo"), Nacionalidad: c.Query("nacionalidad")}
+	pilotos, err := m.Pilotos.Listar(c.Request.Context(), filtro)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, pilotos)
@@ -29,11 +31,8 @@ func (m *ManejadorPilotos) ObtenerPilotos(c *gin.Context) {
 
 // Obtener un piloto por ID
 func (m *ManejadorPilotos) ObtenerPiloto(c *gin.Context) {
-	id := c.Param("id")
-	var piloto models.Piloto
-	resultado := m.DB.First(&piloto, id)
-	if resultado.Error != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Piloto no encontrado"})
+	piloto, ok := m.buscarPiloto(c)
+	if !ok {
 		return
 	}
 	c.JSON(http.StatusOK, piloto)
@@ -46,10 +45,10 @@ func (m *ManejadorPilotos) CrearPiloto(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	piloto.ID = 0
 
-	resultado := m.DB.Create(&piloto)
-	if resultado.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": resultado.Error.Error()})
+	if err := m.Pilotos.Crear(c.Request.Context(), &piloto); err != nil {
+		responderErrorPiloto(c, err)
 		return
 	}
 
@@ -58,75 +57,89 @@ func (m *ManejadorPilotos) CrearPiloto(c *gin.Context) {
 
 // Actualizar piloto
 func (m *ManejadorPilotos) ActualizarPiloto(c *gin.Context) {
-	id := c.Param("id")
-	var piloto models.Piloto
-
-	if err := m.DB.First(&piloto, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Piloto no encontrado"})
+	piloto, ok := m.buscarPiloto(c)
+	if !ok {
 		return
 	}
 
+	id := piloto.ID
 	if err := c.ShouldBindJSON(&piloto); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	piloto.ID = id
 
-	m.DB.Save(&piloto)
+	if err := m.Pilotos.Actualizar(c.Request.Context(), &piloto); err != nil {
+		responderErrorPiloto(c, err)
+		return
+	}
 	c.JSON(http.StatusOK, piloto)
 }
 
 // Eliminar piloto
 func (m *ManejadorPilotos) EliminarPiloto(c *gin.Context) {
-	id := c.Param("id")
-	var piloto models.Piloto
-
-	if err := m.DB.First(&piloto, id).Error; err != nil {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Piloto no encontrado"})
 		return
 	}
 
-	m.DB.Delete(&piloto)
+	if err := m.Pilotos.Eliminar(c.Request.Context(), uint(id)); err != nil {
+		responderErrorPiloto(c, err)
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"mensaje": "Piloto eliminado correctamente"})
 }
 
 // Obtener estadísticas
 func (m *ManejadorPilotos) ObtenerEstadisticas(c *gin.Context) {
-	var totalPilotos int64
-	var totalVictorias int64
-	var totalPuntos float64
-	var pilotoMasVictorias models.Piloto
-
-	m.DB.Model(&models.Piloto{}).Count(&totalPilotos)
-	m.DB.Model(&models.Piloto{}).Select("SUM(victorias)").Row().Scan(&totalVictorias)
-	m.DB.Model(&models.Piloto{}).Select("SUM(puntos)").Row().Scan(&totalPuntos)
-	m.DB.Order("victorias desc").First(&pilotoMasVictorias)
-
-	estadisticas := gin.H{
-		"total_pilotos":        totalPilotos,
-		"total_victorias":      totalVictorias,
-		"total_puntos":         totalPuntos,
-		"piloto_mas_victorias": pilotoMasVictorias.Nombre,
-		"victorias_piloto":     pilotoMasVictorias.Victorias,
+	estadisticas, err := m.Pilotos.Estadisticas(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
-
 	c.JSON(http.StatusOK, estadisticas)
 }
 
 // Buscar pilotos por equipo
 func (m *ManejadorPilotos) BuscarPorEquipo(c *gin.Context) {
 	equipo := c.Query("equipo")
-	var pilotos []models.Piloto
-
 	if equipo == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Parámetro 'equipo' requerido"})
 		return
 	}
 
-	resultado := m.DB.Where("equipo LIKE ?", "%"+equipo+"%").Find(&pilotos)
-	if resultado.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": resultado.Error.Error()})
+	pilotos, err := m.Pilotos.Listar(c.Request.Context(), repositorios.FiltroPilotos{Equipo: equipo})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, pilotos)
 }
+
+// buscarPiloto carga el piloto del parámetro id o responde el error.
+func (m *ManejadorPilotos) buscarPiloto(c *gin.Context) (models.Piloto, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Piloto no encontrado"})
+		return models.Piloto{}, false
+	}
+	piloto, err := m.Pilotos.Obtener(c.Request.Context(), uint(id))
+	if err != nil {
+		responderErrorPiloto(c, err)
+		return piloto, false
+	}
+	return piloto, true
+}
+
+func responderErrorPiloto(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repositorios.ErrNoEncontrado):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Piloto no encontrado"})
+	case errors.Is(err, repositorios.ErrDuplicado):
+		c.JSON(http.StatusConflict, gin.H{"error": "Ya existe un piloto con ese nombre"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
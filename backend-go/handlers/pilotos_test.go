@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"formula1-crud-go/models"
+	"formula1-crud-go/repositorios"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// pilotosDePrueba arranca cada prueba con los mismos tres pilotos.
+func pilotosDePrueba() []models.Piloto {
+	return []models.Piloto{
+		{Model: gorm.Model{ID: 1}, Nombre: "Max Verstappen", Equipo: "Red Bull", Nacionalidad: "Holandés", Numero: 1, Victorias: 54, Puntos: 575.5},
+		{Model: gorm.Model{ID: 2}, Nombre: "Charles Leclerc", Equipo: "Ferrari", Nacionalidad: "Monegasco", Numero: 16, Victorias: 5, Puntos: 1074},
+		{Model: gorm.Model{ID: 3}, Nombre: "Carlos Sainz", Equipo: "Ferrari", Nacionalidad: "Español", Numero: 55, Victorias: 2, Puntos: 782.5},
+	}
+}
+
+// routerPilotos arma las rutas de main.go sobre un repositorio en memoria.
+func routerPilotos(pilotos ...models.Piloto) (*gin.Engine, *repositorios.PilotosMemoria) {
+	gin.SetMode(gin.TestMode)
+	repo := repositorios.NuevoRepositorioPilotosMemoria(pilotos...)
+	m := NuevoManejadorPilotos(repo)
+	r := gin.New()
+	api := r.Group("/api")
+	api.GET("/pilotos", m.ObtenerPilotos)
+	api.GET("/pilotos/:id", m.ObtenerPiloto)
+	api.POST("/pilotos", m.CrearPiloto)
+	api.PUT("/pilotos/:id", m.ActualizarPiloto)
+	api.DELETE("/pilotos/:id", m.EliminarPiloto)
+	api.GET("/estadisticas", m.ObtenerEstadisticas)
+	api.GET("/buscar", m.BuscarPorEquipo)
+	return r, repo
+}
+
+func pedir(t *testing.T, r http.Handler, metodo, ruta string, cuerpo any) *httptest.ResponseRecorder {
+	t.Helper()
+	var lector bytes.Buffer
+	if cuerpo != nil {
+		if err := json.NewEncoder(&lector).Encode(cuerpo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(metodo, ruta, &lector)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func decodificar[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatalf("respuesta no es JSON (%v): %s", err, w.Body.String())
+	}
+	return v
+}
+
+func nombres(pilotos []models.Piloto) []string {
+	var n []string
+	for _, p := range pilotos {
+		n = append(n, p.Nombre)
+	}
+	return n
+}
+
+func TestObtenerPilotos(t *testing.T) {
+	r, _ := routerPilotos(pilotosDePrueba()...)
+	casos := []struct {
+		ruta    string
+		esperan []string
+	}{
+		{"/api/pilotos", []string{"Max Verstappen", "Charles Leclerc", "Carlos Sainz"}},
+		{"/api/pilotos?equipo=Ferr", []string{"Charles Leclerc", "Carlos Sainz"}},
+		{"/api/pilotos?equipo=red%20bull", []string{"Max Verstappen"}},
+		{"/api/pilotos?nacionalidad=Español", []string{"Carlos Sainz"}},
+		{"/api/pilotos?equipo=Mercedes", nil},
+	}
+	for _, caso := range casos {
+		w := pedir(t, r, http.MethodGet, caso.ruta, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: código %d", caso.ruta, w.Code)
+		}
+		obtenidos := nombres(decodificar[[]models.Piloto](t, w))
+		if len(obtenidos) != len(caso.esperan) {
+			t.Fatalf("%s: %v, se esperaba %v", caso.ruta, obtenidos, caso.esperan)
+		}
+		for i := range obtenidos {
+			if obtenidos[i] != caso.esperan[i] {
+				t.Fatalf("%s: %v, se esperaba %v", caso.ruta, obtenidos, caso.esperan)
+			}
+		}
+	}
+}
+
+func TestObtenerPilotosVacioDevuelveLista(t *testing.T) {
+	r, _ := routerPilotos()
+	w := pedir(t, r, http.MethodGet, "/api/pilotos", nil)
+	if w.Code != http.StatusOK || w.Body.String() != "[]" {
+		t.Fatalf("código %d, cuerpo %s; se esperaba 200 y []", w.Code, w.Body.String())
+	}
+}
+
+func TestObtenerPiloto(t *testing.T) {
+	r, _ := routerPilotos(pilotosDePrueba()...)
+	casos := []struct {
+		ruta   string
+		codigo int
+	}{
+		{"/api/pilotos/2", http.StatusOK},
+		{"/api/pilotos/99", http.StatusNotFound},
+		{"/api/pilotos/abc", http.StatusNotFound},
+	}
+	for _, caso := range casos {
+		w := pedir(t, r, http.MethodGet, caso.ruta, nil)
+		if w.Code != caso.codigo {
+			t.Fatalf("%s: código %d, se esperaba %d", caso.ruta, w.Code, caso.codigo)
+		}
+	}
+
+	w := pedir(t, r, http.MethodGet, "/api/pilotos/2", nil)
+	if p := decodificar[models.Piloto](t, w); p.Nombre != "Charles Leclerc" || p.Numero != 16 {
+		t.Fatalf("piloto inesperado: %+v", p)
+	}
+}
+
+func TestCrearPiloto(t *testing.T) {
+	r, repo := routerPilotos(pilotosDePrueba()...)
+
+	w := pedir(t, r, http.MethodPost, "/api/pilotos", gin.H{"nombre": "Lando Norris", "equipo": "McLaren", "numero": 4, "puntos": 350.5})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("código %d: %s", w.Code, w.Body.String())
+	}
+	creado := decodificar[models.Piloto](t, w)
+	if creado.ID != 4 || creado.Nombre != "Lando Norris" {
+		t.Fatalf("piloto creado inesperado: %+v", creado)
+	}
+	if guardado, err := repo.Obtener(context.Background(), creado.ID); err != nil || guardado.Equipo != "McLaren" {
+		t.Fatalf("no se guardó el piloto: %+v, %v", guardado, err)
+	}
+}
+
+func TestCrearPilotoInvalido(t *testing.T) {
+	r, _ := routerPilotos(pilotosDePrueba()...)
+	casos := []struct {
+		nombre string
+		cuerpo any
+		codigo int
+	}{
+		{"sin nombre", gin.H{"equipo": "McLaren"}, http.StatusBadRequest},
+		{"sin equipo", gin.H{"nombre": "Lando Norris"}, http.StatusBadRequest},
+		{"tipo incorrecto", gin.H{"nombre": "Lando Norris", "equipo": "McLaren", "numero": "cuatro"}, http.StatusBadRequest},
+		{"nombre repetido", gin.H{"nombre": "Max Verstappen", "equipo": "Red Bull"}, http.StatusConflict},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			w := pedir(t, r, http.MethodPost, "/api/pilotos", caso.cuerpo)
+			if w.Code != caso.codigo {
+				t.Fatalf("código %d, se esperaba %d: %s", w.Code, caso.codigo, w.Body.String())
+			}
+			if decodificar[gin.H](t, w)["error"] == nil {
+				t.Fatalf("falta el campo error: %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestActualizarPiloto(t *testing.T) {
+	r, repo := routerPilotos(pilotosDePrueba()...)
+
+	// El ID del cuerpo se ignora: manda el de la ruta
+	w := pedir(t, r, http.MethodPut, "/api/pilotos/3", gin.H{"ID": 1, "nombre": "Carlos Sainz", "equipo": "Williams", "victorias": 4})
+	if w.Code != http.StatusOK {
+		t.Fatalf("código %d: %s", w.Code, w.Body.String())
+	}
+	actualizado, _ := repo.Obtener(context.Background(), 3)
+	if actualizado.Equipo != "Williams" || actualizado.Victorias != 4 || actualizado.Numero != 55 {
+		t.Fatalf("actualización inesperada: %+v", actualizado)
+	}
+	if original, _ := repo.Obtener(context.Background(), 1); original.Nombre != "Max Verstappen" {
+		t.Fatalf("se modificó otro piloto: %+v", original)
+	}
+
+	if w := pedir(t, r, http.MethodPut, "/api/pilotos/99", gin.H{"nombre": "X", "equipo": "Y"}); w.Code != http.StatusNotFound {
+		t.Fatalf("piloto inexistente: código %d", w.Code)
+	}
+	if w := pedir(t, r, http.MethodPut, "/api/pilotos/3", gin.H{"nombre": "Max Verstappen", "equipo": "Williams"}); w.Code != http.StatusConflict {
+		t.Fatalf("nombre repetido: código %d", w.Code)
+	}
+}
+
+func TestEliminarPiloto(t *testing.T) {
+	r, repo := routerPilotos(pilotosDePrueba()...)
+
+	if w := pedir(t, r, http.MethodDelete, "/api/pilotos/1", nil); w.Code != http.StatusOK {
+		t.Fatalf("código %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := repo.Obtener(context.Background(), 1); err != repositorios.ErrNoEncontrado {
+		t.Fatalf("el piloto sigue existiendo: %v", err)
+	}
+	if w := pedir(t, r, http.MethodDelete, "/api/pilotos/1", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("segunda eliminación: código %d", w.Code)
+	}
+}
+
+func TestEliminarPilotoLiberaElNombre(t *testing.T) {
+	r, _ := routerPilotos(pilotosDePrueba()...)
+
+	if w := pedir(t, r, http.MethodDelete, "/api/pilotos/1", nil); w.Code != http.StatusOK {
+		t.Fatalf("código %d: %s", w.Code, w.Body.String())
+	}
+	w := pedir(t, r, http.MethodPost, "/api/pilotos", gin.H{"nombre": "Max Verstappen", "equipo": "Red Bull"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("recrear el piloto eliminado: código %d: %s", w.Code, w.Body.String())
+	}
+	if p := decodificar[models.Piloto](t, w); p.ID == 1 {
+		t.Fatalf("se reutilizó el ID del piloto eliminado")
+	}
+}
+
+func TestObtenerEstadisticas(t *testing.T) {
+	r, _ := routerPilotos(pilotosDePrueba()...)
+	w := pedir(t, r, http.MethodGet, "/api/estadisticas", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("código %d", w.Code)
+	}
+	e := decodificar[repositorios.EstadisticasPilotos](t, w)
+	esperado := repositorios.EstadisticasPilotos{TotalPilotos: 3, TotalVictorias: 61, TotalPuntos: 2432, PilotoMasVictorias: "Max Verstappen", VictoriasPiloto: 54}
+	if e != esperado {
+		t.Fatalf("estadísticas %+v, se esperaba %+v", e, esperado)
+	}
+
+	r, _ = routerPilotos()
+	if e := decodificar[repositorios.EstadisticasPilotos](t, pedir(t, r, http.MethodGet, "/api/estadisticas", nil)); e != (repositorios.EstadisticasPilotos{}) {
+		t.Fatalf("sin pilotos: %+v", e)
+	}
+}
+
+func TestBuscarPorEquipo(t *testing.T) {
+	r, _ := routerPilotos(pilotosDePrueba()...)
+
+	if w := pedir(t, r, http.MethodGet, "/api/buscar", nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("sin equipo: código %d", w.Code)
+	}
+	w := pedir(t, r, http.MethodGet, "/api/buscar?equipo=Red", nil)
+	if obtenidos := nombres(decodificar[[]models.Piloto](t, w)); len(obtenidos) != 1 || obtenidos[0] != "Max Verstappen" {
+		t.Fatalf("búsqueda: %v", obtenidos)
+	}
+}
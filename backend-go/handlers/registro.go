@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"formula1-crud-go/registro"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// -------------------- Registro de pedidos --------------------
+
+// CabeceraIDPedido identifica un pedido en los logs del backend y en la
+// respuesta; un proxy o el cliente pueden mandar el suyo.
+const CabeceraIDPedido = "X-Request-ID"
+
+var (
+	registroHTTP = registro.Para(registro.HTTP)
+	registroWS   = registro.Para(registro.WS)
+)
+
+// IDPedido asigna un ID a cada pedido (el de X-Request-ID si es razonable)
+// y lo guarda en el contexto, para que lo lleven los logs de las consultas
+// SQL y de las simulaciones que el pedido inicie.
+func IDPedido() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(CabeceraIDPedido)
+		if !idValido(id) {
+			id = nuevoIDPedido()
+		}
+		c.Header(CabeceraIDPedido, id)
+		c.Request = c.Request.WithContext(registro.ConIDPedido(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// RegistrarPedidos registra cada pedido al terminar: como error las
+// respuestas 5xx, como warn las 4xx y como debug los healthchecks, que se
+// repiten cada pocos segundos.
+func RegistrarPedidos() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		inicio := time.Now()
+		c.Next()
+
+		estado := c.Writer.Status()
+		nivel := slog.LevelInfo
+		switch {
+		case estado >= http.StatusInternalServerError:
+			nivel = slog.LevelError
+		case estado >= http.StatusBadRequest:
+			nivel = slog.LevelWarn
+		case c.FullPath() == "/healthz" || c.FullPath() == "/readyz":
+			nivel = slog.LevelDebug
+		}
+		ctx := c.Request.Context()
+		if !registroHTTP.Enabled(ctx, nivel) {
+			return
+		}
+
+		attrs := []slog.Attr{
+			slog.String("metodo", c.Request.Method),
+			slog.String("ruta", c.FullPath()),
+			slog.String("camino", c.Request.URL.Path),
+			slog.Int("estado", estado),
+			slog.Float64("duracion_ms", float64(time.Since(inicio).Microseconds())/1000),
+			slog.Int("bytes", max(c.Writer.Size(), 0)),
+			slog.String("ip", c.ClientIP()),
+		}
+		if errores := c.Errors.String(); errores != "" {
+			attrs = append(attrs, slog.String("error", errores))
+		}
+		registroHTTP.LogAttrs(ctx, nivel, "pedido", attrs...)
+	}
+}
+
+// Recuperar responde 500 si un manejador entra en pánico y lo registra con
+// su pila, en lugar del texto que escribe gin.Recovery.
+func Recuperar() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, err any) {
+		registroHTTP.ErrorContext(c.Request.Context(), "pánico atendiendo el pedido",
+			"error", err, "pila", string(debug.Stack()))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Error interno del servidor"})
+	})
+}
+
+// idValido acepta IDs cortos de letras, números, guiones, puntos y guiones
+// bajos, para que un cliente no pueda ensuciar los logs.
+func idValido(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, r := range id {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+func nuevoIDPedido() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"bytes"
+	"formula1-crud-go/simulacion"
+	"math"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/metrics"
+	rtrace "runtime/trace"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// -------------------- Runtime de Go --------------------
+// Rutas para ver la concurrencia por dentro mientras corren simulaciones:
+// pprof, un resumen del runtime y la traza de runtime/trace de una corrida.
+// Van detrás de SoloAdmin.
+
+type ManejadorRuntime struct {
+	Planificador *simulacion.Planificador
+
+	// capturando permite una captura de traza a la vez: runtime/trace es
+	// uno solo para todo el proceso
+	capturando sync.Mutex
+}
+
+func NuevoManejadorRuntime(planificador *simulacion.Planificador) *ManejadorRuntime {
+	return &ManejadorRuntime{Planificador: planificador}
+}
+
+// metricasPlanificador son las muestras de runtime/metrics del resumen: el
+// planificador de goroutines, la CPU y la memoria. Se informan con su
+// nombre, que es el de la documentación del paquete runtime/metrics.
+var metricasPlanificador = []string{
+	"/sched/gomaxprocs:threads",
+	"/sched/goroutines:goroutines",
+	"/sched/latencies:seconds",
+	"/sched/pauses/total/gc:seconds",
+	"/sync/mutex/wait/total:seconds",
+	"/cpu/classes/user:cpu-seconds",
+	"/cpu/classes/gc/total:cpu-seconds",
+	"/cpu/classes/idle:cpu-seconds",
+	"/cpu/classes/total:cpu-seconds",
+	"/gc/cycles/total:gc-cycles",
+	"/gc/heap/goal:bytes",
+	"/memory/classes/heap/objects:bytes",
+	"/memory/classes/total:bytes",
+}
+
+// Resumen del runtime: procesadores, goroutines por simulación y las
+// estadísticas del planificador de Go
+func (m *ManejadorRuntime) ObtenerRuntime(c *gin.Context) {
+	porSimulacion := m.Planificador.GoroutinesPorSimulacion()
+	total := int64(runtime.NumGoroutine())
+	resto := total
+	for _, n := range porSimulacion {
+		resto -= n
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"version_go": runtime.Version(),
+		"gomaxprocs": runtime.GOMAXPROCS(0),
+		"cpus":       runtime.NumCPU(),
+		"goroutines": gin.H{
+			"total":          total,
+			"por_simulacion": porSimulacion,
+			// Servidor HTTP, conexiones, trabajadores libres, monitor, ...
+			"resto": max(resto, 0),
+		},
+		"metricas": leerMetricas(metricasPlanificador),
+	})
+}
+
+// leerMetricas lee las muestras de runtime/metrics. Los histogramas (como
+// la latencia del planificador: cuánto espera una goroutine lista hasta
+// correr) se resumen en percentiles; las que esta versión de Go no tiene se
+// omiten.
+func leerMetricas(nombres []string) map[string]any {
+	muestras := make([]metrics.Sample, len(nombres))
+	for i, nombre := range nombres {
+		muestras[i].Name = nombre
+	}
+	metrics.Read(muestras)
+
+	valores := make(map[string]any, len(muestras))
+	for _, muestra := range muestras {
+		switch muestra.Value.Kind() {
+		case metrics.KindUint64:
+			valores[muestra.Name] = muestra.Value.Uint64()
+		case metrics.KindFloat64:
+			valores[muestra.Name] = muestra.Value.Float64()
+		case metrics.KindFloat64Histogram:
+			h := muestra.Value.Float64Histogram()
+			valores[muestra.Name] = gin.H{
+				"p50": percentil(h, 0.50),
+				"p90": percentil(h, 0.90),
+				"p99": percentil(h, 0.99),
+				"max": percentil(h, 1),
+			}
+		}
+	}
+	return valores
+}
+
+// percentil devuelve el límite superior del bucket donde cae el percentil p
+// (el inferior si el superior es infinito), o 0 sin observaciones.
+func percentil(h *metrics.Float64Histogram, p float64) float64 {
+	var total uint64
+	for _, n := range h.Counts {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	objetivo := uint64(math.Ceil(p * float64(total)))
+	var acumulado uint64
+	for i, n := range h.Counts {
+		acumulado += n
+		if acumulado >= objetivo {
+			if limite := h.Buckets[i+1]; !math.IsInf(limite, 1) {
+				return limite
+			}
+			return h.Buckets[i]
+		}
+	}
+	return h.Buckets[len(h.Buckets)-1]
+}
+
+// Correr una simulación capturando su traza de runtime/trace; responde el
+// archivo para go tool trace cuando termina. Si el cliente se va, la
+// simulación se cancela.
+func (m *ManejadorRuntime) CapturarTraza(c *gin.Context) {
+	var solicitud solicitudSimulacion
+	if err := c.ShouldBindJSON(&solicitud); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !m.capturando.TryLock() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Ya hay una captura de traza en curso"})
+		return
+	}
+	defer m.capturando.Unlock()
+	if rtrace.IsEnabled() {
+		c.JSON(http.StatusConflict, gin.H{"error": "runtime/trace ya está activo (¿/debug/pprof/trace?)"})
+		return
+	}
+
+	var traza bytes.Buffer
+	captura := &simulacion.CapturaTraza{Salida: &traza}
+	sol := simulacion.Solicitud{
+		Cliente:  c.ClientIP(),
+		Topico:   solicitud.Topico,
+		Autos:    solicitud.Autos,
+		Sectores: solicitud.Sectores,
+		Vueltas:  solicitud.Vueltas,
+		Traza:    captura,
+	}
+	if sol.Topico == "mpi" {
+		sol.Autos = 0
+	} else {
+		sol.Sectores = 0
+	}
+
+	sim, err := m.Planificador.Encolar(c.Request.Context(), sol, nil)
+	if err != nil {
+		c.JSON(estadoDeEncolar(err), gin.H{"error": err.Error()})
+		return
+	}
+	<-sim.Terminada()
+
+	switch {
+	case captura.Err != nil:
+		c.JSON(http.StatusConflict, gin.H{"error": "No se pudo capturar la traza: " + captura.Err.Error()})
+	case traza.Len() == 0:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "La simulación se canceló antes de correr"})
+	default:
+		c.Header("Content-Disposition", `attachment; filename="`+sim.ID+`.trace"`)
+		c.Header("X-Simulacion-ID", sim.ID)
+		c.Data(http.StatusOK, "application/octet-stream", traza.Bytes())
+	}
+}
+
+// Perfiles de net/http/pprof bajo /debug/pprof/ (ruta /*perfil)
+func Pprof(c *gin.Context) {
+	switch c.Param("perfil") {
+	case "/cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "/profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "/symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "/trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		// El índice y los perfiles por nombre: heap, goroutine, mutex, ...
+		pprof.Index(c.Writer, c.Request)
+	}
+}
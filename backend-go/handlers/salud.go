@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"formula1-crud-go/database"
+	"formula1-crud-go/migraciones"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Tiempo máximo de las comprobaciones de /readyz
+const esperaListo = 2 * time.Second
+
+type ManejadorSalud struct {
+	Monitor  *database.Monitor
+	Migrador *migraciones.Migrador
+}
+
+func NuevoManejadorSalud(monitor *database.Monitor, migrador *migraciones.Migrador) *ManejadorSalud {
+	return &ManejadorSalud{Monitor: monitor, Migrador: migrador}
+}
+
+// Vivo (liveness) responde mientras el proceso atienda pedidos; no mira la
+// base, para que el orquestador no reinicie el backend cuando la caída es
+// de PostgreSQL.
+func (m *ManejadorSalud) Vivo(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"estado": "ok"})
+}
+
+// Listo (readiness) comprueba que la base responde y que no quedan
+// migraciones pendientes; si algo falla responde 503 con el motivo.
+func (m *ManejadorSalud) Listo(c *gin.Context) {
+	ctx, cancelar := context.WithTimeout(c.Request.Context(), esperaListo)
+	defer cancelar()
+
+	comprobaciones := gin.H{"base_de_datos": "ok", "migraciones": "ok"}
+	listo := true
+	if err := m.Monitor.Comprobar(ctx); err != nil {
+		comprobaciones["base_de_datos"] = err.Error()
+		comprobaciones["migraciones"] = "sin comprobar"
+		listo = false
+	} else if pendientes, err := m.Migrador.Pendientes(ctx); err != nil {
+		comprobaciones["migraciones"] = err.Error()
+		listo = false
+	} else if pendientes > 0 {
+		comprobaciones["migraciones"] = fmt.Sprintf("%d pendientes (última conocida: %d)", pendientes, m.Migrador.Ultima())
+		listo = false
+	}
+
+	if !listo {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"estado": "no_listo", "comprobaciones": comprobaciones})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"estado": "listo", "comprobaciones": comprobaciones})
+}
+
+// RequiereBaseDeDatos corta con 503 las rutas que consultan la base mientras
+// el monitor la vea caída. Si una consulta falla con la base supuestamente
+// disponible, pide una comprobación para que los siguientes pedidos ya
+// reciban el 503.
+func (m *ManejadorSalud) RequiereBaseDeDatos() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := m.Monitor.Disponible(); err != nil {
+			c.Header("Retry-After", "5")
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+				"error":   "La base de datos no está disponible en este momento; intente de nuevo en unos segundos",
+				"detalle": err.Error(),
+			})
+			return
+		}
+		c.Next()
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			m.Monitor.Revisar()
+		}
+	}
+}
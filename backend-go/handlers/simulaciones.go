@@ -0,0 +1,419 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"formula1-crud-go/config"
+	"formula1-crud-go/simulacion"
+	"formula1-crud-go/telemetria"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
+)
+
+// FuenteTelemetria carga las muestras guardadas de una sesión para
+// reproducirlas por WebSocket.
+type FuenteTelemetria interface {
+	CargarTelemetria(ctx context.Context, sesionID uint) ([]telemetria.Muestra, []telemetria.Canal, error)
+}
+
+type ManejadorSimulaciones struct {
+	Planificador *simulacion.Planificador
+	Hub          *simulacion.Hub
+	Config       config.WebSocket
+	Telemetria   FuenteTelemetria
+
+	// Los navegadores solo abren el WebSocket desde el mismo origen o desde
+	// los permitidos por CORS
+	actualizador websocket.Upgrader
+
+	// Conexiones WebSocket y SSE abiertas, para cerrarlas al apagar
+	mu         sync.Mutex
+	apagando   bool
+	apagado    chan struct{}
+	conexiones sync.WaitGroup
+}
+
+func NuevoManejadorSimulaciones(planificador *simulacion.Planificador, hub *simulacion.Hub, ws config.WebSocket, cors config.CORS, fuente FuenteTelemetria) *ManejadorSimulaciones {
+	return &ManejadorSimulaciones{
+		Planificador: planificador,
+		Hub:          hub,
+		Config:       ws,
+		Telemetria:   fuente,
+		actualizador: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool {
+				return mismoOrigen(r) || origenPermitido(cors, r.Header.Get("Origin"))
+			},
+		},
+		apagado: make(chan struct{}),
+	}
+}
+
+// Apagar avisa a cada cliente WebSocket y SSE con un mensaje
+// servidor_apagandose (después de lo que ya tuviera en cola, como el
+// "cancelado" de sus simulaciones), cierra las conexiones y espera a que
+// terminen o a que venza ctx. http.Server.Shutdown no sirve para esto: no
+// ve los WebSockets y esperaría a los streams SSE hasta el timeout.
+func (m *ManejadorSimulaciones) Apagar(ctx context.Context) error {
+	m.mu.Lock()
+	if !m.apagando {
+		m.apagando = true
+		close(m.apagado)
+	}
+	m.mu.Unlock()
+
+	listo := make(chan struct{})
+	go func() {
+		m.conexiones.Wait()
+		close(listo)
+	}()
+	select {
+	case <-listo:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// abrirConexion registra una conexión larga; devuelve false si el servidor
+// ya se está apagando.
+func (m *ManejadorSimulaciones) abrirConexion() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.apagando {
+		return false
+	}
+	m.conexiones.Add(1)
+	return true
+}
+
+// avisarApagado entrega el mensaje servidor_apagandose a la suscripción
+// cuando empieza el apagado; termina con ctx.
+func (m *ManejadorSimulaciones) avisarApagado(ctx context.Context, suscripcion *simulacion.Suscripcion) {
+	select {
+	case <-m.apagado:
+		suscripcion.Entregar(simulacion.MensajeWS{Tipo: simulacion.TipoServidorApagandose, Texto: "El servidor se está apagando; vuelva a conectarse en unos segundos"})
+	case <-ctx.Done():
+	}
+}
+
+func respuestaApagando(c *gin.Context) {
+	c.Header("Retry-After", "5")
+	c.JSON(http.StatusServiceUnavailable, gin.H{"error": "El servidor se está apagando"})
+}
+
+// Ruta donde se publica el JSON Schema del protocolo
+const rutaSchemaProtocolo = "/api/protocolo/schema"
+
+// JSON Schema de los comandos y mensajes del WebSocket
+func (m *ManejadorSimulaciones) ObtenerSchemaProtocolo(c *gin.Context) {
+	c.Data(http.StatusOK, "application/schema+json", simulacion.SchemaProtocolo)
+}
+
+// Estado de la cola de simulaciones
+func (m *ManejadorSimulaciones) ObtenerCola(c *gin.Context) {
+	c.JSON(http.StatusOK, m.Planificador.Estado())
+}
+
+// WebSocket atiende una conexión que inicia, controla o mira simulaciones
+func (m *ManejadorSimulaciones) WebSocket(c *gin.Context) {
+	if !m.abrirConexion() {
+		respuestaApagando(c)
+		return
+	}
+	defer m.conexiones.Done()
+
+	conn, err := m.actualizador.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		registroWS.WarnContext(c.Request.Context(), "no se pudo abrir el websocket", "error", err, "ip", c.ClientIP())
+		return
+	}
+	defer conn.Close()
+	conexionesAbiertas.WithLabelValues(transporteWS).Inc()
+	defer conexionesAbiertas.WithLabelValues(transporteWS).Dec()
+
+	ctx, cancelar := context.WithCancel(c.Request.Context())
+	defer cancelar()
+
+	// Un cliente que no responde los pings se da por desconectado
+	conn.SetReadLimit(m.Config.MaxTamanoMensaje)
+	conn.SetReadDeadline(time.Now().Add(m.Config.EsperaPong))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(m.Config.EsperaPong))
+	})
+
+	// Cada conexión tiene su propia cola: un cliente lento no frena a los demás
+	suscripcion := m.Hub.NuevaSuscripcion(m.Config.PoliticaDesborde)
+	defer m.Hub.Cerrar(suscripcion)
+
+	go m.escribir(ctx, cancelar, conn, suscripcion, c.ClientIP())
+	go m.enviarPings(ctx, cancelar, conn)
+	go m.avisarApagado(ctx, suscripcion)
+
+	// Las simulaciones propias no dependen de la conexión: si se corta, se
+	// les da un tiempo de gracia para que el cliente vuelva y las retome
+	propias := make(map[string]simulacionPropia)
+	defer func() {
+		for _, propia := range propias {
+			propia.Abandonar(propia.dueno, m.Config.GraciaReconexion)
+		}
+	}()
+
+	responder := func(msg simulacion.MensajeWS) {
+		suscripcion.Entregar(msg)
+	}
+
+	// Lo primero que recibe el cliente es la versión y lo que soporta el servidor
+	responder(simulacion.MensajeWS{
+		Tipo: simulacion.TipoHello,
+		Obj:  simulacion.NuevasCapacidades(m.Planificador.Limites(), rutaSchemaProtocolo),
+	})
+
+	for {
+		_, datos, err := conn.ReadMessage()
+		if err != nil {
+			registroWS.DebugContext(ctx, "websocket cerrado", "motivo", err, "ip", c.ClientIP())
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(m.Config.EsperaPong))
+
+		comando, errProtocolo := simulacion.DecodificarComando(datos)
+		if errProtocolo == nil {
+			errProtocolo = m.ejecutarComando(ctx, c.ClientIP(), comando, suscripcion, propias)
+		}
+		if errProtocolo != nil {
+			responder(errProtocolo.Mensaje())
+		}
+
+		// Olvidar las simulaciones que ya terminaron
+		for id, propia := range propias {
+			if propia.Finalizada() {
+				delete(propias, id)
+			}
+		}
+	}
+}
+
+// escribir vuelca la cola de la suscripción en la conexión. Ante cualquier
+// error cierra la conexión, lo que también destraba la lectura.
+func (m *ManejadorSimulaciones) escribir(ctx context.Context, cancelar context.CancelFunc, conn *websocket.Conn, suscripcion *simulacion.Suscripcion, cliente string) {
+	defer cancelar()
+	defer conn.Close()
+
+	for {
+		msgs, err := suscripcion.Recibir(ctx)
+		if errors.Is(err, simulacion.ErrSuscriptorLento) {
+			registroWS.WarnContext(ctx, "cerrando websocket de cliente lento", "ip", cliente)
+			cierre := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "cliente lento")
+			conn.WriteControl(websocket.CloseMessage, cierre, time.Now().Add(m.Config.EsperaEscritura))
+			return
+		}
+		if err != nil {
+			return
+		}
+		for _, msg := range msgs {
+			conn.SetWriteDeadline(time.Now().Add(m.Config.EsperaEscritura))
+			if err := conn.WriteJSON(msg); err != nil {
+				registroWS.DebugContext(ctx, "error escribiendo en el websocket", "error", err, "ip", cliente)
+				return
+			}
+			mensajesEnviados.WithLabelValues(transporteWS, msg.Tipo).Inc()
+			if msg.Tipo == simulacion.TipoServidorApagandose {
+				cierre := websocket.FormatCloseMessage(websocket.CloseGoingAway, "servidor apagándose")
+				conn.WriteControl(websocket.CloseMessage, cierre, time.Now().Add(m.Config.EsperaEscritura))
+				return
+			}
+		}
+	}
+}
+
+func (m *ManejadorSimulaciones) enviarPings(ctx context.Context, cancelar context.CancelFunc, conn *websocket.Conn) {
+	ticker := time.NewTicker(m.Config.PeriodoPing)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(m.Config.EsperaEscritura)); err != nil {
+				cancelar()
+				conn.Close()
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// ejecutarComando atiende un comando ya validado. Si el comando se acepta,
+// el cliente recibe un mensaje "aceptado" con su request_id. Las
+// simulaciones no se cancelan con ctx (la conexión) pero llevan su ID de
+// pedido en los logs.
+func (m *ManejadorSimulaciones) ejecutarComando(ctx context.Context, cliente string, comando any, suscripcion *simulacion.Suscripcion, propias map[string]simulacionPropia) *simulacion.ErrorProtocolo {
+	aceptado := simulacion.MensajeWS{Tipo: simulacion.TipoAceptado}
+
+	switch cmd := comando.(type) {
+	case simulacion.ComandoHello:
+		aceptado.RequestID = cmd.RequestID
+		aceptado.Obj = map[string]any{"version": cmd.Version}
+	case simulacion.ComandoIniciarMPI:
+		sol := simulacion.Solicitud{Cliente: cliente, Topico: "mpi", Sectores: cmd.Sectores, Vueltas: cmd.Vueltas}
+		sim, err := m.Planificador.Encolar(context.WithoutCancel(ctx), sol, suscripcion)
+		if err != nil {
+			return simulacion.ErrorDeEncolar(err, cmd.RequestID)
+		}
+		adoptar(propias, sim, sim.Token)
+		aceptado.RequestID, aceptado.Topico, aceptado.SimulacionID = cmd.RequestID, sim.Topico, sim.ID
+		aceptado.Obj = map[string]any{"token": sim.Token}
+	case simulacion.ComandoIniciarOpenMP:
+		sol := simulacion.Solicitud{Cliente: cliente, Topico: "openmp", Autos: cmd.Autos, Vueltas: cmd.Vueltas}
+		sim, err := m.Planificador.Encolar(context.WithoutCancel(ctx), sol, suscripcion)
+		if err != nil {
+			return simulacion.ErrorDeEncolar(err, cmd.RequestID)
+		}
+		adoptar(propias, sim, sim.Token)
+		aceptado.RequestID, aceptado.Topico, aceptado.SimulacionID = cmd.RequestID, sim.Topico, sim.ID
+		aceptado.Obj = map[string]any{"token": sim.Token}
+	case simulacion.ComandoReproducir:
+		muestras, canales, err := m.Telemetria.CargarTelemetria(ctx, cmd.SesionID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return simulacion.NuevoError(simulacion.CodigoNoEncontrada, cmd.RequestID, "Sesión de telemetría no encontrada: %d", cmd.SesionID)
+		}
+		if err != nil {
+			registroWS.ErrorContext(ctx, "no se pudo cargar la sesión a reproducir", "sesion_id", cmd.SesionID, "error", err)
+			return simulacion.NuevoError(simulacion.CodigoErrorInterno, cmd.RequestID, "No se pudo cargar la sesión %d", cmd.SesionID)
+		}
+		repeticion, err := simulacion.NuevaRepeticion(cmd.SesionID, muestras, canales, cmd.Velocidad, cmd.Desde)
+		if err != nil {
+			return simulacion.NuevoError(simulacion.CodigoCampoInvalido, cmd.RequestID, "%v", err)
+		}
+		sol := simulacion.Solicitud{Cliente: cliente, Topico: "telemetria", Repeticion: repeticion}
+		sim, err := m.Planificador.Encolar(context.WithoutCancel(ctx), sol, suscripcion)
+		if err != nil {
+			return simulacion.ErrorDeEncolar(err, cmd.RequestID)
+		}
+		adoptar(propias, sim, sim.Token)
+		aceptado.RequestID, aceptado.Topico, aceptado.SimulacionID = cmd.RequestID, sim.Topico, sim.ID
+		aceptado.Obj = map[string]any{
+			"token":     sim.Token,
+			"sesion_id": cmd.SesionID,
+			"muestras":  repeticion.Muestras(),
+			"duracion":  repeticion.Duracion(),
+			"canales":   canales,
+			"velocidad": cmd.Velocidad,
+		}
+	case simulacion.ComandoVelocidad:
+		sim, errProtocolo := repeticionPropia(propias, cmd.SimulacionID, cmd.RequestID)
+		if errProtocolo != nil {
+			return errProtocolo
+		}
+		if err := sim.Repeticion.CambiarVelocidad(cmd.Velocidad); err != nil {
+			return simulacion.NuevoError(simulacion.CodigoCampoInvalido, cmd.RequestID, "%v", err)
+		}
+		aceptado.RequestID, aceptado.Topico, aceptado.SimulacionID = cmd.RequestID, sim.Topico, sim.ID
+		aceptado.Obj = map[string]any{"velocidad": cmd.Velocidad}
+	case simulacion.ComandoSaltar:
+		sim, errProtocolo := repeticionPropia(propias, cmd.SimulacionID, cmd.RequestID)
+		if errProtocolo != nil {
+			return errProtocolo
+		}
+		sim.Repeticion.Saltar(cmd.Tiempo)
+		aceptado.RequestID, aceptado.Topico, aceptado.SimulacionID = cmd.RequestID, sim.Topico, sim.ID
+	case simulacion.ComandoSuscripcion:
+		aceptado.RequestID, aceptado.Topico, aceptado.SimulacionID = cmd.RequestID, cmd.Topico, cmd.SimulacionID
+		switch {
+		case cmd.Action == simulacion.AccionSuscribir && cmd.Topico != "":
+			m.Hub.SuscribirTopico(suscripcion, cmd.Topico)
+		case cmd.Action == simulacion.AccionSuscribir:
+			if err := m.Hub.Suscribir(suscripcion, cmd.SimulacionID); err != nil {
+				return simulacion.NuevoError(simulacion.CodigoNoEncontrada, cmd.RequestID, "No se pudo suscribir a %q: %v", cmd.SimulacionID, err)
+			}
+		case cmd.Topico != "":
+			m.Hub.DesuscribirTopico(suscripcion, cmd.Topico)
+		default:
+			m.Hub.Desuscribir(suscripcion, cmd.SimulacionID)
+		}
+	case simulacion.ComandoRetomar:
+		// Con el token se recupera el control antes de volver a mirar
+		if cmd.Token != "" {
+			sim, ok := m.Planificador.Buscar(cmd.SimulacionID)
+			if !ok {
+				return simulacion.NuevoError(simulacion.CodigoNoEncontrada, cmd.RequestID, "Simulación no encontrada o finalizada: %q", cmd.SimulacionID)
+			}
+			if err := adoptar(propias, sim, cmd.Token); err != nil {
+				return simulacion.NuevoError(simulacion.CodigoTokenInvalido, cmd.RequestID, "%v", err)
+			}
+		}
+		if err := m.Hub.SuscribirDesde(suscripcion, cmd.SimulacionID, cmd.UltimoSeq); err != nil {
+			return simulacion.NuevoError(simulacion.CodigoNoEncontrada, cmd.RequestID, "No se pudo retomar %q: %v", cmd.SimulacionID, err)
+		}
+		aceptado.RequestID, aceptado.SimulacionID = cmd.RequestID, cmd.SimulacionID
+	case simulacion.ComandoControl:
+		propia, ok := propias[cmd.SimulacionID]
+		sim := propia.Simulacion
+		if !ok || sim.Finalizada() {
+			return simulacion.NuevoError(simulacion.CodigoNoEncontrada, cmd.RequestID, "Simulación no encontrada o finalizada: %q", cmd.SimulacionID)
+		}
+		if err := controlarSimulacion(sim, cmd.Action); err != nil {
+			return simulacion.NuevoError(simulacion.CodigoEstadoInvalido, cmd.RequestID, "%v", err)
+		}
+		aceptado.RequestID, aceptado.Topico, aceptado.SimulacionID = cmd.RequestID, sim.Topico, sim.ID
+	}
+
+	suscripcion.Entregar(aceptado)
+	return nil
+}
+
+// simulacionPropia es una simulación que controla esta conexión, con el
+// Dueno de su adopción para abandonarla al cerrarse.
+type simulacionPropia struct {
+	*simulacion.Simulacion
+	dueno simulacion.Dueno
+}
+
+// adoptar toma el control de sim con token y la suma a las propias de la
+// conexión; quien la crea la adopta con su propio token.
+func adoptar(propias map[string]simulacionPropia, sim *simulacion.Simulacion, token string) error {
+	dueno, err := sim.Adoptar(token)
+	if err != nil {
+		return err
+	}
+	propias[sim.ID] = simulacionPropia{Simulacion: sim, dueno: dueno}
+	return nil
+}
+
+// repeticionPropia busca una reproducción de telemetría que controle este cliente.
+func repeticionPropia(propias map[string]simulacionPropia, id, requestID string) (*simulacion.Simulacion, *simulacion.ErrorProtocolo) {
+	propia, ok := propias[id]
+	sim := propia.Simulacion
+	if !ok || sim.Finalizada() {
+		return nil, simulacion.NuevoError(simulacion.CodigoNoEncontrada, requestID, "Simulación no encontrada o finalizada: %q", id)
+	}
+	if sim.Repeticion == nil {
+		return nil, simulacion.NuevoError(simulacion.CodigoEstadoInvalido, requestID, "%v", simulacion.ErrNoEsRepeticion)
+	}
+	return sim, nil
+}
+
+func controlarSimulacion(sim *simulacion.Simulacion, accion string) error {
+	switch accion {
+	case simulacion.AccionCancelar:
+		// El mensaje "cancelado" lo envía la propia simulación al detenerse
+		sim.Cancelar()
+	case simulacion.AccionPausar:
+		if err := sim.Pausar(); err != nil {
+			return err
+		}
+		sim.Avisar(simulacion.MensajeWS{Tipo: simulacion.TipoPausado})
+	case simulacion.AccionReanudar:
+		if err := sim.Reanudar(); err != nil {
+			return err
+		}
+		sim.Avisar(simulacion.MensajeWS{Tipo: simulacion.TipoReanudado})
+	}
+	return nil
+}
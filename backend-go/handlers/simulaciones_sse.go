@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"formula1-crud-go/simulacion"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// -------------------- REST + Server-Sent Events --------------------
+// Alternativa a /ws para redes y proxies que bloquean WebSocket: las
+// simulaciones se inician con POST y sus mensajes se leen como SSE.
+
+type solicitudSimulacion struct {
+	Topico   string `json:"topico" binding:"required,oneof=mpi openmp"`
+	Autos    int    `json:"autos" binding:"required_if=Topico openmp,omitempty,min=1"`
+	Sectores int    `json:"sectores" binding:"required_if=Topico mpi,omitempty,min=1"`
+	Vueltas  int    `json:"vueltas" binding:"required,min=1"`
+}
+
+type solicitudControl struct {
+	Accion string `json:"accion" binding:"required,oneof=cancelar pausar reanudar"`
+	Token  string `json:"token" binding:"required"`
+}
+
+// Iniciar una simulación sin WebSocket
+func (m *ManejadorSimulaciones) CrearSimulacion(c *gin.Context) {
+	var solicitud solicitudSimulacion
+	if err := c.ShouldBindJSON(&solicitud); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sol := simulacion.Solicitud{
+		Cliente:  c.ClientIP(),
+		Topico:   solicitud.Topico,
+		Autos:    solicitud.Autos,
+		Sectores: solicitud.Sectores,
+		Vueltas:  solicitud.Vueltas,
+	}
+	if sol.Topico == "mpi" {
+		sol.Autos = 0
+	} else {
+		sol.Sectores = 0
+	}
+
+	sim, err := m.Planificador.Encolar(context.WithoutCancel(c.Request.Context()), sol, nil)
+	if err != nil {
+		c.JSON(estadoDeEncolar(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	stream := "/api/simulaciones/" + sim.ID + "/stream"
+	c.Header("Location", stream)
+	c.JSON(http.StatusAccepted, gin.H{
+		"simulacion_id": sim.ID,
+		"topico":        sim.Topico,
+		"token":         sim.Token,
+		"stream":        stream,
+	})
+}
+
+// Cancelar, pausar o reanudar una simulación con el token recibido al crearla
+func (m *ManejadorSimulaciones) ControlarSimulacion(c *gin.Context) {
+	var solicitud solicitudControl
+	if err := c.ShouldBindJSON(&solicitud); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sim, ok := m.Planificador.Buscar(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Simulación no encontrada o finalizada"})
+		return
+	}
+	// Con el token se adopta: si una conexión la había abandonado, ya no se
+	// cancela
+	if _, err := sim.Adoptar(solicitud.Token); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err := controlarSimulacion(sim, solicitud.Accion); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"simulacion_id": sim.ID, "accion": solicitud.Accion})
+}
+
+// Stream SSE de una simulación. El id de cada evento es su seq: al
+// reconectarse, el navegador lo manda en Last-Event-ID y solo recibe lo que
+// se perdió. La primera conexión puede usar ?ultimo_seq= con el mismo fin.
+func (m *ManejadorSimulaciones) StreamSimulacion(c *gin.Context) {
+	id := c.Param("id")
+	desde, err := ultimoSeqVisto(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !m.abrirConexion() {
+		respuestaApagando(c)
+		return
+	}
+	defer m.conexiones.Done()
+
+	// Con 204 EventSource deja de reconectarse
+	if m.Hub.Completa(id, desde) {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	suscripcion := m.Hub.NuevaSuscripcion(m.Config.PoliticaDesborde)
+	defer m.Hub.Cerrar(suscripcion)
+	if err := m.Hub.SuscribirDesde(suscripcion, id, desde); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Simulación no encontrada"})
+		return
+	}
+
+	c.Header("Content-Type", sse.ContentType)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+	conexionesAbiertas.WithLabelValues(transporteSSE).Inc()
+	defer conexionesAbiertas.WithLabelValues(transporteSSE).Dec()
+
+	ctx, cancelar := context.WithCancel(c.Request.Context())
+	defer cancelar()
+	controlador := http.NewResponseController(c.Writer)
+	go m.avisarApagado(ctx, suscripcion)
+
+	// Comentarios periódicos para que los proxies no corten la conexión
+	go func() {
+		ticker := time.NewTicker(m.Config.PeriodoPing)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				suscripcion.Entregar(simulacion.MensajeWS{})
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for {
+		msgs, err := suscripcion.Recibir(ctx)
+		if errors.Is(err, simulacion.ErrSuscriptorLento) {
+			registroWS.WarnContext(ctx, "cerrando stream SSE de cliente lento", "ip", c.ClientIP())
+			return
+		}
+		if err != nil {
+			return
+		}
+
+		controlador.SetWriteDeadline(time.Now().Add(m.Config.EsperaEscritura))
+		for _, msg := range msgs {
+			if err := escribirEvento(c, msg); err != nil {
+				return
+			}
+			if msg.Tipo != "" {
+				mensajesEnviados.WithLabelValues(transporteSSE, msg.Tipo).Inc()
+			}
+		}
+		if err := controlador.Flush(); err != nil {
+			return
+		}
+
+		for _, msg := range msgs {
+			if (msg.SimulacionID == id && simulacion.EsFinal(msg)) || msg.Tipo == simulacion.TipoServidorApagandose {
+				return
+			}
+		}
+	}
+}
+
+// escribirEvento serializa un mensaje como evento SSE; un mensaje vacío es
+// solo un comentario de keepalive.
+func escribirEvento(c *gin.Context, msg simulacion.MensajeWS) error {
+	if msg.Tipo == "" {
+		_, err := c.Writer.WriteString(": ping\n\n")
+		return err
+	}
+	evento := sse.Event{Data: msg}
+	if msg.Seq > 0 {
+		evento.Id = strconv.FormatUint(msg.Seq, 10)
+	}
+	return sse.Encode(c.Writer, evento)
+}
+
+func ultimoSeqVisto(c *gin.Context) (uint64, error) {
+	valor := c.GetHeader("Last-Event-ID")
+	if valor == "" {
+		valor = c.Query("ultimo_seq")
+	}
+	if valor == "" {
+		return 0, nil
+	}
+	seq, err := strconv.ParseUint(valor, 10, 64)
+	if err != nil {
+		return 0, errors.New("Last-Event-ID inválido: " + valor)
+	}
+	return seq, nil
+}
+
+// estadoDeEncolar traduce los errores del planificador a códigos HTTP.
+func estadoDeEncolar(err error) int {
+	switch {
+	case errors.Is(err, simulacion.ErrColaLlena), errors.Is(err, simulacion.ErrApagando):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, simulacion.ErrLimiteCliente):
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusUnprocessableEntity
+	}
+}
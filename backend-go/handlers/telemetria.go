@@ -0,0 +1,817 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"formula1-crud-go/models"
+	"formula1-crud-go/telemetria"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// Errores por fila que se devuelven al subir un archivo
+	maxErroresInformados = 100
+	// Anomalías que se devuelven al analizar un archivo
+	maxAnomaliasInformadas = 1000
+	// Muestras que se insertan por sentencia
+	loteMuestras = 1000
+	// Máximo de muestras por página al consultarlas
+	maxMuestrasPorPagina = 10000
+	// Límites del generador de telemetría sintética
+	maxVueltasGenerador    = 50
+	maxFrecuenciaGenerador = 100
+)
+
+type ManejadorTelemetria struct {
+	DB *gorm.DB
+}
+
+func NuevoManejadorTelemetria(db *gorm.DB) *ManejadorTelemetria {
+	return &ManejadorTelemetria{DB: db}
+}
+
+// db lleva el contexto del pedido (y su ID, para los logs) a las consultas.
+func (m *ManejadorTelemetria) db(c *gin.Context) *gorm.DB {
+	return m.DB.WithContext(c.Request.Context())
+}
+
+// Canales reconocidos, con sus alias y rangos válidos
+func (m *ManejadorTelemetria) ObtenerCanales(c *gin.Context) {
+	c.JSON(http.StatusOK, telemetria.Esquema)
+}
+
+// Subir un archivo CSV o JSON Lines como sesión de telemetría de un piloto.
+// Campos del formulario: archivo, piloto_id, evento, temporada, circuito,
+// sesion y formato (opcional, se deduce de la extensión).
+func (m *ManejadorTelemetria) SubirSesion(c *gin.Context) {
+	archivo, cabecera, err := c.Request.FormFile("archivo")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Falta el archivo de telemetría: " + err.Error()})
+		return
+	}
+	defer archivo.Close()
+
+	pilotoID, err := strconv.ParseUint(c.PostForm("piloto_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "piloto_id inválido"})
+		return
+	}
+	nombreEvento := strings.TrimSpace(c.PostForm("evento"))
+	if nombreEvento == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "evento es obligatorio"})
+		return
+	}
+	temporada, _ := strconv.Atoi(c.PostForm("temporada"))
+
+	formato := telemetria.DetectarFormato(cabecera.Filename)
+	if nombre := c.PostForm("formato"); nombre != "" {
+		if formato, err = telemetria.ParseFormato(nombre); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var piloto models.Piloto
+	if err := m.db(c).First(&piloto, pilotoID).Error; err != nil {
+		responderErrorBusqueda(c, err, "Piloto no encontrado")
+		return
+	}
+
+	lector, err := telemetria.NuevoLector(archivo, formato)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	sesion := models.SesionTelemetria{
+		PilotoID: piloto.ID,
+		Sesion:   c.PostForm("sesion"),
+		Archivo:  cabecera.Filename,
+		Formato:  string(formato),
+		Canales:  unirCanales(lector.Canales()),
+	}
+	informe := telemetria.NuevoInformeErrores(maxErroresInformados)
+
+	// Las muestras se insertan por lotes a medida que se leen
+	err = m.db(c).Transaction(func(tx *gorm.DB) error {
+		evento := models.Evento{Nombre: nombreEvento, Temporada: temporada}
+		err := tx.Where(models.Evento{Nombre: nombreEvento, Temporada: temporada}).
+			Attrs(models.Evento{Circuito: c.PostForm("circuito")}).
+			FirstOrCreate(&evento).Error
+		if err != nil {
+			return err
+		}
+		sesion.EventoID = evento.ID
+		if err := tx.Create(&sesion).Error; err != nil {
+			return err
+		}
+
+		lote := make([]models.MuestraTelemetria, 0, loteMuestras)
+		for {
+			muestra, err := lector.Leer()
+			if err == io.EOF {
+				break
+			}
+			var errFila *telemetria.ErrorFila
+			if errors.As(err, &errFila) {
+				informe.Agregar(*errFila)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			lote = append(lote, models.MuestraTelemetria{SesionID: sesion.ID, Indice: sesion.Muestras, Muestra: muestra})
+			sesion.Muestras++
+			if len(lote) == loteMuestras {
+				if err := tx.Create(&lote).Error; err != nil {
+					return err
+				}
+				lote = lote[:0]
+			}
+		}
+		if sesion.Muestras == 0 {
+			return errSinMuestras
+		}
+		if len(lote) > 0 {
+			if err := tx.Create(&lote).Error; err != nil {
+				return err
+			}
+		}
+
+		sesion.FilasConError = informe.Total
+		sesion.Evento = &evento
+		return tx.Model(&sesion).Updates(map[string]any{"muestras": sesion.Muestras, "filas_con_error": sesion.FilasConError}).Error
+	})
+	if errors.Is(err, errSinMuestras) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "errores": informe})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	sesion.Piloto = &piloto
+	c.JSON(http.StatusCreated, gin.H{
+		"sesion":    sesion,
+		"ignoradas": lector.Ignoradas(),
+		"errores":   informe,
+	})
+}
+
+var errSinMuestras = errors.New("el archivo no tiene ninguna fila válida")
+
+// Listar sesiones, opcionalmente filtradas por piloto_id y evento_id
+func (m *ManejadorTelemetria) ObtenerSesiones(c *gin.Context) {
+	consulta := m.db(c).Preload("Piloto").Preload("Evento").Order("id")
+	if pilotoID := c.Query("piloto_id"); pilotoID != "" {
+		consulta = consulta.Where("piloto_id = ?", pilotoID)
+	}
+	if eventoID := c.Query("evento_id"); eventoID != "" {
+		consulta = consulta.Where("evento_id = ?", eventoID)
+	}
+
+	var sesiones []models.SesionTelemetria
+	if err := consulta.Find(&sesiones).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, sesiones)
+}
+
+// Obtener una sesión por ID
+func (m *ManejadorTelemetria) ObtenerSesion(c *gin.Context) {
+	var sesion models.SesionTelemetria
+	if err := m.db(c).Preload("Piloto").Preload("Evento").First(&sesion, c.Param("id")).Error; err != nil {
+		responderErrorBusqueda(c, err, "Sesión no encontrada")
+		return
+	}
+	c.JSON(http.StatusOK, sesion)
+}
+
+// Muestras de una sesión, paginadas con ?desde= (índice) y ?limite=
+func (m *ManejadorTelemetria) ObtenerMuestras(c *gin.Context) {
+	var sesion models.SesionTelemetria
+	if err := m.db(c).First(&sesion, c.Param("id")).Error; err != nil {
+		responderErrorBusqueda(c, err, "Sesión no encontrada")
+		return
+	}
+
+	desde, _ := strconv.Atoi(c.DefaultQuery("desde", "0"))
+	limite, _ := strconv.Atoi(c.DefaultQuery("limite", "1000"))
+	if limite <= 0 || limite > maxMuestrasPorPagina {
+		limite = maxMuestrasPorPagina
+	}
+
+	var muestras []models.MuestraTelemetria
+	err := m.db(c).Where("sesion_id = ? AND indice >= ?", sesion.ID, desde).
+		Order("indice").Limit(limite).Find(&muestras).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"sesion_id": sesion.ID,
+		"canales":   strings.Split(sesion.Canales, ","),
+		"total":     sesion.Muestras,
+		"muestras":  muestras,
+	})
+}
+
+// Procesar un archivo de telemetría en paralelo sin guardarlo. El archivo se
+// lee en streaming, así que puede pesar varios GB: como multipart (campo
+// archivo o telemetryFile, con los demás campos antes del archivo) o como
+// cuerpo crudo text/csv o application/x-ndjson. Parámetros opcionales:
+// formato, trabajadores, agrupar (vuelta o sector), limites y longitud (para
+// sectores), bins (de los histogramas), canal y analysisType (max, min, avg,
+// sum, count, std, var, median o pNN) para obtener un único resultado.
+func (m *ManejadorTelemetria) ProcesarTelemetria(c *gin.Context) {
+	parametros := c.Request.URL.Query()
+	cuerpo, nombreArchivo, err := abrirArchivoTelemetria(c, parametros)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	formato := telemetria.DetectarFormato(nombreArchivo)
+	if nombre := parametros.Get("formato"); nombre != "" {
+		if formato, err = telemetria.ParseFormato(nombre); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	canal := telemetria.CanalVelocidad
+	if nombre := parametros.Get("canal"); nombre != "" {
+		var ok bool
+		if canal, ok = telemetria.CanalDeEncabezado(nombre); !ok || canal == telemetria.CanalTiempo {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("canal desconocido: %q", nombre)})
+			return
+		}
+	}
+	analisis := parametros.Get("analysisType")
+	if _, ok := resultadoAnalisis(telemetria.NuevaEstadistica(canal, 1), analisis); analisis != "" && !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("analysisType desconocido: %q (max, min, avg, sum, count, std, var, median o pNN)", analisis)})
+		return
+	}
+
+	opcionesAnalisis, err := opcionesAnalisisDesde(parametros)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opciones := telemetria.OpcionesPorDefecto()
+	if n, err := strconv.Atoi(parametros.Get("trabajadores")); err == nil && n > 0 && n <= 4*opciones.Trabajadores {
+		opciones.Trabajadores = n
+	}
+
+	inicio := time.Now()
+	lector, err := telemetria.NuevoLector(cuerpo, formato)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// Se valida una vez que el archivo tenga lo que pide la agrupación
+	if _, err := telemetria.NuevoAnalisis(lector.Canales(), opcionesAnalisis); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	informe := telemetria.NuevoInformeErrores(maxErroresInformados)
+	medir := medirCalculo("telemetria", "procesar")
+	resultado, estadisticas, err := telemetria.Procesar(c.Request.Context(), lector, opciones, informe, func() *telemetria.Analisis {
+		a, _ := telemetria.NuevoAnalisis(lector.Canales(), opcionesAnalisis)
+		return a
+	})
+	medir()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "errores": informe})
+		return
+	}
+
+	respuesta := gin.H{
+		"formato":     formato,
+		"canales":     resultado.General,
+		"ignoradas":   lector.Ignoradas(),
+		"errores":     informe,
+		"pipeline":    estadisticas,
+		"duracion_ms": time.Since(inicio).Milliseconds(),
+	}
+	if opcionesAnalisis.Agrupar != telemetria.SinAgrupar {
+		respuesta["agrupar"], respuesta["grupos"] = opcionesAnalisis.Agrupar, resultado.Grupos()
+	}
+	if analisis != "" {
+		estadistica, ok := resultado.General[canal]
+		if !ok || estadistica.Cantidad() == 0 {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("el archivo no tiene datos del canal %s", canal), "errores": informe})
+			return
+		}
+		respuesta["canal"], respuesta["analysisType"] = canal, analisis
+		respuesta["resultado"], _ = resultadoAnalisis(estadistica, analisis)
+	}
+	c.JSON(http.StatusOK, respuesta)
+}
+
+// resultadoAnalisis extrae un valor de la estadística según analysisType.
+func resultadoAnalisis(e *telemetria.Estadistica, analisis string) (float64, bool) {
+	switch analisis {
+	case "max":
+		return e.Max(), true
+	case "min":
+		return e.Min(), true
+	case "avg":
+		return e.Media(), true
+	case "sum":
+		return e.Suma(), true
+	case "count":
+		return float64(e.Cantidad()), true
+	case "std":
+		return e.Desviacion(), true
+	case "var":
+		return e.Varianza(), true
+	case "median":
+		return e.Percentil(50), true
+	}
+	if p, err := strconv.ParseFloat(strings.TrimPrefix(analisis, "p"), 64); err == nil && strings.HasPrefix(analisis, "p") && p >= 0 && p <= 100 {
+		return e.Percentil(p), true
+	}
+	return 0, false
+}
+
+func opcionesAnalisisDesde(parametros url.Values) (telemetria.OpcionesAnalisis, error) {
+	var opciones telemetria.OpcionesAnalisis
+	var err error
+	if opciones.Agrupar, err = telemetria.ParseAgrupacion(parametros.Get("agrupar")); err != nil {
+		return opciones, err
+	}
+	if opciones.Limites, err = parsearLimites(parametros.Get("limites")); err != nil {
+		return opciones, err
+	}
+	if texto := parametros.Get("longitud"); texto != "" {
+		if opciones.Longitud, err = strconv.ParseFloat(texto, 64); err != nil || opciones.Longitud <= 0 {
+			return opciones, fmt.Errorf("longitud inválida: %q", texto)
+		}
+	}
+	if texto := parametros.Get("bins"); texto != "" {
+		if opciones.Bins, err = strconv.Atoi(texto); err != nil || opciones.Bins < 1 || opciones.Bins > 1000 {
+			return opciones, fmt.Errorf("bins debe ser un entero entre 1 y 1000")
+		}
+	}
+	return opciones, nil
+}
+
+// abrirArchivoTelemetria devuelve el archivo sin copiarlo a memoria ni a
+// disco. En multipart, los campos previos al archivo se suman a parametros.
+func abrirArchivoTelemetria(c *gin.Context, parametros url.Values) (io.Reader, string, error) {
+	tipo, _, _ := mime.ParseMediaType(c.ContentType())
+	switch tipo {
+	case "multipart/form-data":
+		partes, err := c.Request.MultipartReader()
+		if err != nil {
+			return nil, "", err
+		}
+		for {
+			parte, err := partes.NextPart()
+			if err == io.EOF {
+				return nil, "", errors.New("falta el archivo de telemetría (campo archivo)")
+			}
+			if err != nil {
+				return nil, "", err
+			}
+			if parte.FormName() == "archivo" || parte.FormName() == "telemetryFile" {
+				return parte, parte.FileName(), nil
+			}
+			valor, err := io.ReadAll(io.LimitReader(parte, 1024))
+			if err != nil {
+				return nil, "", err
+			}
+			parametros.Set(parte.FormName(), string(valor))
+		}
+	case "application/x-ndjson", "application/jsonl":
+		return c.Request.Body, "cuerpo.jsonl", nil
+	default:
+		return c.Request.Body, "cuerpo.csv", nil
+	}
+}
+
+// Detectar anomalías en un archivo de telemetría sin guardarlo, con el mismo
+// pipeline paralelo que ProcesarTelemetria. Parámetros opcionales: formato,
+// trabajadores, ventana (muestras, impar), umbral_z, umbral_mad y rangos
+// (canal:min:max separados por coma, reemplazan los límites físicos).
+func (m *ManejadorTelemetria) DetectarAnomalias(c *gin.Context) {
+	parametros := c.Request.URL.Query()
+	cuerpo, nombreArchivo, err := abrirArchivoTelemetria(c, parametros)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	formato := telemetria.DetectarFormato(nombreArchivo)
+	if nombre := parametros.Get("formato"); nombre != "" {
+		if formato, err = telemetria.ParseFormato(nombre); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	opcionesAnomalias, err := opcionesAnomaliasDesde(parametros)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opciones := telemetria.OpcionesPorDefecto()
+	if n, err := strconv.Atoi(parametros.Get("trabajadores")); err == nil && n > 0 && n <= 4*opciones.Trabajadores {
+		opciones.Trabajadores = n
+	}
+	opciones.Solape = opcionesAnomalias.Solape()
+
+	inicio := time.Now()
+	lector, err := telemetria.NuevoLector(cuerpo, formato)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := telemetria.NuevoDetectorAnomalias(lector.Canales(), opcionesAnomalias); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	informe := telemetria.NuevoInformeErrores(maxErroresInformados)
+	medir := medirCalculo("telemetria", "anomalias")
+	detector, estadisticas, err := telemetria.Procesar(c.Request.Context(), lector, opciones, informe, func() *telemetria.DetectorAnomalias {
+		d, _ := telemetria.NuevoDetectorAnomalias(lector.Canales(), opcionesAnomalias)
+		return d
+	})
+	medir()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "errores": informe})
+		return
+	}
+
+	anomalias := detector.Anomalias()
+	porTipo := map[telemetria.TipoAnomalia]int{}
+	porSeveridad := map[telemetria.Severidad]int{}
+	for _, a := range anomalias {
+		porTipo[a.Tipo]++
+		porSeveridad[a.Severidad]++
+	}
+	total := len(anomalias)
+	if total > maxAnomaliasInformadas {
+		anomalias = anomalias[:maxAnomaliasInformadas]
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"formato":       formato,
+		"muestras":      estadisticas.Muestras,
+		"total":         total,
+		"anomalias":     anomalias,
+		"por_tipo":      porTipo,
+		"por_severidad": porSeveridad,
+		"ignoradas":     lector.Ignoradas(),
+		"errores":       informe,
+		"pipeline":      estadisticas,
+		"duracion_ms":   time.Since(inicio).Milliseconds(),
+	})
+}
+
+func opcionesAnomaliasDesde(parametros url.Values) (telemetria.OpcionesAnomalias, error) {
+	opciones := telemetria.OpcionesAnomaliasPorDefecto()
+	var err error
+	if texto := parametros.Get("ventana"); texto != "" {
+		if opciones.Ventana, err = strconv.Atoi(texto); err != nil || opciones.Ventana < 5 || opciones.Ventana > 1001 || opciones.Ventana%2 == 0 {
+			return opciones, fmt.Errorf("ventana debe ser un entero impar entre 5 y 1001")
+		}
+	}
+	for nombre, umbral := range map[string]*float64{"umbral_z": &opciones.UmbralZ, "umbral_mad": &opciones.UmbralMAD} {
+		if texto := parametros.Get(nombre); texto != "" {
+			if *umbral, err = strconv.ParseFloat(texto, 64); err != nil || *umbral <= 0 || *umbral > 100 {
+				return opciones, fmt.Errorf("%s debe estar entre 0 y 100", nombre)
+			}
+		}
+	}
+	if texto := parametros.Get("rangos"); texto != "" {
+		opciones.Rangos = map[telemetria.Canal][2]float64{}
+		for _, parte := range strings.Split(texto, ",") {
+			campos := strings.Split(strings.TrimSpace(parte), ":")
+			if len(campos) != 3 {
+				return opciones, fmt.Errorf("rango inválido: %q (canal:min:max)", parte)
+			}
+			canal, ok := telemetria.CanalDeEncabezado(campos[0])
+			if !ok {
+				return opciones, fmt.Errorf("canal desconocido: %q", campos[0])
+			}
+			minimo, err1 := strconv.ParseFloat(campos[1], 64)
+			maximo, err2 := strconv.ParseFloat(campos[2], 64)
+			if err1 != nil || err2 != nil || minimo >= maximo {
+				return opciones, fmt.Errorf("rango inválido: %q (canal:min:max)", parte)
+			}
+			opciones.Rangos[canal] = [2]float64{minimo, maximo}
+		}
+	}
+	return opciones, nil
+}
+
+// Vueltas y sectores detectados en una sesión, con la mejor vuelta, los
+// mejores sectores y las vueltas teórica e ideal. Parámetros opcionales:
+// longitud (m), limites (m desde la meta donde empiezan los sectores 2, 3...)
+// y minisectores.
+func (m *ManejadorTelemetria) ObtenerVueltas(c *gin.Context) {
+	circuito, err := circuitoDesde(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sesion, muestras, ok := m.cargarSesion(c)
+	if !ok {
+		return
+	}
+	medir := medirCalculo("telemetria", "vueltas")
+	vueltas, err := telemetria.DetectarVueltas(muestras, canalesDeSesion(sesion), circuito)
+	medir()
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, vueltas)
+}
+
+// Superpone dos vueltas guardadas (de la misma sesión o de dos pilotos)
+// alineadas por distancia. Parámetros: a y b (ids de sesión), vuelta_a y
+// vuelta_b (por defecto, la mejor vuelta de cada una), paso (m de la grilla)
+// y los mismos parámetros de circuito que /vueltas.
+func (m *ManejadorTelemetria) CompararVueltas(c *gin.Context) {
+	circuito, err := circuitoDesde(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	paso := 0.0
+	if texto := c.Query("paso"); texto != "" {
+		if paso, err = strconv.ParseFloat(texto, 64); err != nil || paso < 0.5 || paso > 500 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "paso debe ser un número entre 0.5 y 500"})
+			return
+		}
+	}
+
+	var trazas [2]*telemetria.TrazaVuelta
+	var sesiones [2]models.SesionTelemetria
+	for i, lado := range []string{"a", "b"} {
+		id, err := strconv.ParseUint(c.Query(lado), 10, 64)
+		if err != nil || id == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("falta el id de sesión %s", lado)})
+			return
+		}
+		numero := 0
+		if texto := c.Query("vuelta_" + lado); texto != "" {
+			if numero, err = strconv.Atoi(texto); err != nil || numero < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("vuelta_%s inválida: %q", lado, texto)})
+				return
+			}
+		}
+		if err := m.db(c).Preload("Piloto").First(&sesiones[i], id).Error; err != nil {
+			responderErrorBusqueda(c, err, fmt.Sprintf("Sesión %s no encontrada", lado))
+			return
+		}
+		muestras, err := m.muestrasDeSesion(c.Request.Context(), sesiones[i])
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		vueltas, err := telemetria.DetectarVueltas(muestras, canalesDeSesion(sesiones[i]), circuito)
+		if err == nil {
+			trazas[i], err = vueltas.Traza(numero)
+		}
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("sesión %s: %v", lado, err)})
+			return
+		}
+	}
+
+	medir := medirCalculo("telemetria", "comparar")
+	comparacion := telemetria.Comparar(trazas[0], trazas[1], paso, 0)
+	medir()
+	vuelta := func(sesion models.SesionTelemetria, t *telemetria.TrazaVuelta) gin.H {
+		return gin.H{"sesion_id": sesion.ID, "piloto": sesion.Piloto, "vuelta": t.Numero, "tiempo": t.Tiempo, "longitud": t.Longitud}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"a":           vuelta(sesiones[0], trazas[0]),
+		"b":           vuelta(sesiones[1], trazas[1]),
+		"comparacion": comparacion,
+	})
+}
+
+// circuitoDesde lee los parámetros opcionales longitud, limites y minisectores.
+func circuitoDesde(c *gin.Context) (telemetria.Circuito, error) {
+	var circuito telemetria.Circuito
+	var err error
+	if texto := c.Query("longitud"); texto != "" {
+		if circuito.Longitud, err = strconv.ParseFloat(texto, 64); err != nil || circuito.Longitud <= 0 {
+			return circuito, fmt.Errorf("longitud inválida: %q", texto)
+		}
+	}
+	if circuito.Limites, err = parsearLimites(c.Query("limites")); err != nil {
+		return circuito, err
+	}
+	if texto := c.Query("minisectores"); texto != "" {
+		if circuito.Minisectores, err = strconv.Atoi(texto); err != nil || circuito.Minisectores < 1 || circuito.Minisectores > 1000 {
+			return circuito, fmt.Errorf("minisectores debe ser un entero entre 1 y 1000")
+		}
+	}
+	return circuito, nil
+}
+
+// Genera un archivo de telemetría sintética. Parámetros opcionales: trazado
+// (monza), perfil (f1), vueltas (3), frecuencia (10 Hz), ruido (1), semilla
+// (al azar; se devuelve en X-Semilla) y formato (csv o jsonl).
+func (m *ManejadorTelemetria) GenerarTelemetria(c *gin.Context) {
+	opciones, formato, err := opcionesGeneradorDesde(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Los encabezados se envían con la primera muestra: si las opciones no
+	// son válidas todavía se puede responder con un error JSON
+	var escritor *telemetria.Escritor
+	err = telemetria.Generar(opciones, func(muestra telemetria.Muestra) error {
+		if escritor == nil {
+			nombre := fmt.Sprintf("%s-%s-%d.%s", opciones.Trazado, opciones.Perfil, opciones.Semilla, formato)
+			c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": nombre}))
+			c.Header("X-Semilla", strconv.FormatInt(opciones.Semilla, 10))
+			c.Header("Content-Type", tipoDeContenido(formato))
+			c.Status(http.StatusOK)
+			if escritor, err = telemetria.NuevoEscritor(c.Writer, formato); err != nil {
+				return err
+			}
+		}
+		return escritor.Escribir(muestra)
+	})
+	if escritor == nil {
+		if err == nil {
+			err = errors.New("sin muestras: las opciones no generan ninguna")
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err == nil {
+		err = escritor.Cerrar()
+	}
+	if err != nil {
+		c.Error(err)
+	}
+}
+
+// opcionesGeneradorDesde lee las opciones del generador de la consulta.
+func opcionesGeneradorDesde(parametros url.Values) (telemetria.OpcionesGenerador, telemetria.Formato, error) {
+	opciones := telemetria.OpcionesGenerador{
+		Trazado:    "monza",
+		Perfil:     "f1",
+		Vueltas:    3,
+		Frecuencia: 10,
+		Ruido:      1,
+		Semilla:    time.Now().UnixNano(),
+	}
+	formato := telemetria.FormatoCSV
+	var err error
+	if texto := parametros.Get("trazado"); texto != "" {
+		opciones.Trazado = strings.ToLower(texto)
+	}
+	if texto := parametros.Get("perfil"); texto != "" {
+		opciones.Perfil = strings.ToLower(texto)
+	}
+	if texto := parametros.Get("vueltas"); texto != "" {
+		if opciones.Vueltas, err = strconv.Atoi(texto); err != nil || opciones.Vueltas < 1 || opciones.Vueltas > maxVueltasGenerador {
+			return opciones, formato, fmt.Errorf("vueltas debe ser un entero entre 1 y %d", maxVueltasGenerador)
+		}
+	}
+	if texto := parametros.Get("frecuencia"); texto != "" {
+		if opciones.Frecuencia, err = strconv.ParseFloat(texto, 64); err != nil || opciones.Frecuencia <= 0 || opciones.Frecuencia > maxFrecuenciaGenerador {
+			return opciones, formato, fmt.Errorf("frecuencia debe estar entre 0 y %d Hz", maxFrecuenciaGenerador)
+		}
+	}
+	if texto := parametros.Get("ruido"); texto != "" {
+		if opciones.Ruido, err = strconv.ParseFloat(texto, 64); err != nil || opciones.Ruido < 0 || opciones.Ruido > 10 {
+			return opciones, formato, fmt.Errorf("ruido debe estar entre 0 y 10")
+		}
+	}
+	if texto := parametros.Get("semilla"); texto != "" {
+		if opciones.Semilla, err = strconv.ParseInt(texto, 10, 64); err != nil {
+			return opciones, formato, fmt.Errorf("semilla inválida: %q", texto)
+		}
+	}
+	if texto := parametros.Get("formato"); texto != "" {
+		if formato, err = telemetria.ParseFormato(texto); err != nil {
+			return opciones, formato, err
+		}
+	}
+	return opciones, formato, nil
+}
+
+func tipoDeContenido(formato telemetria.Formato) string {
+	if formato == telemetria.FormatoJSONL {
+		return "application/x-ndjson"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// cargarSesion busca la sesión del parámetro :id y todas sus muestras en
+// orden. Si falla, ya respondió al cliente.
+func (m *ManejadorTelemetria) cargarSesion(c *gin.Context) (models.SesionTelemetria, []telemetria.Muestra, bool) {
+	var sesion models.SesionTelemetria
+	if err := m.db(c).First(&sesion, c.Param("id")).Error; err != nil {
+		responderErrorBusqueda(c, err, "Sesión no encontrada")
+		return sesion, nil, false
+	}
+	muestras, err := m.muestrasDeSesion(c.Request.Context(), sesion)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return sesion, nil, false
+	}
+	return sesion, muestras, true
+}
+
+// CargarTelemetria devuelve las muestras y canales de una sesión guardada.
+func (m *ManejadorTelemetria) CargarTelemetria(ctx context.Context, sesionID uint) ([]telemetria.Muestra, []telemetria.Canal, error) {
+	var sesion models.SesionTelemetria
+	if err := m.DB.WithContext(ctx).First(&sesion, sesionID).Error; err != nil {
+		return nil, nil, err
+	}
+	muestras, err := m.muestrasDeSesion(ctx, sesion)
+	return muestras, canalesDeSesion(sesion), err
+}
+
+func (m *ManejadorTelemetria) muestrasDeSesion(ctx context.Context, sesion models.SesionTelemetria) ([]telemetria.Muestra, error) {
+	muestras := make([]telemetria.Muestra, 0, sesion.Muestras)
+	var lote []models.MuestraTelemetria
+	err := m.DB.WithContext(ctx).Where("sesion_id = ?", sesion.ID).Order("indice").
+		FindInBatches(&lote, loteMuestras, func(tx *gorm.DB, _ int) error {
+			for _, fila := range lote {
+				muestras = append(muestras, fila.Muestra)
+			}
+			return nil
+		}).Error
+	return muestras, err
+}
+
+func canalesDeSesion(sesion models.SesionTelemetria) []telemetria.Canal {
+	var canales []telemetria.Canal
+	for _, nombre := range strings.Split(sesion.Canales, ",") {
+		canales = append(canales, telemetria.Canal(nombre))
+	}
+	return canales
+}
+
+// parsearLimites lee una lista de distancias separadas por coma.
+func parsearLimites(texto string) ([]float64, error) {
+	if texto == "" {
+		return nil, nil
+	}
+	var limites []float64
+	for _, parte := range strings.Split(texto, ",") {
+		limite, err := strconv.ParseFloat(strings.TrimSpace(parte), 64)
+		if err != nil {
+			return nil, fmt.Errorf("límite de sector inválido: %q", parte)
+		}
+		limites = append(limites, limite)
+	}
+	return limites, nil
+}
+
+// Listar eventos
+func (m *ManejadorTelemetria) ObtenerEventos(c *gin.Context) {
+	var eventos []models.Evento
+	if err := m.db(c).Order("temporada desc, nombre").Find(&eventos).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, eventos)
+}
+
+func unirCanales(canales []telemetria.Canal) string {
+	nombres := make([]string, len(canales))
+	for i, canal := range canales {
+		nombres[i] = string(canal)
+	}
+	return strings.Join(nombres, ",")
+}
+
+// responderErrorBusqueda responde 404 con mensaje si el registro no existe
+// y 500 si la consulta falló, para que una caída de la base no parezca un
+// error del cliente.
+func responderErrorBusqueda(c *gin.Context, err error, mensaje string) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": mensaje})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
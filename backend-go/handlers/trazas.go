@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"formula1-crud-go/registro"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// -------------------- Trazas --------------------
+
+var trazador = otel.Tracer("formula1-crud-go/handlers")
+
+// Trazar abre un span por pedido, hijo del que mande el cliente en
+// traceparent, y lo deja en el contexto: las consultas SQL y las
+// simulaciones que inicie el pedido cuelgan de él. Va después de IDPedido
+// para llevar su ID.
+func Trazar() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Los healthchecks y los scrapes de Prometheus llegan cada pocos
+		// segundos y solo taparían las trazas que interesan
+		switch c.FullPath() {
+		case "/healthz", "/readyz", "/metrics":
+			c.Next()
+			return
+		}
+
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		// El nombre usa la plantilla de la ruta, como las métricas
+		nombre := c.Request.Method
+		attrs := []attribute.KeyValue{
+			semconv.HTTPRequestMethodKey.String(c.Request.Method),
+			semconv.URLPath(c.Request.URL.Path),
+			semconv.ClientAddress(c.ClientIP()),
+			attribute.String("id_pedido", registro.IDPedido(ctx)),
+		}
+		if ruta := c.FullPath(); ruta != "" {
+			nombre += " " + ruta
+			attrs = append(attrs, semconv.HTTPRoute(ruta))
+		}
+		ctx, span := trazador.Start(ctx, nombre, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		estado := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(estado))
+		if len(c.Errors) > 0 {
+			span.RecordError(c.Errors.Last())
+		}
+		// Las 4xx son errores del cliente, no del servidor
+		if estado >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(estado))
+		}
+	}
+}
@@ -1,226 +1,170 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"formula1-crud-go/config"
 	"formula1-crud-go/database"
 	"formula1-crud-go/handlers"
+	"formula1-crud-go/metricas"
+	"formula1-crud-go/registro"
+	"formula1-crud-go/repositorios"
+	"formula1-crud-go/simulacion"
+	"formula1-crud-go/trazas"
 	"html/template"
-	"log"
 	"math/rand"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/gorilla/websocket"
 )
 
-// -------------------- Configuración WebSocket --------------------
-var actualizador = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool { return true },
-}
+// -------------------- HTML Template --------------------
+var plantillaSimulacion = template.Must(template.New("simulacion").Parse(htmlSimulacion))
 
-// -------------------- Tipos de Mensajes --------------------
-type MensajeWS struct {
-	Tipo   string `json:"tipo"`
-	Topico string `json:"topico,omitempty"`
-	Texto  string `json:"texto,omitempty"`
-	Obj    any    `json:"obj,omitempty"`
+func simulacionHandler(c *gin.Context) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	plantillaSimulacion.Execute(c.Writer, nil)
 }
 
-type ResultadoOpenMP struct {
-	AutoID          int     `json:"auto_id"`
-	MejorVuelta     float64 `json:"mejor_vuelta"`
-	CantidadVueltas int     `json:"cantidad_vueltas"`
-}
+// -------------------- Main --------------------
+func main() {
+	// Con un subcomando no se levanta el servidor
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		if err := ejecutarSubcomando(os.Args[1], os.Args[2:]); err != nil && !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 
-// -------------------- Funciones de Simulación --------------------
-func correrMPI(sectores int, vueltas int, enviar chan MensajeWS) {
-	if sectores < 1 {
-		enviar <- MensajeWS{Tipo: "registro", Topico: "mpi", Texto: "Error: sectores debe ser >= 1"}
-		enviar <- MensajeWS{Tipo: "finalizado", Topico: "mpi"}
+	// Configuración: archivo, entorno y banderas (-h las lista todas). Los
+	// errores van como texto: todavía no se sabe el formato de los logs
+	cfg, err := config.Cargar("formula1-crud", os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
 		return
 	}
-	if vueltas < 1 {
-		vueltas = 1
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
+	registro.Configurar(cfg.Registro.Opciones())
 
-	enviar <- MensajeWS{Tipo: "registro", Topico: "mpi", Texto: fmt.Sprintf("Iniciando MPI: %d sectores, %d vueltas", sectores, vueltas)}
-
-	for v := 1; v <= vueltas; v++ {
-		enviar <- MensajeWS{Tipo: "registro", Topico: "mpi", Texto: fmt.Sprintf("=== Vuelta %d ===", v)}
-		for s := 1; s <= sectores; s++ {
-			tiempoSector := float64(rand.Intn(2300)+1200) / 100.0
-			time.Sleep(300 * time.Millisecond)
-			enviar <- MensajeWS{
-				Tipo:   "registro",
-				Topico: "mpi",
-				Texto:  fmt.Sprintf("Tiempo de sector %d: %.2f s (vuelta %d)", s, tiempoSector, v),
-			}
-		}
-	}
+	rand.Seed(time.Now().UnixNano())
 
-	enviar <- MensajeWS{Tipo: "resumen", Topico: "mpi", Obj: map[string]any{"mensaje": "MPI finalizado"}}
-	enviar <- MensajeWS{Tipo: "finalizado", Topico: "mpi"}
-}
+	// SIGINT (Ctrl+C) o SIGTERM (docker stop) inician el apagado ordenado
+	ctx, detener := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer detener()
 
-func correrOpenMP(cantidadAutos int, vueltas int, enviar chan MensajeWS) {
-	if cantidadAutos < 1 {
-		enviar <- MensajeWS{Tipo: "registro", Topico: "openmp", Texto: "Error: cantidad de autos debe ser >= 1"}
-		enviar <- MensajeWS{Tipo: "finalizado", Topico: "openmp"}
-		return
-	}
-	if vueltas < 1 {
-		vueltas = 1
+	// Trazas OpenTelemetry: por defecto no se exportan
+	apagarTrazas, err := trazas.Iniciar(ctx, cfg.Trazas.Opciones())
+	if err != nil {
+		fatal("no se pudieron preparar las trazas", err)
 	}
 
-	enviar <- MensajeWS{Tipo: "registro", Topico: "openmp", Texto: fmt.Sprintf("Iniciando OpenMP: %d autos, %d vueltas cada uno", cantidadAutos, vueltas)}
-
-	resultados := make([]ResultadoOpenMP, cantidadAutos)
-	done := make(chan struct{})
-
-	for auto := 0; auto < cantidadAutos; auto++ {
-		go func(autoID int) {
-			defer func() { done <- struct{}{} }()
-
-			mejor := 1e9
-			for v := 1; v <= vueltas; v++ {
-				tiempoVuelta := float64(rand.Intn(2099)+7500) / 100.0
-				time.Sleep(200 * time.Millisecond)
-				enviar <- MensajeWS{Tipo: "registro", Topico: "openmp", Texto: fmt.Sprintf("Auto %d - Vuelta %d: %.2f s", autoID+1, v, tiempoVuelta)}
-				if tiempoVuelta < mejor {
-					mejor = tiempoVuelta
-					enviar <- MensajeWS{Tipo: "registro", Topico: "openmp", Texto: fmt.Sprintf("Auto %d - Nueva mejor vuelta: %.2f s", autoID+1, mejor)}
-				}
-			}
-			resultados[autoID] = ResultadoOpenMP{AutoID: autoID + 1, MejorVuelta: mejor, CantidadVueltas: vueltas}
-		}(auto)
+	// Conectar a la base de datos (reintenta mientras no responda)
+	if err := database.ConectarBaseDeDatos(cfg.BaseDeDatos); err != nil {
+		fatal("no se pudo preparar la base de datos", err)
 	}
-
-	for i := 0; i < cantidadAutos; i++ {
-		<-done
+	monitor, err := database.NuevoMonitor(database.DB, cfg.BaseDeDatos.PeriodoSalud)
+	if err != nil {
+		fatal("no se pudo preparar el monitor de la base de datos", err)
 	}
-
-	mejorGeneral := ResultadoOpenMP{AutoID: -1, MejorVuelta: 1e9}
-	for _, r := range resultados {
-		if r.MejorVuelta < mejorGeneral.MejorVuelta {
-			mejorGeneral = r
+	monitor.Iniciar(ctx)
+	if sqlDB, err := database.DB.DB(); err == nil {
+		if err := metricas.RegistrarBaseDeDatos(sqlDB, database.DB.Dialector.Name()); err != nil {
+			fatal("no se pudieron registrar las métricas de la base de datos", err)
 		}
 	}
-
-	enviar <- MensajeWS{Tipo: "resumen", Topico: "openmp", Obj: map[string]any{
-		"mejor_por_auto": resultados,
-		"mejor_general":  mejorGeneral,
-	}}
-	enviar <- MensajeWS{Tipo: "finalizado", Topico: "openmp"}
-}
-
-// -------------------- Handlers WebSocket --------------------
-func wsHandler(c *gin.Context) {
-	conn, err := actualizador.Upgrade(c.Writer, c.Request, nil)
+	migrador, err := database.NuevoMigrador(database.DB)
 	if err != nil {
-		log.Println("Error al actualizar a websocket:", err)
-		return
+		fatal("no se pudieron cargar las migraciones", err)
 	}
-	defer conn.Close()
 
-	enviar := make(chan MensajeWS, 100)
-	defer close(enviar)
-
-	go func() {
-		for msg := range enviar {
-			if err := conn.WriteJSON(msg); err != nil {
-				log.Println("Error escribiendo en websocket:", err)
-				return
-			}
-		}
-	}()
-
-	for {
-		var comando map[string]any
-		if err := conn.ReadJSON(&comando); err != nil {
-			log.Println("Conexión cerrada o error de lectura:", err)
-			return
-		}
-		switch comando["action"] {
-		case "iniciar_mpi":
-			sectores := 1
-			vueltas := 1
-			if v, ok := comando["sectores"].(float64); ok {
-				sectores = int(v)
-			}
-			if v, ok := comando["vueltas"].(float64); ok {
-				vueltas = int(v)
-			}
-			go correrMPI(sectores, vueltas, enviar)
-		case "iniciar_openmp":
-			autos := 3
-			vueltas := 5
-			if v, ok := comando["autos"].(float64); ok {
-				autos = int(v)
-			}
-			if v, ok := comando["vueltas"].(float64); ok {
-				vueltas = int(v)
-			}
-			go correrOpenMP(autos, vueltas, enviar)
-		default:
-			enviar <- MensajeWS{Tipo: "registro", Texto: fmt.Sprintf("Comando no reconocido: %v", comando["action"])}
-		}
+	// Crear router: cada pedido lleva un ID que aparece en todos sus logs y
+	// un span del que cuelgan sus consultas y simulaciones
+	if os.Getenv(gin.EnvGinMode) == "" {
+		gin.SetMode(gin.ReleaseMode)
 	}
-}
-
-// -------------------- HTML Template --------------------
-var plantillaSimulacion = template.Must(template.New("simulacion").Parse(htmlSimulacion))
-
-func simulacionHandler(c *gin.Context) {
-	c.Header("Content-Type", "text/html; charset=utf-8")
-	plantillaSimulacion.Execute(c.Writer, nil)
-}
-
-// -------------------- Main --------------------
-func main() {
-	rand.Seed(time.Now().UnixNano())
-
-	// Conectar a la base de datos
-	database.ConectarBaseDeDatos()
-
-	// Crear router
-	router := gin.Default()
+	router := gin.New()
+	// Sin esto gin cree en cualquier X-Forwarded-For y un cliente podría
+	// cambiar de IP a voluntad para saltarse los límites por cliente
+	if err := router.SetTrustedProxies(cfg.Servidor.ProxiesConfiables); err != nil {
+		fatal("proxies confiables inválidos", err)
+	}
+	router.Use(handlers.IDPedido(), handlers.Trazar(), handlers.RegistrarPedidos(), handlers.MedirPedidos(), handlers.Recuperar())
 
 	// Configurar CORS
-	router.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-
-		c.Next()
-	})
-
-	// Inicializar manejador
-	manejador := handlers.NuevoManejadorPilotos(database.DB)
+	router.Use(handlers.CORS(cfg.CORS))
+
+	// Inicializar manejadores
+	manejador := handlers.NuevoManejadorPilotos(repositorios.NuevoRepositorioPilotosGorm(database.DB))
+	manejadorTelemetria := handlers.NuevoManejadorTelemetria(database.DB)
+	manejadorCalculo := handlers.NuevoManejadorCalculo()
+	manejadorSalud := handlers.NuevoManejadorSalud(monitor, migrador)
+	hub := simulacion.NuevoHub()
+	planificador := simulacion.NuevoPlanificador(cfg.Simulacion.Limites(), cfg.Simulacion.Ritmo(), hub)
+	manejadorSimulaciones := handlers.NuevoManejadorSimulaciones(planificador, hub, cfg.WebSocket, cfg.CORS, manejadorTelemetria)
+	manejadorRuntime := handlers.NuevoManejadorRuntime(planificador)
+
+	// Salud: vivo (liveness) y listo (readiness)
+	router.GET("/healthz", manejadorSalud.Vivo)
+	router.GET("/readyz", manejadorSalud.Listo)
+
+	// Métricas para Prometheus
+	router.GET("/metrics", gin.WrapH(metricas.Manejador()))
+
+	// Runtime de Go, solo para administradores (token de "token-admin")
+	soloAdmin := handlers.SoloAdmin(cfg.Auth.SecretoJWT)
+	depuracion := router.Group("/debug/pprof", soloAdmin)
+	depuracion.GET("/*perfil", handlers.Pprof)
+	depuracion.POST("/*perfil", handlers.Pprof)
+	router.GET("/api/runtime", soloAdmin, manejadorRuntime.ObtenerRuntime)
+	router.POST("/api/runtime/traza", soloAdmin, manejadorRuntime.CapturarTraza)
 
 	// Routes API CRUD
 	api := router.Group("/api")
 	{
-		api.GET("/pilotos", manejador.ObtenerPilotos)
-		api.GET("/pilotos/:id", manejador.ObtenerPiloto)
-		api.POST("/pilotos", manejador.CrearPiloto)
-		api.PUT("/pilotos/:id", manejador.ActualizarPiloto)
-		api.DELETE("/pilotos/:id", manejador.EliminarPiloto)
-		api.GET("/estadisticas", manejador.ObtenerEstadisticas)
-		api.GET("/buscar", manejador.BuscarPorEquipo)
+		// Las que consultan la base responden 503 mientras esté caída; el
+		// resto (cálculo, simulaciones, procesar archivos) sigue funcionando
+		conBase := api.Group("", manejadorSalud.RequiereBaseDeDatos())
+		conBase.GET("/pilotos", manejador.ObtenerPilotos)
+		conBase.GET("/pilotos/:id", manejador.ObtenerPiloto)
+		conBase.POST("/pilotos", manejador.CrearPiloto)
+		conBase.PUT("/pilotos/:id", manejador.ActualizarPiloto)
+		conBase.DELETE("/pilotos/:id", manejador.EliminarPiloto)
+		conBase.GET("/estadisticas", manejador.ObtenerEstadisticas)
+		conBase.GET("/buscar", manejador.BuscarPorEquipo)
+		conBase.GET("/eventos", manejadorTelemetria.ObtenerEventos)
+		conBase.GET("/telemetria/sesiones", manejadorTelemetria.ObtenerSesiones)
+		conBase.POST("/telemetria/sesiones", manejadorTelemetria.SubirSesion)
+		conBase.GET("/telemetria/sesiones/:id", manejadorTelemetria.ObtenerSesion)
+		conBase.GET("/telemetria/sesiones/:id/muestras", manejadorTelemetria.ObtenerMuestras)
+		conBase.GET("/telemetria/sesiones/:id/vueltas", manejadorTelemetria.ObtenerVueltas)
+		conBase.GET("/telemetria/comparar", manejadorTelemetria.CompararVueltas)
+
+		api.GET("/telemetria/canales", manejadorTelemetria.ObtenerCanales)
+		api.POST("/telemetria/procesar", manejadorTelemetria.ProcesarTelemetria)
+		api.POST("/telemetria/anomalias", manejadorTelemetria.DetectarAnomalias)
+		api.GET("/telemetria/generar", manejadorTelemetria.GenerarTelemetria)
+		api.GET("/simulaciones/cola", manejadorSimulaciones.ObtenerCola)
+		api.POST("/simulaciones", manejadorSimulaciones.CrearSimulacion)
+		api.GET("/simulaciones/:id/stream", manejadorSimulaciones.StreamSimulacion)
+		api.POST("/simulaciones/:id/control", manejadorSimulaciones.ControlarSimulacion)
+		api.GET("/protocolo/schema", manejadorSimulaciones.ObtenerSchemaProtocolo)
+		api.GET("/pi", manejadorCalculo.CalcularPi)
 	}
 
 	// Routes Simulación
 	router.GET("/simulacion", simulacionHandler)
-	router.GET("/ws", func(c *gin.Context) {
-		wsHandler(c)
-	})
+	router.GET("/ws", manejadorSimulaciones.WebSocket)
 
 	// Servir archivos estáticos
 	router.Static("/static", "./frontend")
@@ -228,20 +172,67 @@ func main() {
 		c.File("./frontend/index.html")
 	})
 
-	// Obtener puerto de environment variable
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
+	port := strconv.Itoa(cfg.Servidor.Puerto)
+	registroApp.Info("servidor Formula 1 ejecutándose",
+		"puerto", cfg.Servidor.Puerto,
+		"api", "http://localhost:"+port+"/api/pilotos",
+		"frontend", "http://localhost:"+port,
+		"simulacion", "http://localhost:"+port+"/simulacion")
+
+	servidor := &http.Server{Addr: ":" + port, Handler: router}
+	go func() {
+		if err := servidor.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("no se pudo iniciar el servidor", err)
+		}
+	}()
 
-	log.Printf("Servidor Formula 1 ejecutándose en puerto %s", port)
-	log.Printf("API CRUD disponible en: http://localhost:%s/api/pilotos", port)
-	log.Printf("Frontend disponible en: http://localhost:%s", port)
-	log.Printf("Simulación disponible en: http://localhost:%s/simulacion", port)
+	<-ctx.Done()
+	// Una segunda señal termina el proceso sin esperar
+	detener()
+	apagar(servidor, planificador, manejadorSimulaciones, apagarTrazas, cfg.Servidor.EsperaApagado)
+}
 
-	if err := router.Run(":" + port); err != nil {
-		log.Fatal("Error iniciando el servidor:", err)
+// -------------------- Apagado --------------------
+
+// apagar detiene el servidor en orden: deja de aceptar conexiones, cancela
+// las simulaciones, despide a los clientes WebSocket y SSE, espera los
+// pedidos HTTP en curso y cierra la base al final, cuando ya nadie la usa.
+// Por último envía los spans pendientes. Lo que no termine dentro de espera
+// se corta.
+func apagar(servidor *http.Server, planificador *simulacion.Planificador, simulaciones *handlers.ManejadorSimulaciones, apagarTrazas func(context.Context) error, espera time.Duration) {
+	registroApp.Info("apagando el servidor", "espera_maxima", espera.String())
+	ctx, cancelar := context.WithTimeout(context.Background(), espera)
+	defer cancelar()
+
+	// Shutdown cierra el listener en el acto y espera los pedidos en curso;
+	// los streams SSE terminan cuando los despide simulaciones.Apagar
+	servidorApagado := make(chan error, 1)
+	go func() { servidorApagado <- servidor.Shutdown(ctx) }()
+
+	if err := planificador.Apagar(ctx); err != nil {
+		registroApp.Warn("simulaciones sin terminar al apagar", "error", err)
+	}
+	if err := simulaciones.Apagar(ctx); err != nil {
+		registroApp.Warn("conexiones WebSocket/SSE sin cerrar al apagar", "error", err)
 	}
+	if err := <-servidorApagado; err != nil {
+		registroApp.Warn("pedidos HTTP sin terminar al apagar", "error", err)
+	}
+	if err := database.Cerrar(); err != nil {
+		registroApp.Warn("error cerrando la base de datos", "error", err)
+	}
+	if err := apagarTrazas(ctx); err != nil {
+		registroApp.Warn("trazas sin enviar al apagar", "error", err)
+	}
+	registroApp.Info("servidor apagado")
+}
+
+var registroApp = registro.Para(registro.App)
+
+// fatal registra el error y termina el proceso.
+func fatal(mensaje string, err error) {
+	registroApp.Error(mensaje, "error", err)
+	os.Exit(1)
 }
 
 // -------------------- HTML + JS embebido --------------------
@@ -406,7 +397,7 @@ const htmlSimulacion = `
             font-weight: 500;
         }
 
-        .input-group input {
+        .input-group input, .input-group select {
             width: 100%;
             padding: 10px;
             border-radius: 6px;
@@ -447,6 +438,36 @@ const htmlSimulacion = `
             box-shadow: 0 5px 15px rgba(255, 204, 0, 0.3);
         }
 
+        .control-buttons {
+            display: flex;
+            gap: 8px;
+            margin-top: 8px;
+        }
+
+        .btn-control {
+            background: var(--f1-gray);
+            color: var(--f1-white);
+            padding: 8px;
+        }
+
+        .btn-control:disabled {
+            opacity: 0.4;
+            cursor: not-allowed;
+        }
+
+        .input-group input[type="range"] {
+            padding: 0;
+        }
+
+        /* Telemetría en vivo */
+        .telemetria-vivo canvas {
+            width: 100%;
+            height: 160px;
+            background: rgba(30, 30, 40, 0.8);
+            border-radius: 8px;
+            margin-top: 15px;
+        }
+
         /* Circuito */
         .circuit-container {
             flex: 2;
@@ -695,6 +716,11 @@ const htmlSimulacion = `
                     <button class="btn btn-mpi" id="start-mpi">
                         <i class="fas fa-play-circle"></i> Iniciar Simulación MPI
                     </button>
+                    <div class="control-buttons">
+                        <button class="btn btn-control" id="pause-mpi" disabled><i class="fas fa-pause"></i> Pausar</button>
+                        <button class="btn btn-control" id="resume-mpi" disabled><i class="fas fa-play"></i> Reanudar</button>
+                        <button class="btn btn-control" id="cancel-mpi" disabled><i class="fas fa-stop"></i> Cancelar</button>
+                    </div>
                 </div>
 
                 <div class="panel-section">
@@ -710,8 +736,52 @@ const htmlSimulacion = `
                     <button class="btn btn-openmp" id="start-openmp">
                         <i class="fas fa-play-circle"></i> Iniciar Simulación OpenMP
                     </button>
+                    <div class="control-buttons">
+                        <button class="btn btn-control" id="pause-openmp" disabled><i class="fas fa-pause"></i> Pausar</button>
+                        <button class="btn btn-control" id="resume-openmp" disabled><i class="fas fa-play"></i> Reanudar</button>
+                        <button class="btn btn-control" id="cancel-openmp" disabled><i class="fas fa-stop"></i> Cancelar</button>
+                    </div>
+                </div>
+
+                <div class="panel-section">
+                    <h3><i class="fas fa-wave-square"></i> Telemetría en Vivo</h3>
+                    <div class="input-group">
+                        <label for="telemetria-sesion">Sesión guardada (ID):</label>
+                        <input type="number" id="telemetria-sesion" min="1" value="1">
+                    </div>
+                    <div class="input-group">
+                        <label for="telemetria-velocidad">Velocidad:</label>
+                        <select id="telemetria-velocidad">
+                            <option value="1">1x (tiempo real)</option>
+                            <option value="2">2x</option>
+                            <option value="10">10x</option>
+                        </select>
+                    </div>
+                    <button class="btn btn-control" id="start-telemetria">
+                        <i class="fas fa-play-circle"></i> Reproducir Sesión
+                    </button>
+                    <div class="control-buttons">
+                        <button class="btn btn-control" id="pause-telemetria" disabled><i class="fas fa-pause"></i> Pausar</button>
+                        <button class="btn btn-control" id="resume-telemetria" disabled><i class="fas fa-play"></i> Reanudar</button>
+                        <button class="btn btn-control" id="cancel-telemetria" disabled><i class="fas fa-stop"></i> Cancelar</button>
+                    </div>
+                    <div class="input-group">
+                        <label for="telemetria-posicion">Posición: <span id="telemetria-tiempo">-</span></label>
+                        <input type="range" id="telemetria-posicion" min="0" max="0" step="0.1" value="0" disabled>
+                    </div>
                 </div>
 
+                <div class="panel-section">
+                    <h3><i class="fas fa-users"></i> Modo Espectador</h3>
+                    <div class="input-group">
+                        <label for="watch-target">Simulación (ID) o tópico (mpi / openmp):</label>
+                        <input type="text" id="watch-target" placeholder="openmp-1a2b3c4d">
+                    </div>
+                    <button class="btn btn-control" id="watch-btn">
+                        <i class="fas fa-eye"></i> Mirar
+                    </button>
+                    <p id="viewers">Espectadores: -</p>
+                </div>
                 <div class="panel-section">
                     <h3><i class="fas fa-info-circle"></i> Información</h3>
                     <p>MPI simula el procesamiento de sectores de una pista en un anillo de nodos.</p>
@@ -761,12 +831,31 @@ const htmlSimulacion = `
                 </div>
             </div>
         </div>
+
+        <div class="stats-panel telemetria-vivo">
+            <h3><i class="fas fa-wave-square"></i> Telemetría en Vivo</h3>
+            <div class="stats-grid">
+                <div class="stat-card">
+                    <h4>Velocidad</h4>
+                    <div id="telemetria-kmh" class="stat-value">-</div>
+                </div>
+                <div class="stat-card">
+                    <h4>RPM / Marcha</h4>
+                    <div id="telemetria-rpm" class="stat-value">-</div>
+                </div>
+                <div class="stat-card">
+                    <h4>Acelerador / Freno</h4>
+                    <div id="telemetria-pedales" class="stat-value">-</div>
+                </div>
+            </div>
+            <canvas id="telemetria-traza" width="1200" height="160"></canvas>
+        </div>
     </div>
 
     <script>
         document.addEventListener('DOMContentLoaded', function() {
-            // Conexión WebSocket
-            const ws = new WebSocket("ws://" + location.host + "/ws");
+            // Conexión WebSocket (se reconecta sola y retoma las simulaciones)
+            let ws = null;
             const mpiLog = document.getElementById("mpi-log");
             const openmpLog = document.getElementById("openmp-log");
             const sectorsContainer = document.getElementById("sectors-container");
@@ -778,6 +867,7 @@ const htmlSimulacion = `
             const sectorsProcessed = document.getElementById("sectors-processed");
             const bestOpenmpTime = document.getElementById("best-openmp-time");
             const lapsCompleted = document.getElementById("laps-completed");
+            const viewers = document.getElementById("viewers");
             
             // Variables de estado
             let currentSectors = 5;
@@ -785,14 +875,51 @@ const htmlSimulacion = `
             let openmpStats = { bestTime: Infinity, lapsCompleted: 0 };
             let carPositions = {};
             let nodePositions = [];
+            let simulacionActual = { mpi: null, openmp: null, telemetria: null };
+
+            // Reproducción de telemetría: últimos segundos de velocidad
+            const trazaTelemetria = document.getElementById("telemetria-traza");
+            const posicionTelemetria = document.getElementById("telemetria-posicion");
+            let telemetria = { duracion: 0, puntos: [] };
+            let ultimoSeq = {};
+            let tokens = {};
+
+            // Si la red bloquea WebSocket se usa REST + Server-Sent Events
+            let modoSSE = false;
+            let fallosWS = 0;
+            let streams = {};
+
+            function conectar() {
+                let abierto = false;
+                ws = new WebSocket("ws://" + location.host + "/ws");
+                ws.onopen = () => {
+                    abierto = true;
+                    fallosWS = 0;
+                    appendLog("info", "Conexión WebSocket establecida.");
+                };
+                ws.onclose = () => {
+                    if (!abierto && ++fallosWS >= 2) {
+                        modoSSE = true;
+                        appendLog("info", "WebSocket no disponible: usando Server-Sent Events.");
+                        Object.values(simulacionActual).filter(id => id).forEach(seguir);
+                        return;
+                    }
+                    appendLog("info", "WebSocket cerrado. Reconectando...");
+                    setTimeout(conectar, 2000);
+                };
+                ws.onerror = (e) => appendLog("info", "Error WebSocket: " + e);
+                ws.onmessage = manejarMensaje;
+            }
 
-            ws.onopen = () => appendLog("info", "Conexión WebSocket establecida.");
-            ws.onclose = () => appendLog("info", "WebSocket cerrado.");
-            ws.onerror = (e) => appendLog("info", "Error WebSocket: " + e);
-
-            ws.onmessage = (evt) => {
+            function manejarMensaje(evt) {
                 try {
                     const msg = JSON.parse(evt.data);
+
+                    // Los mensajes con seq ya vistos llegan repetidos al retomar
+                    if (msg.seq) {
+                        if (msg.seq <= (ultimoSeq[msg.simulacion_id] || 0)) return;
+                        ultimoSeq[msg.simulacion_id] = msg.seq;
+                    }
                     
                     if (msg.tipo === "registro") {
                         if (msg.topico === "mpi") {
@@ -811,13 +938,108 @@ const htmlSimulacion = `
                             appendLog("openmp", "<b>Resumen OpenMP:</b> " + JSON.stringify(msg.obj));
                             updateOpenmpStats(msg.obj);
                         }
-                    } else if (msg.tipo === "finalizado") {
-                        appendLog(msg.topico, "<i>Proceso " + msg.topico + " finalizado</i>");
+                    } else if (msg.tipo === "en_cola") {
+                        simulacionActual[msg.topico] = msg.simulacion_id;
+                        updateControls(msg.topico, "en_cola");
+                        appendLog(msg.topico, "<i>Simulación " + msg.simulacion_id + " en cola, posición " + msg.obj.posicion + "</i>");
+                    } else if (msg.tipo === "suscrito") {
+                        appendLog("info", "Suscripto a " + (msg.simulacion_id || "tópico " + msg.topico));
+                    } else if (msg.tipo === "presencia") {
+                        viewers.textContent = "Espectadores de " + msg.simulacion_id + ": " + msg.obj.espectadores;
+                    } else if (msg.tipo === "hello") {
+                        ws.send(JSON.stringify({action: "hello", version: msg.obj.version, cliente: "simulacion-web"}));
+                        Object.values(simulacionActual).filter(id => id).forEach((id) => {
+                            ws.send(JSON.stringify({action: "retomar", simulacion_id: id, ultimo_seq: ultimoSeq[id] || 0, token: tokens[id]}));
+                        });
+                    } else if (msg.tipo === "muestra") {
+                        mostrarMuestra(msg.obj);
+                    } else if (msg.tipo === "salto") {
+                        telemetria.puntos = [];
+                    } else if (msg.tipo === "aceptado") {
+                        if (msg.obj && msg.obj.token) tokens[msg.simulacion_id] = msg.obj.token;
+                        if (msg.topico === "telemetria" && msg.obj && msg.obj.duracion !== undefined) {
+                            telemetria = { duracion: msg.obj.duracion, puntos: [] };
+                            posicionTelemetria.max = msg.obj.duracion;
+                            posicionTelemetria.disabled = false;
+                        }
+                    } else if (msg.tipo === "servidor_apagandose") {
+                        // Después llega el cierre y onclose reintenta la conexión
+                        appendLog("info", "<b>" + msg.texto + "</b>");
+                    } else if (msg.tipo === "desborde") {
+                        appendLog("info", "Se descartaron " + msg.obj.descartados + " mensajes por conexión lenta");
+                    } else if (msg.tipo === "error") {
+                        appendLog(msg.topico || "info", "<b>Error (" + msg.codigo + "):</b> " + msg.texto);
+                    } else if (msg.tipo === "iniciado") {
+                        simulacionActual[msg.topico] = msg.simulacion_id;
+                        updateControls(msg.topico, "corriendo");
+                        appendLog(msg.topico, "<i>Simulación " + msg.simulacion_id + " iniciada</i>");
+                    } else if (msg.tipo === "pausado" || msg.tipo === "reanudado") {
+                        updateControls(msg.topico, msg.tipo === "pausado" ? "pausado" : "corriendo");
+                        appendLog(msg.topico, "<i>Simulación " + msg.simulacion_id + " " + msg.tipo + "</i>");
+                    } else if (msg.tipo === "finalizado" || msg.tipo === "cancelado") {
+                        if (simulacionActual[msg.topico] === msg.simulacion_id) {
+                            simulacionActual[msg.topico] = null;
+                            updateControls(msg.topico, null);
+                        }
+                        appendLog(msg.topico, "<i>Proceso " + msg.topico + " " + msg.tipo + (msg.codigo ? " (" + msg.texto + ")" : "") + "</i>");
+                        if (streams[msg.simulacion_id]) {
+                            streams[msg.simulacion_id].close();
+                            delete streams[msg.simulacion_id];
+                        }
                     }
                 } catch(e) {
                     appendLog("info", "Mensaje no JSON: " + evt.data);
                 }
-            };
+            }
+
+            // enviar manda un comando por WebSocket o, sin él, por la API REST
+            function enviar(cmd) {
+                if (!modoSSE) {
+                    ws.send(JSON.stringify(cmd));
+                    return;
+                }
+                if (cmd.action === "iniciar_mpi" || cmd.action === "iniciar_openmp") {
+                    const topico = cmd.action === "iniciar_mpi" ? "mpi" : "openmp";
+                    const cuerpo = {topico: topico, autos: cmd.autos, sectores: cmd.sectores, vueltas: cmd.vueltas};
+                    pedir("POST", "/api/simulaciones", cuerpo, topico).then((datos) => {
+                        if (!datos) return;
+                        tokens[datos.simulacion_id] = datos.token;
+                        simulacionActual[topico] = datos.simulacion_id;
+                        seguir(datos.simulacion_id);
+                    });
+                } else if (["pausar", "reanudar", "cancelar"].includes(cmd.action)) {
+                    const cuerpo = {accion: cmd.action, token: tokens[cmd.simulacion_id]};
+                    pedir("POST", "/api/simulaciones/" + cmd.simulacion_id + "/control", cuerpo, "info");
+                } else if (cmd.action === "suscribir" && cmd.simulacion_id) {
+                    seguir(cmd.simulacion_id);
+                } else {
+                    appendLog("info", "Sin WebSocket solo se puede mirar una simulación por ID.");
+                }
+            }
+
+            function pedir(metodo, url, cuerpo, topico) {
+                return fetch(url, {method: metodo, headers: {"Content-Type": "application/json"}, body: JSON.stringify(cuerpo)})
+                    .then((r) => r.json().then((datos) => {
+                        if (r.ok) return datos;
+                        appendLog(topico, "<b>Error:</b> " + sanitize(datos.error));
+                        return null;
+                    }))
+                    .catch((e) => appendLog(topico, "<b>Error:</b> " + sanitize(String(e))));
+            }
+
+            // seguir abre el stream SSE de una simulación; al reconectarse, el
+            // navegador manda Last-Event-ID y solo recibe lo que se perdió
+            function seguir(id) {
+                if (streams[id]) return;
+                const es = new EventSource("/api/simulaciones/" + id + "/stream?ultimo_seq=" + (ultimoSeq[id] || 0));
+                streams[id] = es;
+                es.onmessage = manejarMensaje;
+                es.onerror = () => {
+                    if (es.readyState === EventSource.CLOSED) delete streams[id];
+                };
+            }
+
+            conectar();
 
             // Configurar eventos de botones
             document.getElementById("start-mpi").addEventListener("click", () => {
@@ -832,7 +1054,7 @@ const htmlSimulacion = `
                 mpiStats = { bestTime: Infinity, sectorsProcessed: 0 };
                 updateMpiStats();
                 
-                ws.send(JSON.stringify({action: "iniciar_mpi", sectores: sectores, vueltas: vueltas}));
+                enviar({action: "iniciar_mpi", sectores: sectores, vueltas: vueltas});
                 appendLog("mpi", "<b>Comando enviado: iniciar MPI</b>");
             });
 
@@ -847,11 +1069,88 @@ const htmlSimulacion = `
                 openmpStats = { bestTime: Infinity, lapsCompleted: 0 };
                 updateOpenmpStats();
                 
-                ws.send(JSON.stringify({action: "iniciar_openmp", autos: autos, vueltas: vueltas}));
+                enviar({action: "iniciar_openmp", autos: autos, vueltas: vueltas});
                 appendLog("openmp", "<b>Comando enviado: iniciar OpenMP</b>");
             });
 
+            document.getElementById("start-telemetria").addEventListener("click", () => {
+                const sesion = parseInt(document.getElementById("telemetria-sesion").value);
+                const velocidad = parseFloat(document.getElementById("telemetria-velocidad").value);
+                if (!sesion) return;
+                if (modoSSE) {
+                    appendLog("info", "La reproducción de telemetría necesita WebSocket.");
+                    return;
+                }
+                enviar({action: "reproducir_telemetria", sesion_id: sesion, velocidad: velocidad});
+                appendLog("info", "<b>Comando enviado: reproducir sesión " + sesion + "</b>");
+            });
+
+            document.getElementById("telemetria-velocidad").addEventListener("change", (e) => {
+                if (!simulacionActual.telemetria || modoSSE) return;
+                enviar({action: "velocidad", simulacion_id: simulacionActual.telemetria, velocidad: parseFloat(e.target.value)});
+            });
+
+            posicionTelemetria.addEventListener("change", () => {
+                if (!simulacionActual.telemetria || modoSSE) return;
+                enviar({action: "saltar", simulacion_id: simulacionActual.telemetria, tiempo: parseFloat(posicionTelemetria.value)});
+            });
+
+            ["mpi", "openmp", "telemetria"].forEach((topico) => {
+                ["pause", "resume", "cancel"].forEach((control) => {
+                    const action = { pause: "pausar", resume: "reanudar", cancel: "cancelar" }[control];
+                    document.getElementById(control + "-" + topico).addEventListener("click", () => {
+                        if (!simulacionActual[topico]) return;
+                        enviar({action: action, simulacion_id: simulacionActual[topico]});
+                    });
+                });
+            });
+
+            document.getElementById("watch-btn").addEventListener("click", () => {
+                const target = document.getElementById("watch-target").value.trim();
+                if (!target) return;
+                if (target === "mpi" || target === "openmp") {
+                    enviar({action: "suscribir", topico: target});
+                } else {
+                    enviar({action: "suscribir", simulacion_id: target});
+                }
+            });
+
             // Funciones auxiliares
+            function mostrarMuestra(m) {
+                const t = m.transcurrido;
+                document.getElementById("telemetria-kmh").textContent = m.velocidad.toFixed(0) + " km/h";
+                document.getElementById("telemetria-rpm").textContent = m.rpm.toFixed(0) + " / " + m.marcha;
+                document.getElementById("telemetria-pedales").textContent = m.acelerador.toFixed(0) + "% / " + m.freno.toFixed(0) + "%";
+                document.getElementById("telemetria-tiempo").textContent = t.toFixed(1) + " / " + telemetria.duracion.toFixed(1) + " s";
+                if (document.activeElement !== posicionTelemetria) posicionTelemetria.value = t;
+
+                // Se grafican los últimos 30 s de velocidad
+                telemetria.puntos.push([t, m.velocidad]);
+                while (telemetria.puntos.length && telemetria.puntos[0][0] < t - 30) telemetria.puntos.shift();
+                dibujarTraza(t);
+            }
+
+            function dibujarTraza(ahora) {
+                const ctx = trazaTelemetria.getContext("2d");
+                const w = trazaTelemetria.width, h = trazaTelemetria.height;
+                ctx.clearRect(0, 0, w, h);
+                ctx.strokeStyle = "#e10600";
+                ctx.lineWidth = 2;
+                ctx.beginPath();
+                telemetria.puntos.forEach(([t, v], i) => {
+                    const x = w - (ahora - t) / 30 * w;
+                    const y = h - Math.min(v, 360) / 360 * h;
+                    if (i === 0) ctx.moveTo(x, y); else ctx.lineTo(x, y);
+                });
+                ctx.stroke();
+            }
+
+            function updateControls(topico, estado) {
+                document.getElementById("pause-" + topico).disabled = estado !== "corriendo";
+                document.getElementById("resume-" + topico).disabled = estado !== "pausado";
+                document.getElementById("cancel-" + topico).disabled = estado === null;
+            }
+
             function appendLog(type, text) {
                 const target = type === "mpi" ? mpiLog : 
                               type === "openmp" ? openmpLog : 
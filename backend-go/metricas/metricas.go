@@ -0,0 +1,42 @@
+// Package metricas reúne las métricas Prometheus del backend. Cada paquete
+// declara las suyas sobre Registro con promauto y main expone el conjunto
+// en /metrics.
+package metricas
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Espacio es el prefijo de todas las métricas propias.
+const Espacio = "formula1"
+
+// Registro tiene solo las métricas de este proceso, más las del runtime de
+// Go y del proceso, en lugar del registro global de la biblioteca.
+var Registro = prometheus.NewRegistry()
+
+// BucketsCalculo va de 1 ms a unos 30 s, para cálculos y simulaciones
+// cuyo costo depende de lo que pida el cliente.
+var BucketsCalculo = prometheus.ExponentialBuckets(0.001, 2, 16)
+
+func init() {
+	Registro.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Manejador responde las métricas en el formato de texto de Prometheus.
+func Manejador() http.Handler {
+	return promhttp.HandlerFor(Registro, promhttp.HandlerOpts{Registry: Registro})
+}
+
+// RegistrarBaseDeDatos publica las estadísticas del pool de conexiones
+// (abiertas, en uso, esperas, ...) con la etiqueta db_name=nombre.
+func RegistrarBaseDeDatos(db *sql.DB, nombre string) error {
+	return Registro.Register(collectors.NewDBStatsCollector(db, nombre))
+}
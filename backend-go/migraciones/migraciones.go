@@ -0,0 +1,424 @@
+package migraciones
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Las migraciones son pares NNNN_nombre.up.sql / NNNN_nombre.down.sql, con
+// un directorio por motor y las mismas versiones en ambos. Una vez
+// publicada, una migración no se edita: los cambios van en una nueva.
+//
+//go:embed sql/postgres/*.sql sql/sqlite/*.sql
+var archivos embed.FS
+
+// Clave del advisory lock de PostgreSQL que toma quien migra, para que dos
+// réplicas del backend no migren a la vez
+const claveBloqueo int64 = 0x466f726d756c6131 // "Formula1"
+
+// Dialecto es el motor de la base, con el nombre que usa gorm.Dialector.Name.
+type Dialecto string
+
+const (
+	DialectoPostgres Dialecto = "postgres"
+	DialectoSQLite   Dialecto = "sqlite"
+)
+
+// sentencias son las consultas propias de cada motor.
+type sentencias struct {
+	bloquear    string // vacío = sin bloqueo
+	desbloquear string
+	crearTabla  string
+	existeTabla string // consulta que dice si schema_migrations existe
+	registrar   string
+	borrar      string
+}
+
+var sentenciasPorDialecto = map[Dialecto]sentencias{
+	DialectoPostgres: {
+		bloquear:    "SELECT pg_advisory_lock($1)",
+		desbloquear: "SELECT pg_advisory_unlock($1)",
+		crearTabla: `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version BIGINT PRIMARY KEY,
+    nombre TEXT NOT NULL,
+    aplicada_en TIMESTAMPTZ NOT NULL DEFAULT now()
+)`,
+		existeTabla: "SELECT to_regclass('schema_migrations') IS NOT NULL",
+		registrar:   "INSERT INTO schema_migrations (version, nombre) VALUES ($1, $2)",
+		borrar:      "DELETE FROM schema_migrations WHERE version = $1",
+	},
+	// SQLite no tiene advisory locks: el archivo lo usa un solo proceso y, si
+	// dos migraran a la vez, la clave primaria de schema_migrations hace
+	// fallar (y deshacer) la transacción del segundo
+	DialectoSQLite: {
+		crearTabla: `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    nombre TEXT NOT NULL,
+    aplicada_en DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`,
+		existeTabla: "SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations')",
+		registrar:   "INSERT INTO schema_migrations (version, nombre) VALUES (?, ?)",
+		borrar:      "DELETE FROM schema_migrations WHERE version = ?",
+	},
+}
+
+// Migracion es un cambio de esquema con su vuelta atrás.
+type Migracion struct {
+	Version int64
+	Nombre  string
+	Subir   string
+	Bajar   string
+}
+
+// Estado es una migración conocida o aplicada.
+type Estado struct {
+	Version    int64      `json:"version"`
+	Nombre     string     `json:"nombre"`
+	Aplicada   bool       `json:"aplicada"`
+	AplicadaEn *time.Time `json:"aplicada_en,omitempty"`
+	// Aplicada en la base pero desconocida para este binario (uno más nuevo)
+	Desconocida bool `json:"desconocida,omitempty"`
+}
+
+// Paso es una migración aplicada o revertida por Migrador.
+type Paso struct {
+	Version  int64
+	Nombre   string
+	Subida   bool
+	Duracion time.Duration
+}
+
+// Cargar lee las migraciones de un directorio, ordenadas por versión.
+func Cargar(fsys fs.FS) ([]Migracion, error) {
+	nombres, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	porVersion := map[int64]*Migracion{}
+	for _, nombre := range nombres {
+		base, sentido, ok := cortarSentido(nombre)
+		if !ok {
+			return nil, fmt.Errorf("migración %s: el nombre debe terminar en .up.sql o .down.sql", nombre)
+		}
+		numero, descripcion, ok := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(numero, 10, 64)
+		if !ok || err != nil || version < 1 {
+			return nil, fmt.Errorf("migración %s: el nombre debe empezar con un número positivo y _", nombre)
+		}
+		contenido, err := fs.ReadFile(fsys, nombre)
+		if err != nil {
+			return nil, err
+		}
+
+		m := porVersion[version]
+		if m == nil {
+			m = &Migracion{Version: version, Nombre: descripcion}
+			porVersion[version] = m
+		} else if m.Nombre != descripcion {
+			return nil, fmt.Errorf("migración %d: dos nombres distintos (%s y %s)", version, m.Nombre, descripcion)
+		}
+		if sentido == "up" {
+			m.Subir = string(contenido)
+		} else {
+			m.Bajar = string(contenido)
+		}
+	}
+
+	migraciones := make([]Migracion, 0, len(porVersion))
+	for _, m := range porVersion {
+		if strings.TrimSpace(m.Subir) == "" || strings.TrimSpace(m.Bajar) == "" {
+			return nil, fmt.Errorf("migración %d_%s: faltan el .up.sql o el .down.sql", m.Version, m.Nombre)
+		}
+		migraciones = append(migraciones, *m)
+	}
+	sort.Slice(migraciones, func(i, j int) bool { return migraciones[i].Version < migraciones[j].Version })
+	return migraciones, nil
+}
+
+func cortarSentido(nombre string) (string, string, bool) {
+	nombre = path.Base(nombre)
+	if base, ok := strings.CutSuffix(nombre, ".up.sql"); ok {
+		return base, "up", true
+	}
+	if base, ok := strings.CutSuffix(nombre, ".down.sql"); ok {
+		return base, "down", true
+	}
+	return "", "", false
+}
+
+// -------------------- Migrador --------------------
+
+// Migrador aplica y revierte migraciones registrándolas en schema_migrations.
+// Cada migración corre en su propia transacción junto con su registro, así
+// que una que falla no deja la base a medias.
+type Migrador struct {
+	db          *sql.DB
+	sentencias  sentencias
+	migraciones []Migracion
+	// Registro recibe cada paso a medida que se aplica; puede ser nil
+	Registro func(Paso)
+}
+
+// NuevoMigrador usa las migraciones del motor incluidas en el binario.
+func NuevoMigrador(db *sql.DB, dialecto Dialecto) (*Migrador, error) {
+	s, ok := sentenciasPorDialecto[dialecto]
+	if !ok {
+		return nil, fmt.Errorf("no hay migraciones para el motor %q", dialecto)
+	}
+	directorio, err := fs.Sub(archivos, path.Join("sql", string(dialecto)))
+	if err != nil {
+		return nil, err
+	}
+	migraciones, err := Cargar(directorio)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrador{db: db, sentencias: s, migraciones: migraciones}, nil
+}
+
+// Ultima es la versión más nueva que conoce el binario.
+func (m *Migrador) Ultima() int64 {
+	if len(m.migraciones) == 0 {
+		return 0
+	}
+	return m.migraciones[len(m.migraciones)-1].Version
+}
+
+// Subir aplica todas las migraciones pendientes.
+func (m *Migrador) Subir(ctx context.Context) ([]Paso, error) {
+	return m.IrA(ctx, m.Ultima())
+}
+
+// Bajar revierte las últimas pasos migraciones aplicadas.
+func (m *Migrador) Bajar(ctx context.Context, pasos int) ([]Paso, error) {
+	if pasos < 1 {
+		return nil, errors.New("hay que revertir al menos una migración")
+	}
+	var hechos []Paso
+	err := m.conBloqueo(ctx, func(conn *sql.Conn) error {
+		aplicadas, err := leerAplicadas(ctx, conn)
+		if err != nil {
+			return err
+		}
+		versiones := make([]int64, 0, len(aplicadas))
+		for v := range aplicadas {
+			versiones = append(versiones, v)
+		}
+		sort.Slice(versiones, func(i, j int) bool { return versiones[i] > versiones[j] })
+		destino := int64(0)
+		if pasos < len(versiones) {
+			destino = versiones[pasos]
+		}
+		hechos, err = m.migrar(ctx, conn, aplicadas, destino)
+		return err
+	})
+	return hechos, err
+}
+
+// IrA sube o baja hasta dejar aplicadas exactamente las migraciones con
+// versión menor o igual a version (0 revierte todas).
+func (m *Migrador) IrA(ctx context.Context, version int64) ([]Paso, error) {
+	if version < 0 || version > m.Ultima() {
+		return nil, fmt.Errorf("versión %d desconocida: la última es %d", version, m.Ultima())
+	}
+	var hechos []Paso
+	err := m.conBloqueo(ctx, func(conn *sql.Conn) error {
+		aplicadas, err := leerAplicadas(ctx, conn)
+		if err != nil {
+			return err
+		}
+		hechos, err = m.migrar(ctx, conn, aplicadas, version)
+		return err
+	})
+	return hechos, err
+}
+
+// Estado lista las migraciones conocidas y las aplicadas, por versión. Solo
+// lee: no toma el bloqueo ni crea schema_migrations, así que se puede
+// consultar seguido (lo hace /readyz) aunque otra réplica esté migrando.
+func (m *Migrador) Estado(ctx context.Context) ([]Estado, error) {
+	aplicadas, err := m.aplicadas(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var estados []Estado
+	for _, mig := range m.migraciones {
+		e := Estado{Version: mig.Version, Nombre: mig.Nombre}
+		if a, ok := aplicadas[mig.Version]; ok {
+			e.Aplicada, e.AplicadaEn = true, &a.en
+			delete(aplicadas, mig.Version)
+		}
+		estados = append(estados, e)
+	}
+	for v, a := range aplicadas {
+		estados = append(estados, Estado{Version: v, Nombre: a.nombre, Aplicada: true, AplicadaEn: &a.en, Desconocida: true})
+	}
+	sort.Slice(estados, func(i, j int) bool { return estados[i].Version < estados[j].Version })
+	return estados, nil
+}
+
+// Pendientes cuenta las migraciones conocidas que faltan aplicar.
+func (m *Migrador) Pendientes(ctx context.Context) (int, error) {
+	estados, err := m.Estado(ctx)
+	pendientes := 0
+	for _, e := range estados {
+		if !e.Aplicada {
+			pendientes++
+		}
+	}
+	return pendientes, err
+}
+
+type aplicada struct {
+	nombre string
+	en     time.Time
+}
+
+// migrar revierte las aplicadas por encima de destino, de la más nueva a la
+// más vieja, y después aplica las que faltan hasta destino, en orden.
+func (m *Migrador) migrar(ctx context.Context, conn *sql.Conn, aplicadas map[int64]aplicada, destino int64) ([]Paso, error) {
+	conocidas := make(map[int64]Migracion, len(m.migraciones))
+	for _, mig := range m.migraciones {
+		conocidas[mig.Version] = mig
+	}
+
+	var bajar []Migracion
+	for v, a := range aplicadas {
+		if v <= destino {
+			continue
+		}
+		mig, ok := conocidas[v]
+		if !ok {
+			return nil, fmt.Errorf("la migración %d_%s está aplicada pero este binario no la conoce: revertila con la versión que la agregó", v, a.nombre)
+		}
+		bajar = append(bajar, mig)
+	}
+	sort.Slice(bajar, func(i, j int) bool { return bajar[i].Version > bajar[j].Version })
+
+	var hechos []Paso
+	for _, mig := range bajar {
+		paso, err := m.ejecutar(ctx, conn, mig, false)
+		if err != nil {
+			return hechos, err
+		}
+		hechos = append(hechos, paso)
+	}
+	for _, mig := range m.migraciones {
+		if _, ok := aplicadas[mig.Version]; ok || mig.Version > destino {
+			continue
+		}
+		paso, err := m.ejecutar(ctx, conn, mig, true)
+		if err != nil {
+			return hechos, err
+		}
+		hechos = append(hechos, paso)
+	}
+	return hechos, nil
+}
+
+func (m *Migrador) ejecutar(ctx context.Context, conn *sql.Conn, mig Migracion, subir bool) (Paso, error) {
+	paso := Paso{Version: mig.Version, Nombre: mig.Nombre, Subida: subir}
+	inicio := time.Now()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return paso, err
+	}
+	defer tx.Rollback()
+
+	sentencia, registro, args := mig.Subir, m.sentencias.registrar, []any{mig.Version, mig.Nombre}
+	if !subir {
+		sentencia, registro, args = mig.Bajar, m.sentencias.borrar, []any{mig.Version}
+	}
+	if _, err := tx.ExecContext(ctx, sentencia); err != nil {
+		return paso, fmt.Errorf("migración %d_%s (%s): %w", mig.Version, mig.Nombre, sentido(subir), err)
+	}
+	if _, err := tx.ExecContext(ctx, registro, args...); err != nil {
+		return paso, err
+	}
+	if err := tx.Commit(); err != nil {
+		return paso, err
+	}
+
+	paso.Duracion = time.Since(inicio)
+	if m.Registro != nil {
+		m.Registro(paso)
+	}
+	return paso, nil
+}
+
+func sentido(subir bool) string {
+	if subir {
+		return "up"
+	}
+	return "down"
+}
+
+// conBloqueo toma el advisory lock (en PostgreSQL) en una conexión propia
+// (el bloqueo es de la sesión, no del pool), crea schema_migrations si falta
+// y corre f. Si otra réplica está migrando, espera a que termine.
+func (m *Migrador) conBloqueo(ctx context.Context, f func(*sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.sentencias.bloquear != "" {
+		if _, err := conn.ExecContext(ctx, m.sentencias.bloquear, claveBloqueo); err != nil {
+			return fmt.Errorf("no se pudo tomar el bloqueo de migraciones: %w", err)
+		}
+		// Se libera aunque ctx se haya cancelado
+		defer conn.ExecContext(context.Background(), m.sentencias.desbloquear, claveBloqueo)
+	}
+
+	if _, err := conn.ExecContext(ctx, m.sentencias.crearTabla); err != nil {
+		return err
+	}
+	return f(conn)
+}
+
+// aplicadas lee schema_migrations con cualquier conexión del pool, sin
+// bloqueo ni DDL. Si la tabla todavía no existe no hay ninguna aplicada.
+func (m *Migrador) aplicadas(ctx context.Context) (map[int64]aplicada, error) {
+	var existe bool
+	if err := m.db.QueryRowContext(ctx, m.sentencias.existeTabla).Scan(&existe); err != nil {
+		return nil, err
+	}
+	if !existe {
+		return map[int64]aplicada{}, nil
+	}
+	return leerAplicadas(ctx, m.db)
+}
+
+// consultor es una conexión propia (*sql.Conn) o el pool (*sql.DB).
+type consultor interface {
+	QueryContext(ctx context.Context, consulta string, args ...any) (*sql.Rows, error)
+}
+
+func leerAplicadas(ctx context.Context, conn consultor) (map[int64]aplicada, error) {
+	filas, err := conn.QueryContext(ctx, "SELECT version, nombre, aplicada_en FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer filas.Close()
+	aplicadas := map[int64]aplicada{}
+	for filas.Next() {
+		var v int64
+		var a aplicada
+		if err := filas.Scan(&v, &a.nombre, &a.en); err != nil {
+			return nil, err
+		}
+		aplicadas[v] = a
+	}
+	return aplicadas, filas.Err()
+}
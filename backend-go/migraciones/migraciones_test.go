@@ -0,0 +1,69 @@
+package migraciones
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/glebarez/go-sqlite"
+)
+
+func migradorSQLite(t *testing.T) (*Migrador, *sql.DB) {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "formula1.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	m, err := NuevoMigrador(db, DialectoSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, db
+}
+
+func existeSchemaMigrations(t *testing.T, db *sql.DB) bool {
+	t.Helper()
+	var existe bool
+	if err := db.QueryRow(sentenciasPorDialecto[DialectoSQLite].existeTabla).Scan(&existe); err != nil {
+		t.Fatal(err)
+	}
+	return existe
+}
+
+// Consultar el estado, como hace /readyz, no crea schema_migrations: en una
+// base nueva todas quedan pendientes.
+func TestEstadoSoloLee(t *testing.T) {
+	m, db := migradorSQLite(t)
+	ctx := context.Background()
+
+	pendientes, err := m.Pendientes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pendientes != len(m.migraciones) {
+		t.Fatalf("%d pendientes, se esperaban %d", pendientes, len(m.migraciones))
+	}
+	if existeSchemaMigrations(t, db) {
+		t.Fatal("consultar el estado creó schema_migrations")
+	}
+
+	if _, err := m.Subir(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if pendientes, err := m.Pendientes(ctx); err != nil || pendientes != 0 {
+		t.Fatalf("tras subir: %d pendientes, %v", pendientes, err)
+	}
+
+	if _, err := m.Bajar(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	estados, err := m.Estado(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ultimo := estados[len(estados)-1]; ultimo.Aplicada || ultimo.Version != m.Ultima() {
+		t.Fatalf("la última debería estar pendiente: %+v", ultimo)
+	}
+}
@@ -0,0 +1,31 @@
+-- Pilotos, con las columnas de gorm.Model. Las bases creadas con AutoMigrate
+-- ya tienen la tabla y las creadas con el antiguo init-db.sql no tienen las
+-- fechas: esta migración deja a ambas igual.
+CREATE TABLE IF NOT EXISTS pilotos (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    nombre TEXT NOT NULL,
+    equipo TEXT,
+    nacionalidad TEXT,
+    numero BIGINT,
+    victorias BIGINT,
+    puntos DECIMAL,
+    podios BIGINT,
+    poles BIGINT,
+    vueltas_rapidas BIGINT
+);
+
+ALTER TABLE pilotos ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ;
+ALTER TABLE pilotos ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ;
+ALTER TABLE pilotos ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+-- El nombre es único entre los pilotos no eliminados: gorm elimina marcando
+-- deleted_at y el nombre de un piloto eliminado se puede volver a usar. Si
+-- la base ya tenía el índice sobre todas las filas, se reemplaza.
+DROP INDEX IF EXISTS idx_pilotos_nombre;
+CREATE UNIQUE INDEX idx_pilotos_nombre ON pilotos (nombre) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_pilotos_equipo ON pilotos (equipo);
+CREATE INDEX IF NOT EXISTS idx_pilotos_nacionalidad ON pilotos (nacionalidad);
+CREATE INDEX IF NOT EXISTS idx_pilotos_deleted_at ON pilotos (deleted_at);
@@ -0,0 +1,48 @@
+-- Eventos, sesiones de telemetría y sus muestras (ver models/telemetria.go)
+CREATE TABLE IF NOT EXISTS eventos (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    nombre TEXT NOT NULL,
+    temporada BIGINT,
+    circuito TEXT
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_eventos_nombre_temporada ON eventos (nombre, temporada);
+CREATE INDEX IF NOT EXISTS idx_eventos_deleted_at ON eventos (deleted_at);
+
+CREATE TABLE IF NOT EXISTS sesiones_telemetria (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    piloto_id BIGINT NOT NULL,
+    evento_id BIGINT NOT NULL,
+    sesion TEXT,
+    archivo TEXT,
+    formato TEXT,
+    canales TEXT,
+    muestras BIGINT,
+    filas_con_error BIGINT,
+    CONSTRAINT fk_sesiones_telemetria_piloto FOREIGN KEY (piloto_id) REFERENCES pilotos (id),
+    CONSTRAINT fk_sesiones_telemetria_evento FOREIGN KEY (evento_id) REFERENCES eventos (id)
+);
+CREATE INDEX IF NOT EXISTS idx_sesiones_telemetria_piloto_id ON sesiones_telemetria (piloto_id);
+CREATE INDEX IF NOT EXISTS idx_sesiones_telemetria_evento_id ON sesiones_telemetria (evento_id);
+CREATE INDEX IF NOT EXISTS idx_sesiones_telemetria_deleted_at ON sesiones_telemetria (deleted_at);
+
+CREATE TABLE IF NOT EXISTS muestras_telemetria (
+    id BIGSERIAL PRIMARY KEY,
+    sesion_id BIGINT NOT NULL,
+    indice BIGINT NOT NULL,
+    tiempo DECIMAL,
+    distancia DECIMAL,
+    velocidad DECIMAL,
+    rpm DECIMAL,
+    marcha BIGINT,
+    acelerador DECIMAL,
+    freno DECIMAL,
+    drs BOOLEAN,
+    vuelta BIGINT
+);
+CREATE INDEX IF NOT EXISTS idx_muestras_sesion_indice ON muestras_telemetria (sesion_id, indice);
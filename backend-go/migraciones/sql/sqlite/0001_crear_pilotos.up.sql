@@ -0,0 +1,23 @@
+-- Pilotos, con las columnas de gorm.Model
+CREATE TABLE IF NOT EXISTS pilotos (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    deleted_at DATETIME,
+    nombre TEXT NOT NULL,
+    equipo TEXT,
+    nacionalidad TEXT,
+    numero INTEGER,
+    victorias INTEGER,
+    puntos REAL,
+    podios INTEGER,
+    poles INTEGER,
+    vueltas_rapidas INTEGER
+);
+
+-- El nombre es único entre los pilotos no eliminados: gorm elimina marcando
+-- deleted_at y el nombre de un piloto eliminado se puede volver a usar
+CREATE UNIQUE INDEX IF NOT EXISTS idx_pilotos_nombre ON pilotos (nombre) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_pilotos_equipo ON pilotos (equipo);
+CREATE INDEX IF NOT EXISTS idx_pilotos_nacionalidad ON pilotos (nacionalidad);
+CREATE INDEX IF NOT EXISTS idx_pilotos_deleted_at ON pilotos (deleted_at);
@@ -0,0 +1,2 @@
+DELETE FROM pilotos
+WHERE nombre IN ('Max Verstappen', 'Lewis Hamilton', 'Charles Leclerc', 'Fernando Alonso', 'Carlos Sainz');
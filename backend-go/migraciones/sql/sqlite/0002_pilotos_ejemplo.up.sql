@@ -0,0 +1,8 @@
+-- Pilotos de ejemplo; los que ya existen (por nombre, sin eliminar) no se tocan
+INSERT INTO pilotos (created_at, updated_at, nombre, equipo, nacionalidad, numero, victorias, puntos, podios, poles, vueltas_rapidas) VALUES
+(CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, 'Max Verstappen', 'Red Bull', 'Holandés', 1, 54, 575.5, 98, 33, 30),
+(CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, 'Lewis Hamilton', 'Mercedes', 'Británico', 44, 103, 4637.5, 197, 104, 65),
+(CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, 'Charles Leclerc', 'Ferrari', 'Monegasco', 16, 5, 1074, 32, 23, 9),
+(CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, 'Fernando Alonso', 'Aston Martin', 'Español', 14, 32, 2267, 106, 22, 24),
+(CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, 'Carlos Sainz', 'Ferrari', 'Español', 55, 2, 782.5, 18, 5, 3)
+ON CONFLICT (nombre) WHERE deleted_at IS NULL DO NOTHING;
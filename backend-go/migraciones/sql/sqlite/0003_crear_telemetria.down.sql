@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS muestras_telemetria;
+DROP TABLE IF EXISTS sesiones_telemetria;
+DROP TABLE IF EXISTS eventos;
@@ -0,0 +1,48 @@
+-- Eventos, sesiones de telemetría y sus muestras (ver models/telemetria.go)
+CREATE TABLE IF NOT EXISTS eventos (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    deleted_at DATETIME,
+    nombre TEXT NOT NULL,
+    temporada INTEGER,
+    circuito TEXT
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_eventos_nombre_temporada ON eventos (nombre, temporada);
+CREATE INDEX IF NOT EXISTS idx_eventos_deleted_at ON eventos (deleted_at);
+
+CREATE TABLE IF NOT EXISTS sesiones_telemetria (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    deleted_at DATETIME,
+    piloto_id INTEGER NOT NULL,
+    evento_id INTEGER NOT NULL,
+    sesion TEXT,
+    archivo TEXT,
+    formato TEXT,
+    canales TEXT,
+    muestras INTEGER,
+    filas_con_error INTEGER,
+    CONSTRAINT fk_sesiones_telemetria_piloto FOREIGN KEY (piloto_id) REFERENCES pilotos (id),
+    CONSTRAINT fk_sesiones_telemetria_evento FOREIGN KEY (evento_id) REFERENCES eventos (id)
+);
+CREATE INDEX IF NOT EXISTS idx_sesiones_telemetria_piloto_id ON sesiones_telemetria (piloto_id);
+CREATE INDEX IF NOT EXISTS idx_sesiones_telemetria_evento_id ON sesiones_telemetria (evento_id);
+CREATE INDEX IF NOT EXISTS idx_sesiones_telemetria_deleted_at ON sesiones_telemetria (deleted_at);
+
+CREATE TABLE IF NOT EXISTS muestras_telemetria (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    sesion_id INTEGER NOT NULL,
+    indice INTEGER NOT NULL,
+    tiempo REAL,
+    distancia REAL,
+    velocidad REAL,
+    rpm REAL,
+    marcha INTEGER,
+    acelerador REAL,
+    freno REAL,
+    drs BOOLEAN,
+    vuelta INTEGER
+);
+CREATE INDEX IF NOT EXISTS idx_muestras_sesion_indice ON muestras_telemetria (sesion_id, indice);
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"formula1-crud-go/telemetria"
+
+	"gorm.io/gorm"
+)
+
+// Evento es un Gran Premio (o test) de una temporada.
+type Evento struct {
+	gorm.Model
+	Nombre    string `json:"nombre" gorm:"not null;uniqueIndex:idx_eventos_nombre_temporada"`
+	Temporada int    `json:"temporada" gorm:"uniqueIndex:idx_eventos_nombre_temporada"`
+	Circuito  string `json:"circuito"`
+}
+
+// SesionTelemetria agrupa las muestras subidas de un piloto en un evento.
+type SesionTelemetria struct {
+	gorm.Model
+	PilotoID      uint    `json:"piloto_id" gorm:"not null;index"`
+	Piloto        *Piloto `json:"piloto,omitempty"`
+	EventoID      uint    `json:"evento_id" gorm:"not null;index"`
+	Evento        *Evento `json:"evento,omitempty"`
+	Sesion        string  `json:"sesion"` // FP1, Q, R...
+	Archivo       string  `json:"archivo"`
+	Formato       string  `json:"formato"`
+	Canales       string  `json:"canales"` // separados por coma
+	Muestras      int     `json:"muestras"`
+	FilasConError int     `json:"filas_con_error"`
+}
+
+// MuestraTelemetria es una fila de una sesión, en el orden del archivo.
+type MuestraTelemetria struct {
+	ID       uint `json:"-" gorm:"primaryKey"`
+	SesionID uint `json:"-" gorm:"not null;index:idx_muestras_sesion_indice,priority:1"`
+	Indice   int  `json:"indice" gorm:"not null;index:idx_muestras_sesion_indice,priority:2"`
+	telemetria.Muestra
+}
+
+func (MuestraTelemetria) TableName() string {
+	return "muestras_telemetria"
+}
+
+func (SesionTelemetria) TableName() string {
+	return "sesiones_telemetria"
+}
@@ -0,0 +1,142 @@
+// Package registro arma los logs estructurados (log/slog) del backend: un
+// logger por subsistema, cada uno con su nivel, y el ID del pedido HTTP que
+// viaja en el contexto hasta las consultas SQL y las simulaciones, junto
+// con la traza OpenTelemetry si hay una.
+package registro
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Subsistema separa los logs para poder subir o bajar el nivel de cada uno.
+type Subsistema string
+
+const (
+	App  Subsistema = "app"  // arranque, apagado y lo que no encaja en otro
+	HTTP Subsistema = "http" // un registro por pedido
+	DB   Subsistema = "db"   // conexión, migraciones y consultas SQL
+	WS   Subsistema = "ws"   // conexiones WebSocket y SSE
+	Sim  Subsistema = "sim"  // simulaciones
+)
+
+// Opciones configura la salida de todos los loggers.
+type Opciones struct {
+	Texto   bool       // texto legible en lugar de JSON
+	Nivel   slog.Level // nivel de los subsistemas sin uno propio
+	Niveles map[Subsistema]slog.Level
+}
+
+// estado es la salida y los niveles vigentes. Los loggers se crean como
+// variables de paquete, antes de leer la configuración, así que lo
+// consultan en cada registro en lugar de copiarlo.
+type estado struct {
+	salida  slog.Handler
+	nivel   slog.Level
+	niveles map[Subsistema]slog.Level
+}
+
+var actual atomic.Pointer[estado]
+
+func init() {
+	actual.Store(nuevoEstado(os.Stderr, Opciones{}))
+	slog.SetDefault(Para(App))
+}
+
+// Configurar reemplaza la salida y los niveles de todos los loggers. Los
+// logs van a la salida de errores: la estándar es de los subcomandos.
+func Configurar(o Opciones) {
+	actual.Store(nuevoEstado(os.Stderr, o))
+}
+
+func nuevoEstado(w io.Writer, o Opciones) *estado {
+	// El manejador filtra por subsistema; la salida deja pasar todo
+	opciones := &slog.HandlerOptions{Level: slog.LevelDebug - 4}
+	e := &estado{nivel: o.Nivel, niveles: o.Niveles}
+	if o.Texto {
+		e.salida = slog.NewTextHandler(w, opciones)
+	} else {
+		e.salida = slog.NewJSONHandler(w, opciones)
+	}
+	return e
+}
+
+func (e *estado) nivelDe(s Subsistema) slog.Level {
+	if nivel, ok := e.niveles[s]; ok {
+		return nivel
+	}
+	return e.nivel
+}
+
+// Para devuelve el logger de un subsistema. Cada registro lleva el atributo
+// "subsistema" y, si el contexto los tiene, "id_pedido" y "traza_id" y
+// "span_id", para encontrar la traza de un log y viceversa.
+func Para(s Subsistema) *slog.Logger {
+	return slog.New(&manejador{subsistema: s}).With("subsistema", string(s))
+}
+
+// -------------------- ID de pedido --------------------
+
+type claveIDPedido struct{}
+
+// ConIDPedido guarda en ctx el ID del pedido HTTP que lo originó.
+func ConIDPedido(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, claveIDPedido{}, id)
+}
+
+// IDPedido devuelve el ID guardado con ConIDPedido, o "".
+func IDPedido(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(claveIDPedido{}).(string)
+	return id
+}
+
+// -------------------- Manejador --------------------
+
+// manejador aplica el nivel del subsistema y agrega el ID de pedido y la
+// traza antes de pasar el registro a la salida vigente. Guarda los With y
+// WithGroup para repetirlos sobre esa salida, que puede cambiar con
+// Configurar.
+type manejador struct {
+	subsistema Subsistema
+	pasos      []func(slog.Handler) slog.Handler
+}
+
+func (m *manejador) Enabled(_ context.Context, nivel slog.Level) bool {
+	return nivel >= actual.Load().nivelDe(m.subsistema)
+}
+
+func (m *manejador) Handle(ctx context.Context, r slog.Record) error {
+	if id := IDPedido(ctx); id != "" {
+		r.AddAttrs(slog.String("id_pedido", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("traza_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	salida := actual.Load().salida
+	for _, paso := range m.pasos {
+		salida = paso(salida)
+	}
+	return salida.Handle(ctx, r)
+}
+
+func (m *manejador) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return m.con(func(h slog.Handler) slog.Handler { return h.WithAttrs(attrs) })
+}
+
+func (m *manejador) WithGroup(nombre string) slog.Handler {
+	return m.con(func(h slog.Handler) slog.Handler { return h.WithGroup(nombre) })
+}
+
+func (m *manejador) con(paso func(slog.Handler) slog.Handler) *manejador {
+	pasos := make([]func(slog.Handler) slog.Handler, len(m.pasos), len(m.pasos)+1)
+	copy(pasos, m.pasos)
+	return &manejador{subsistema: m.subsistema, pasos: append(pasos, paso)}
+}
@@ -0,0 +1,44 @@
+package repositorios
+
+import (
+	"context"
+	"errors"
+	"formula1-crud-go/models"
+)
+
+var (
+	// ErrNoEncontrado se devuelve al buscar, actualizar o eliminar un ID que no existe.
+	ErrNoEncontrado = errors.New("no encontrado")
+	// ErrDuplicado se devuelve al repetir un valor único (el nombre de un piloto).
+	ErrDuplicado = errors.New("ya existe")
+)
+
+// FiltroPilotos restringe un listado; los campos vacíos no filtran.
+type FiltroPilotos struct {
+	Equipo       string // contenido en el nombre del equipo, sin distinguir mayúsculas
+	Nacionalidad string // exacta
+}
+
+// EstadisticasPilotos resume la tabla de pilotos.
+type EstadisticasPilotos struct {
+	TotalPilotos       int64   `json:"total_pilotos"`
+	TotalVictorias     int64   `json:"total_victorias"`
+	TotalPuntos        float64 `json:"total_puntos"`
+	PilotoMasVictorias string  `json:"piloto_mas_victorias"`
+	VictoriasPiloto    int     `json:"victorias_piloto"`
+}
+
+// RepositorioPilotos es el acceso a los pilotos que usan los manejadores.
+// PilotosGorm lo implementa sobre la base y PilotosMemoria en memoria, para
+// pruebas y demos sin base de datos.
+type RepositorioPilotos interface {
+	// Listar devuelve los pilotos que cumplen el filtro, ordenados por ID.
+	Listar(ctx context.Context, filtro FiltroPilotos) ([]models.Piloto, error)
+	Obtener(ctx context.Context, id uint) (models.Piloto, error)
+	// Crear asigna el ID y las fechas de piloto.
+	Crear(ctx context.Context, piloto *models.Piloto) error
+	// Actualizar guarda todos los campos del piloto con piloto.ID.
+	Actualizar(ctx context.Context, piloto *models.Piloto) error
+	Eliminar(ctx context.Context, id uint) error
+	Estadisticas(ctx context.Context) (EstadisticasPilotos, error)
+}
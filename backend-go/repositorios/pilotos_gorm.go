@@ -0,0 +1,103 @@
+package repositorios
+
+import (
+	"context"
+	"errors"
+	"formula1-crud-go/models"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// PilotosGorm guarda los pilotos con GORM.
+type PilotosGorm struct {
+	DB *gorm.DB
+}
+
+func NuevoRepositorioPilotosGorm(db *gorm.DB) *PilotosGorm {
+	return &PilotosGorm{DB: db}
+}
+
+func (r *PilotosGorm) Listar(ctx context.Context, filtro FiltroPilotos) ([]models.Piloto, error) {
+	consulta := r.DB.WithContext(ctx).Order("id")
+	if filtro.Nacionalidad != "" {
+		consulta = consulta.Where("nacionalidad = ?", filtro.Nacionalidad)
+	}
+	pilotos := []models.Piloto{}
+	if err := consulta.Find(&pilotos).Error; err != nil {
+		return pilotos, err
+	}
+	if filtro.Equipo == "" {
+		return pilotos, nil
+	}
+
+	// El equipo se compara en Go: LOWER de SQLite solo pasa a minúsculas
+	// las letras ASCII ("ÉQUIPE" no coincidiría con "é") y el de PostgreSQL
+	// depende de la configuración regional de la base
+	equipo := strings.ToLower(filtro.Equipo)
+	filtrados := pilotos[:0]
+	for _, p := range pilotos {
+		if strings.Contains(strings.ToLower(p.Equipo), equipo) {
+			filtrados = append(filtrados, p)
+		}
+	}
+	return filtrados, nil
+}
+
+func (r *PilotosGorm) Obtener(ctx context.Context, id uint) (models.Piloto, error) {
+	var piloto models.Piloto
+	err := r.DB.WithContext(ctx).First(&piloto, id).Error
+	return piloto, traducirError(err)
+}
+
+func (r *PilotosGorm) Crear(ctx context.Context, piloto *models.Piloto) error {
+	return traducirError(r.DB.WithContext(ctx).Create(piloto).Error)
+}
+
+func (r *PilotosGorm) Actualizar(ctx context.Context, piloto *models.Piloto) error {
+	resultado := r.DB.WithContext(ctx).Model(piloto).Select("*").Omit("created_at").Updates(piloto)
+	if resultado.Error == nil && resultado.RowsAffected == 0 {
+		return ErrNoEncontrado
+	}
+	return traducirError(resultado.Error)
+}
+
+func (r *PilotosGorm) Eliminar(ctx context.Context, id uint) error {
+	resultado := r.DB.WithContext(ctx).Delete(&models.Piloto{}, id)
+	if resultado.Error == nil && resultado.RowsAffected == 0 {
+		return ErrNoEncontrado
+	}
+	return resultado.Error
+}
+
+// Estadisticas calcula los totales en una sola consulta y busca al piloto
+// con más victorias en otra.
+func (r *PilotosGorm) Estadisticas(ctx context.Context) (EstadisticasPilotos, error) {
+	var e EstadisticasPilotos
+	err := r.DB.WithContext(ctx).Model(&models.Piloto{}).
+		Select("COUNT(*), COALESCE(SUM(victorias), 0), COALESCE(SUM(puntos), 0)").
+		Row().Scan(&e.TotalPilotos, &e.TotalVictorias, &e.TotalPuntos)
+	if err != nil || e.TotalPilotos == 0 {
+		return e, err
+	}
+
+	var lider models.Piloto
+	if err := r.DB.WithContext(ctx).Order("victorias desc").Order("id").First(&lider).Error; err != nil {
+		return e, err
+	}
+	e.PilotoMasVictorias, e.VictoriasPiloto = lider.Nombre, lider.Victorias
+	return e, nil
+}
+
+// traducirError lleva los errores de GORM a los del paquete; la conexión se
+// abre con TranslateError para que las violaciones de unicidad lleguen como
+// gorm.ErrDuplicatedKey en cualquier motor.
+func traducirError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNoEncontrado
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrDuplicado
+	}
+	return err
+}
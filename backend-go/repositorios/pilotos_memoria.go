@@ -0,0 +1,129 @@
+package repositorios
+
+import (
+	"context"
+	"formula1-crud-go/models"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PilotosMemoria guarda los pilotos en un mapa, con las mismas reglas que la
+// base: IDs crecientes que no se reutilizan y nombres únicos entre los
+// pilotos que siguen existiendo, así que el de uno eliminado se puede volver
+// a usar.
+type PilotosMemoria struct {
+	mu        sync.RWMutex
+	pilotos   map[uint]models.Piloto
+	siguiente uint
+}
+
+// NuevoRepositorioPilotosMemoria arranca con una copia de los pilotos dados,
+// que conservan su ID si lo tienen.
+func NuevoRepositorioPilotosMemoria(iniciales ...models.Piloto) *PilotosMemoria {
+	r := &PilotosMemoria{pilotos: make(map[uint]models.Piloto), siguiente: 1}
+	for _, p := range iniciales {
+		if p.ID == 0 {
+			p.ID = r.siguiente
+		}
+		r.pilotos[p.ID] = p
+		r.siguiente = max(r.siguiente, p.ID+1)
+	}
+	return r
+}
+
+func (r *PilotosMemoria) Listar(_ context.Context, filtro FiltroPilotos) ([]models.Piloto, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	pilotos := []models.Piloto{}
+	for _, p := range r.pilotos {
+		if filtro.Equipo != "" && !strings.Contains(strings.ToLower(p.Equipo), strings.ToLower(filtro.Equipo)) {
+			continue
+		}
+		if filtro.Nacionalidad != "" && p.Nacionalidad != filtro.Nacionalidad {
+			continue
+		}
+		pilotos = append(pilotos, p)
+	}
+	sort.Slice(pilotos, func(i, j int) bool { return pilotos[i].ID < pilotos[j].ID })
+	return pilotos, nil
+}
+
+func (r *PilotosMemoria) Obtener(_ context.Context, id uint) (models.Piloto, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.pilotos[id]
+	if !ok {
+		return models.Piloto{}, ErrNoEncontrado
+	}
+	return p, nil
+}
+
+func (r *PilotosMemoria) Crear(_ context.Context, piloto *models.Piloto) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.nombreUsado(piloto.Nombre, 0) {
+		return ErrDuplicado
+	}
+	ahora := time.Now()
+	piloto.ID, piloto.CreatedAt, piloto.UpdatedAt = r.siguiente, ahora, ahora
+	r.siguiente++
+	r.pilotos[piloto.ID] = *piloto
+	return nil
+}
+
+func (r *PilotosMemoria) Actualizar(_ context.Context, piloto *models.Piloto) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	anterior, ok := r.pilotos[piloto.ID]
+	if !ok {
+		return ErrNoEncontrado
+	}
+	if r.nombreUsado(piloto.Nombre, piloto.ID) {
+		return ErrDuplicado
+	}
+	piloto.CreatedAt, piloto.UpdatedAt = anterior.CreatedAt, time.Now()
+	r.pilotos[piloto.ID] = *piloto
+	return nil
+}
+
+func (r *PilotosMemoria) Eliminar(_ context.Context, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.pilotos[id]; !ok {
+		return ErrNoEncontrado
+	}
+	delete(r.pilotos, id)
+	return nil
+}
+
+func (r *PilotosMemoria) Estadisticas(_ context.Context) (EstadisticasPilotos, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var e EstadisticasPilotos
+	var lider *models.Piloto
+	for _, p := range r.pilotos {
+		e.TotalPilotos++
+		e.TotalVictorias += int64(p.Victorias)
+		e.TotalPuntos += p.Puntos
+		// Ante un empate gana el de menor ID, como en PilotosGorm
+		if lider == nil || p.Victorias > lider.Victorias || (p.Victorias == lider.Victorias && p.ID < lider.ID) {
+			lider = &p
+		}
+	}
+	if lider != nil {
+		e.PilotoMasVictorias, e.VictoriasPiloto = lider.Nombre, lider.Victorias
+	}
+	return e, nil
+}
+
+// nombreUsado informa si otro piloto (distinto de excepto) ya tiene nombre.
+func (r *PilotosMemoria) nombreUsado(nombre string, excepto uint) bool {
+	for id, p := range r.pilotos {
+		if id != excepto && p.Nombre == nombre {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,237 @@
+package repositorios
+
+import (
+	"context"
+	"errors"
+	"formula1-crud-go/config"
+	"formula1-crud-go/database"
+	"formula1-crud-go/models"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// Los mismos casos corren contra cada implementación de RepositorioPilotos:
+// la de memoria que usan las pruebas de handlers y la de GORM sobre SQLite
+// con las migraciones reales.
+
+// pilotosDePrueba se crean en este orden, así que sus IDs son crecientes.
+func pilotosDePrueba() []models.Piloto {
+	return []models.Piloto{
+		{Nombre: "Max Verstappen", Equipo: "Red Bull", Nacionalidad: "Holandés", Numero: 1, Victorias: 54, Puntos: 575.5},
+		{Nombre: "Charles Leclerc", Equipo: "Ferrari", Nacionalidad: "Monegasco", Numero: 16, Victorias: 5, Puntos: 1074},
+		{Nombre: "Carlos Sainz", Equipo: "Ferrari", Nacionalidad: "Español", Numero: 55, Victorias: 2, Puntos: 782.5},
+		{Nombre: "Pierre Gasly", Equipo: "Écurie Alpine", Nacionalidad: "Francés", Numero: 10, Victorias: 1, Puntos: 436},
+	}
+}
+
+func repositoriosDePrueba() map[string]func(t *testing.T) RepositorioPilotos {
+	return map[string]func(t *testing.T) RepositorioPilotos{
+		"memoria": func(*testing.T) RepositorioPilotos {
+			return NuevoRepositorioPilotosMemoria()
+		},
+		"gorm-sqlite": nuevoPilotosSQLite,
+	}
+}
+
+// nuevoPilotosSQLite abre una base SQLite nueva como la abre el servidor,
+// aplica las migraciones y quita los pilotos de ejemplo.
+func nuevoPilotosSQLite(t *testing.T) RepositorioPilotos {
+	t.Helper()
+	cfg := config.PorDefecto().BaseDeDatos
+	cfg.Driver = "sqlite"
+	cfg.Ruta = filepath.Join(t.TempDir(), "formula1.db")
+
+	db, err := database.Abrir(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	migrador, err := database.NuevoMigrador(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrador.Subir(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec("DELETE FROM pilotos").Error; err != nil {
+		t.Fatal(err)
+	}
+	return NuevoRepositorioPilotosGorm(db)
+}
+
+// cargar crea los pilotos de prueba y los devuelve con su ID.
+func cargar(t *testing.T, repo RepositorioPilotos) []models.Piloto {
+	t.Helper()
+	pilotos := pilotosDePrueba()
+	for i := range pilotos {
+		if err := repo.Crear(context.Background(), &pilotos[i]); err != nil {
+			t.Fatalf("crear %s: %v", pilotos[i].Nombre, err)
+		}
+	}
+	return pilotos
+}
+
+func nombres(pilotos []models.Piloto) []string {
+	var n []string
+	for _, p := range pilotos {
+		n = append(n, p.Nombre)
+	}
+	return n
+}
+
+func TestListar(t *testing.T) {
+	for nombre, nuevo := range repositoriosDePrueba() {
+		t.Run(nombre, func(t *testing.T) {
+			repo := nuevo(t)
+			cargar(t, repo)
+			casos := []struct {
+				filtro  FiltroPilotos
+				esperan []string
+			}{
+				{FiltroPilotos{}, []string{"Max Verstappen", "Charles Leclerc", "Carlos Sainz", "Pierre Gasly"}},
+				{FiltroPilotos{Equipo: "FERRARI"}, []string{"Charles Leclerc", "Carlos Sainz"}},
+				{FiltroPilotos{Equipo: "bul"}, []string{"Max Verstappen"}},
+				// Mayúsculas fuera de ASCII
+				{FiltroPilotos{Equipo: "ÉCURIE"}, []string{"Pierre Gasly"}},
+				{FiltroPilotos{Nacionalidad: "Español"}, []string{"Carlos Sainz"}},
+				{FiltroPilotos{Nacionalidad: "español"}, nil},
+				{FiltroPilotos{Equipo: "ferrari", Nacionalidad: "Monegasco"}, []string{"Charles Leclerc"}},
+				{FiltroPilotos{Equipo: "Mercedes"}, nil},
+			}
+			for _, caso := range casos {
+				pilotos, err := repo.Listar(context.Background(), caso.filtro)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if pilotos == nil {
+					t.Errorf("%+v: lista nil, se espera vacía", caso.filtro)
+				}
+				if got := nombres(pilotos); !slices.Equal(got, caso.esperan) {
+					t.Errorf("%+v: %v, se esperaba %v", caso.filtro, got, caso.esperan)
+				}
+			}
+		})
+	}
+}
+
+func TestObtenerYActualizar(t *testing.T) {
+	for nombre, nuevo := range repositoriosDePrueba() {
+		t.Run(nombre, func(t *testing.T) {
+			repo := nuevo(t)
+			pilotos := cargar(t, repo)
+			ctx := context.Background()
+
+			p, err := repo.Obtener(ctx, pilotos[1].ID)
+			if err != nil || p.Nombre != "Charles Leclerc" {
+				t.Fatalf("obtener: %+v, %v", p, err)
+			}
+			if _, err := repo.Obtener(ctx, 999); !errors.Is(err, ErrNoEncontrado) {
+				t.Fatalf("obtener inexistente: %v", err)
+			}
+
+			p.Equipo, p.Victorias = "Mercedes", 6
+			if err := repo.Actualizar(ctx, &p); err != nil {
+				t.Fatal(err)
+			}
+			if p, _ = repo.Obtener(ctx, p.ID); p.Equipo != "Mercedes" || p.Victorias != 6 {
+				t.Fatalf("no se guardó la actualización: %+v", p)
+			}
+			if err := repo.Actualizar(ctx, &models.Piloto{Model: gorm.Model{ID: 999}, Nombre: "Nadie"}); !errors.Is(err, ErrNoEncontrado) {
+				t.Fatalf("actualizar inexistente: %v", err)
+			}
+			p.Nombre = "Max Verstappen"
+			if err := repo.Actualizar(ctx, &p); !errors.Is(err, ErrDuplicado) {
+				t.Fatalf("nombre repetido al actualizar: %v", err)
+			}
+		})
+	}
+}
+
+func TestCrearNombreRepetido(t *testing.T) {
+	for nombre, nuevo := range repositoriosDePrueba() {
+		t.Run(nombre, func(t *testing.T) {
+			repo := nuevo(t)
+			cargar(t, repo)
+			err := repo.Crear(context.Background(), &models.Piloto{Nombre: "Max Verstappen", Equipo: "Williams"})
+			if !errors.Is(err, ErrDuplicado) {
+				t.Fatalf("se esperaba ErrDuplicado: %v", err)
+			}
+		})
+	}
+}
+
+func TestEliminarLiberaElNombre(t *testing.T) {
+	for nombre, nuevo := range repositoriosDePrueba() {
+		t.Run(nombre, func(t *testing.T) {
+			repo := nuevo(t)
+			pilotos := cargar(t, repo)
+			ctx := context.Background()
+
+			if err := repo.Eliminar(ctx, pilotos[0].ID); err != nil {
+				t.Fatal(err)
+			}
+			if err := repo.Eliminar(ctx, pilotos[0].ID); !errors.Is(err, ErrNoEncontrado) {
+				t.Fatalf("segunda eliminación: %v", err)
+			}
+			if _, err := repo.Obtener(ctx, pilotos[0].ID); !errors.Is(err, ErrNoEncontrado) {
+				t.Fatalf("el piloto sigue existiendo: %v", err)
+			}
+
+			otra := models.Piloto{Nombre: "Max Verstappen", Equipo: "Red Bull"}
+			if err := repo.Crear(ctx, &otra); err != nil {
+				t.Fatalf("recrear el piloto eliminado: %v", err)
+			}
+			if otra.ID <= pilotos[len(pilotos)-1].ID {
+				t.Fatalf("se reutilizó un ID: %d", otra.ID)
+			}
+			// El nombre vuelve a estar ocupado
+			if err := repo.Crear(ctx, &models.Piloto{Nombre: "Max Verstappen"}); !errors.Is(err, ErrDuplicado) {
+				t.Fatalf("se esperaba ErrDuplicado: %v", err)
+			}
+		})
+	}
+}
+
+func TestEstadisticas(t *testing.T) {
+	for nombre, nuevo := range repositoriosDePrueba() {
+		t.Run(nombre, func(t *testing.T) {
+			repo := nuevo(t)
+			ctx := context.Background()
+
+			e, err := repo.Estadisticas(ctx)
+			if err != nil || e != (EstadisticasPilotos{}) {
+				t.Fatalf("sin pilotos: %+v, %v", e, err)
+			}
+
+			pilotos := cargar(t, repo)
+			e, err = repo.Estadisticas(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			esperan := EstadisticasPilotos{TotalPilotos: 4, TotalVictorias: 62, TotalPuntos: 2868, PilotoMasVictorias: "Max Verstappen", VictoriasPiloto: 54}
+			if e != esperan {
+				t.Fatalf("%+v, se esperaba %+v", e, esperan)
+			}
+
+			// Los eliminados no cuentan
+			if err := repo.Eliminar(ctx, pilotos[0].ID); err != nil {
+				t.Fatal(err)
+			}
+			e, err = repo.Estadisticas(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			esperan = EstadisticasPilotos{TotalPilotos: 3, TotalVictorias: 8, TotalPuntos: 2292.5, PilotoMasVictorias: "Charles Leclerc", VictoriasPiloto: 5}
+			if e != esperan {
+				t.Fatalf("tras eliminar: %+v, se esperaba %+v", e, esperan)
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+package simulacion
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrSimulacionDesconocida indica que no hay transmisión para ese ID.
+var ErrSimulacionDesconocida = errors.New("simulación desconocida")
+
+const (
+	// Mensajes que puede acumular un suscriptor antes de considerarlo lento
+	maxColaSuscriptor = 4096
+	// Tiempo que se conserva el historial de una simulación terminada
+	retencionHistorial = 5 * time.Minute
+)
+
+// transmision guarda el historial de una simulación y quiénes la miran.
+type transmision struct {
+	topico       string
+	historial    []MensajeWS
+	suscriptores map[*Suscripcion]bool
+	finalizada   bool
+}
+
+// Hub reparte los mensajes de cada simulación entre todos sus espectadores,
+// ya sea que se suscriban por ID o por tópico ("mpi", "openmp").
+type Hub struct {
+	mu            sync.Mutex
+	transmisiones map[string]*transmision
+	porTopico     map[string]map[*Suscripcion]bool
+}
+
+func NuevoHub() *Hub {
+	return &Hub{
+		transmisiones: make(map[string]*transmision),
+		porTopico:     make(map[string]map[*Suscripcion]bool),
+	}
+}
+
+// NuevaSuscripcion crea la cola de un cliente; todavía no recibe nada hasta
+// que se suscriba a una simulación o tópico.
+func (h *Hub) NuevaSuscripcion(politica PoliticaDesborde) *Suscripcion {
+	return nuevaSuscripcion(maxColaSuscriptor, politica)
+}
+
+// Suscribir agrega al cliente como espectador de una simulación. Primero
+// recibe el historial acumulado y luego los mensajes en vivo.
+func (h *Hub) Suscribir(s *Suscripcion, id string) error {
+	return h.SuscribirDesde(s, id, 0)
+}
+
+// SuscribirDesde es como Suscribir pero solo reenvía el historial posterior a
+// la secuencia desde, para que un cliente que se reconecta no reciba de nuevo
+// lo que ya vio.
+func (h *Hub) SuscribirDesde(s *Suscripcion, id string, desde uint64) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	t, ok := h.transmisiones[id]
+	if !ok {
+		return ErrSimulacionDesconocida
+	}
+	if s.ids[id] {
+		return nil
+	}
+
+	s.ids[id] = true
+	s.Entregar(MensajeWS{Tipo: TipoSuscrito, Topico: t.topico, SimulacionID: id})
+	if !h.mirando(s, t) && !h.entregarHistorial(s, t, desde) {
+		h.quitar(s)
+		return nil
+	}
+	t.suscriptores[s] = true
+	h.avisarPresencia(id, t)
+	return nil
+}
+
+// SuscribirTopico agrega al cliente como espectador de todas las
+// simulaciones de un tópico, incluidas las que empiecen después.
+func (h *Hub) SuscribirTopico(s *Suscripcion, topico string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if s.topicos[topico] {
+		return
+	}
+	s.topicos[topico] = true
+	if h.porTopico[topico] == nil {
+		h.porTopico[topico] = make(map[*Suscripcion]bool)
+	}
+	h.porTopico[topico][s] = true
+	s.Entregar(MensajeWS{Tipo: TipoSuscrito, Topico: topico})
+
+	for id, t := range h.transmisiones {
+		if t.topico != topico || t.finalizada {
+			continue
+		}
+		if !t.suscriptores[s] && !h.entregarHistorial(s, t, 0) {
+			h.quitar(s)
+			return
+		}
+		h.avisarPresencia(id, t)
+	}
+}
+
+// Desuscribir deja de enviar al cliente los mensajes de una simulación.
+func (h *Hub) Desuscribir(s *Suscripcion, id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(s.ids, id)
+	if t, ok := h.transmisiones[id]; ok && t.suscriptores[s] {
+		delete(t.suscriptores, s)
+		h.avisarPresencia(id, t)
+	}
+}
+
+// DesuscribirTopico deja de enviar al cliente las simulaciones de un tópico.
+func (h *Hub) DesuscribirTopico(s *Suscripcion, topico string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.quitarDeTopico(s, topico)
+}
+
+// Cerrar quita al cliente de todas sus suscripciones y cierra su cola.
+func (h *Hub) Cerrar(s *Suscripcion) {
+	h.mu.Lock()
+	h.quitar(s)
+	h.mu.Unlock()
+
+	s.cerrar()
+}
+
+// Espectadores devuelve cuántos clientes miran una simulación.
+func (h *Hub) Espectadores(id string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	t, ok := h.transmisiones[id]
+	if !ok {
+		return 0
+	}
+	return len(h.espectadores(t))
+}
+
+// Completa informa si la simulación ya publicó su mensaje final y el cliente
+// vio hasta él, es decir, si no le queda nada por recibir.
+func (h *Hub) Completa(id string, desde uint64) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	t, ok := h.transmisiones[id]
+	if !ok || len(t.historial) == 0 {
+		return false
+	}
+	ultimo := t.historial[len(t.historial)-1]
+	return EsFinal(ultimo) && desde >= ultimo.Seq
+}
+
+// abrir registra la transmisión de una simulación recién creada.
+func (h *Hub) abrir(id, topico string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.transmisiones[id] = &transmision{
+		topico:       topico,
+		suscriptores: make(map[*Suscripcion]bool),
+	}
+}
+
+// publicar numera el mensaje, lo guarda en el historial y lo reparte sin
+// bloquear. La secuencia empieza en 1 y no tiene huecos.
+func (h *Hub) publicar(msg MensajeWS) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	t, ok := h.transmisiones[msg.SimulacionID]
+	if !ok {
+		return
+	}
+	msg.Seq = uint64(len(t.historial) + 1)
+	t.historial = append(t.historial, msg)
+	h.repartir(t, msg)
+}
+
+// difundir reparte un mensaje efímero que no queda en el historial.
+func (h *Hub) difundir(msg MensajeWS) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if t, ok := h.transmisiones[msg.SimulacionID]; ok {
+		h.repartir(t, msg)
+	}
+}
+
+// finalizar marca la transmisión como terminada y programa su borrado.
+func (h *Hub) finalizar(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	t, ok := h.transmisiones[id]
+	if !ok {
+		return
+	}
+	t.finalizada = true
+
+	time.AfterFunc(retencionHistorial, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		for s := range t.suscriptores {
+			delete(s.ids, id)
+		}
+		delete(h.transmisiones, id)
+	})
+}
+
+// Las funciones siguientes deben llamarse con h.mu tomado.
+
+// espectadores une a los suscriptores por ID y por tópico de una transmisión.
+func (h *Hub) espectadores(t *transmision) map[*Suscripcion]bool {
+	todos := make(map[*Suscripcion]bool, len(t.suscriptores))
+	for s := range t.suscriptores {
+		todos[s] = true
+	}
+	if !t.finalizada {
+		for s := range h.porTopico[t.topico] {
+			todos[s] = true
+		}
+	}
+	return todos
+}
+
+// mirando informa si el cliente ya recibe la transmisión por su tópico.
+func (h *Hub) mirando(s *Suscripcion, t *transmision) bool {
+	return !t.finalizada && h.porTopico[t.topico][s]
+}
+
+func (h *Hub) repartir(t *transmision, msg MensajeWS) {
+	for s := range h.espectadores(t) {
+		if !s.Entregar(msg) {
+			h.quitar(s)
+		}
+	}
+}
+
+func (h *Hub) entregarHistorial(s *Suscripcion, t *transmision, desde uint64) bool {
+	if desde > uint64(len(t.historial)) {
+		desde = uint64(len(t.historial))
+	}
+	for _, msg := range t.historial[desde:] {
+		if !s.Entregar(msg) {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *Hub) avisarPresencia(id string, t *transmision) {
+	msg := MensajeWS{
+		Tipo:         TipoPresencia,
+		Topico:       t.topico,
+		SimulacionID: id,
+		Obj:          map[string]any{"espectadores": len(h.espectadores(t))},
+	}
+	// La presencia no se guarda en el historial: solo vale en el momento
+	h.repartir(t, msg)
+}
+
+// quitar saca al cliente de todas las transmisiones y tópicos, avisando la
+// nueva presencia a los que siguen mirando.
+func (h *Hub) quitar(s *Suscripcion) {
+	ids, topicos := s.ids, s.topicos
+	s.ids, s.topicos = make(map[string]bool), make(map[string]bool)
+
+	// Primero se lo desconecta de todo, así los avisos ya no le llegan
+	for topico := range topicos {
+		delete(h.porTopico[topico], s)
+	}
+	for id := range ids {
+		if t, ok := h.transmisiones[id]; ok {
+			delete(t.suscriptores, s)
+		}
+	}
+
+	for id, t := range h.transmisiones {
+		if ids[id] || (topicos[t.topico] && !t.finalizada) {
+			h.avisarPresencia(id, t)
+		}
+	}
+}
+
+func (h *Hub) quitarDeTopico(s *Suscripcion, topico string) {
+	if !s.topicos[topico] {
+		return
+	}
+	delete(s.topicos, topico)
+	delete(h.porTopico[topico], s)
+	for id, t := range h.transmisiones {
+		if t.topico == topico && !t.finalizada && !t.suscriptores[s] {
+			h.avisarPresencia(id, t)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package simulacion
+
+import (
+	"fmt"
+	"time"
+)
+
+// Limites acota cuántas simulaciones corre el servidor y de qué tamaño.
+type Limites struct {
+	Trabajadores  int `json:"trabajadores"`    // simulaciones corriendo a la vez en todo el servidor
+	MaxEnCola     int `json:"max_en_cola"`     // simulaciones esperando un trabajador
+	MaxPorCliente int `json:"max_por_cliente"` // simulaciones en cola o corriendo por cliente
+	MaxAutos      int `json:"max_autos"`
+	MaxSectores   int `json:"max_sectores"`
+	MaxVueltas    int `json:"max_vueltas"`
+}
+
+// Ritmo son las pausas que hacen visibles las simulaciones en clase.
+type Ritmo struct {
+	PasoMPI    time.Duration // entre sectores
+	PasoOpenMP time.Duration // entre vueltas de cada auto
+}
+
+// validar comprueba el tamaño de la simulación pedida.
+func (l Limites) validar(sol Solicitud) error {
+	switch sol.Topico {
+	case "mpi":
+		if sol.Sectores > l.MaxSectores {
+			return fmt.Errorf("%w: sectores (%d) supera el máximo de %d", ErrLimiteExcedido, sol.Sectores, l.MaxSectores)
+		}
+	case "openmp":
+		if sol.Autos > l.MaxAutos {
+			return fmt.Errorf("%w: autos (%d) supera el máximo de %d", ErrLimiteExcedido, sol.Autos, l.MaxAutos)
+		}
+	case "telemetria":
+		if sol.Repeticion == nil {
+			return fmt.Errorf("falta la telemetría a reproducir")
+		}
+	default:
+		return fmt.Errorf("tipo de simulación desconocido: %q", sol.Topico)
+	}
+	if sol.Vueltas > l.MaxVueltas {
+		return fmt.Errorf("%w: vueltas (%d) supera el máximo de %d", ErrLimiteExcedido, sol.Vueltas, l.MaxVueltas)
+	}
+	return nil
+}
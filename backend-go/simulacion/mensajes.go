@@ -0,0 +1,47 @@
+package simulacion
+
+// -------------------- Tipos de Mensajes --------------------
+type MensajeWS struct {
+	Tipo         string `json:"tipo"`
+	Topico       string `json:"topico,omitempty"`
+	SimulacionID string `json:"simulacion_id,omitempty"`
+	Seq          uint64 `json:"seq,omitempty"` // solo en mensajes del historial
+	RequestID    string `json:"request_id,omitempty"`
+	Codigo       string `json:"codigo,omitempty"`
+	Texto        string `json:"texto,omitempty"`
+	Obj          any    `json:"obj,omitempty"`
+}
+
+// Tipos de mensaje que el servidor envía a los clientes
+const (
+	TipoHello      = "hello"
+	TipoAceptado   = "aceptado"
+	TipoError      = "error"
+	TipoRegistro   = "registro"
+	TipoResumen    = "resumen"
+	TipoEnCola     = "en_cola"
+	TipoIniciado   = "iniciado"
+	TipoPausado    = "pausado"
+	TipoReanudado  = "reanudado"
+	TipoFinalizado = "finalizado"
+	TipoCancelado  = "cancelado"
+	TipoSuscrito   = "suscrito"
+	TipoPresencia  = "presencia"
+	TipoDesborde   = "desborde"
+	TipoMuestra    = "muestra"
+	TipoSalto      = "salto"
+	// TipoServidorApagandose es el último mensaje antes de que el servidor
+	// cierre la conexión para apagarse
+	TipoServidorApagandose = "servidor_apagandose"
+)
+
+// EsFinal informa si el mensaje es el último que publica una simulación.
+func EsFinal(msg MensajeWS) bool {
+	return msg.Seq > 0 && (msg.Tipo == TipoFinalizado || msg.Tipo == TipoCancelado)
+}
+
+type ResultadoOpenMP struct {
+	AutoID          int     `json:"auto_id"`
+	MejorVuelta     float64 `json:"mejor_vuelta"`
+	CantidadVueltas int     `json:"cantidad_vueltas"`
+}
@@ -0,0 +1,61 @@
+package simulacion
+
+import (
+	"formula1-crud-go/metricas"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Estados de la métrica formula1_simulaciones
+const (
+	estadoEnCola    = "en_cola"
+	estadoCorriendo = "corriendo"
+)
+
+var (
+	simulacionesActivas = promauto.With(metricas.Registro).NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricas.Espacio,
+		Name:      "simulaciones",
+		Help:      "Simulaciones esperando un trabajador (en_cola) o corriendo, por tópico.",
+	}, []string{"topico", "estado"})
+
+	simulacionesTerminadas = promauto.With(metricas.Registro).NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricas.Espacio,
+		Name:      "simulaciones_terminadas_total",
+		Help:      "Simulaciones que llegaron a correr, por tópico y resultado (completada, cancelada o error).",
+	}, []string{"topico", "resultado"})
+
+	duracionSimulacion = promauto.With(metricas.Registro).NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricas.Espacio,
+		Name:      "simulacion_duracion_seconds",
+		Help:      "Tiempo de ejecución de las simulaciones, sin la espera en cola, por tópico.",
+		Buckets:   metricas.BucketsCalculo,
+	}, []string{"topico"})
+
+	esperaEnCola = promauto.With(metricas.Registro).NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricas.Espacio,
+		Name:      "simulacion_espera_seconds",
+		Help:      "Tiempo que esperaron las simulaciones hasta tener un trabajador, por tópico.",
+		Buckets:   metricas.BucketsCalculo,
+	}, []string{"topico"})
+
+	goroutinesSimulacion = promauto.With(metricas.Registro).NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricas.Espacio,
+		Name:      "simulacion_goroutines",
+		Help:      "Goroutines trabajando en simulaciones, por tópico: el trabajador de cada una más las que lance (un auto por goroutine en openmp).",
+	}, []string{"topico"})
+)
+
+// goroutine cuenta una goroutine de la simulación, en la métrica de su
+// tópico y en la simulación, hasta que se llame a la función devuelta:
+// defer s.goroutine()().
+func (s *Simulacion) goroutine() func() {
+	g := goroutinesSimulacion.WithLabelValues(s.Topico)
+	g.Inc()
+	s.goroutines.Add(1)
+	return func() {
+		g.Dec()
+		s.goroutines.Add(-1)
+	}
+}
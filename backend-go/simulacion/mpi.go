@@ -0,0 +1,49 @@
+package simulacion
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// CorrerMPI simula el procesamiento de los sectores de la pista vuelta a
+// vuelta, con una pausa de paso entre sectores.
+func CorrerMPI(s *Simulacion, sectores int, vueltas int, paso time.Duration) error {
+	if sectores < 1 {
+		if err := s.Enviar(MensajeWS{Tipo: TipoRegistro, Texto: "Error: sectores debe ser >= 1"}); err != nil {
+			return err
+		}
+		return s.Enviar(MensajeWS{Tipo: TipoFinalizado})
+	}
+	if vueltas < 1 {
+		vueltas = 1
+	}
+
+	if err := s.Enviar(MensajeWS{Tipo: TipoRegistro, Texto: fmt.Sprintf("Iniciando MPI: %d sectores, %d vueltas", sectores, vueltas)}); err != nil {
+		return err
+	}
+
+	for v := 1; v <= vueltas; v++ {
+		if err := s.Enviar(MensajeWS{Tipo: TipoRegistro, Texto: fmt.Sprintf("=== Vuelta %d ===", v)}); err != nil {
+			return err
+		}
+		for sec := 1; sec <= sectores; sec++ {
+			tiempoSector := float64(rand.Intn(2300)+1200) / 100.0
+			if err := s.Dormir(paso); err != nil {
+				return err
+			}
+			err := s.Enviar(MensajeWS{
+				Tipo:  TipoRegistro,
+				Texto: fmt.Sprintf("Tiempo de sector %d: %.2f s (vuelta %d)", sec, tiempoSector, v),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := s.Enviar(MensajeWS{Tipo: TipoResumen, Obj: map[string]any{"mensaje": "MPI finalizado"}}); err != nil {
+		return err
+	}
+	return s.Enviar(MensajeWS{Tipo: TipoFinalizado})
+}
@@ -0,0 +1,90 @@
+package simulacion
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// CorrerOpenMP simula varios autos corriendo en paralelo, uno por goroutine,
+// con una pausa de paso entre vueltas.
+func CorrerOpenMP(s *Simulacion, cantidadAutos int, vueltas int, paso time.Duration) error {
+	if cantidadAutos < 1 {
+		if err := s.Enviar(MensajeWS{Tipo: TipoRegistro, Texto: "Error: cantidad de autos debe ser >= 1"}); err != nil {
+			return err
+		}
+		return s.Enviar(MensajeWS{Tipo: TipoFinalizado})
+	}
+	if vueltas < 1 {
+		vueltas = 1
+	}
+
+	if err := s.Enviar(MensajeWS{Tipo: TipoRegistro, Texto: fmt.Sprintf("Iniciando OpenMP: %d autos, %d vueltas cada uno", cantidadAutos, vueltas)}); err != nil {
+		return err
+	}
+
+	resultados := make([]ResultadoOpenMP, cantidadAutos)
+	done := make(chan error)
+
+	for auto := 0; auto < cantidadAutos; auto++ {
+		go func(autoID int) {
+			defer s.goroutine()()
+			defer s.region(fmt.Sprintf("auto %d", autoID+1))()
+			span := s.trazarGoroutine("openmp auto", attribute.Int("auto", autoID+1), attribute.Int("vueltas", vueltas))
+			err := correrAuto(s, autoID, vueltas, paso, &resultados[autoID])
+			terminarSpan(span, err)
+			done <- err
+		}(auto)
+	}
+
+	// Se espera a todas las goroutines aunque alguna falle, así ninguna
+	// queda enviando mensajes después de que la simulación termina.
+	var primerError error
+	for i := 0; i < cantidadAutos; i++ {
+		if err := <-done; err != nil && primerError == nil {
+			primerError = err
+		}
+	}
+	if primerError != nil {
+		return primerError
+	}
+
+	mejorGeneral := ResultadoOpenMP{AutoID: -1, MejorVuelta: 1e9}
+	for _, r := range resultados {
+		if r.MejorVuelta < mejorGeneral.MejorVuelta {
+			mejorGeneral = r
+		}
+	}
+
+	err := s.Enviar(MensajeWS{Tipo: TipoResumen, Obj: map[string]any{
+		"mejor_por_auto": resultados,
+		"mejor_general":  mejorGeneral,
+	}})
+	if err != nil {
+		return err
+	}
+	return s.Enviar(MensajeWS{Tipo: TipoFinalizado})
+}
+
+func correrAuto(s *Simulacion, autoID int, vueltas int, paso time.Duration, resultado *ResultadoOpenMP) error {
+	mejor := 1e9
+	for v := 1; v <= vueltas; v++ {
+		tiempoVuelta := float64(rand.Intn(2099)+7500) / 100.0
+		if err := s.Dormir(paso); err != nil {
+			return err
+		}
+		if err := s.Enviar(MensajeWS{Tipo: TipoRegistro, Texto: fmt.Sprintf("Auto %d - Vuelta %d: %.2f s", autoID+1, v, tiempoVuelta)}); err != nil {
+			return err
+		}
+		if tiempoVuelta < mejor {
+			mejor = tiempoVuelta
+			if err := s.Enviar(MensajeWS{Tipo: TipoRegistro, Texto: fmt.Sprintf("Auto %d - Nueva mejor vuelta: %.2f s", autoID+1, mejor)}); err != nil {
+				return err
+			}
+		}
+	}
+	*resultado = ResultadoOpenMP{AutoID: autoID + 1, MejorVuelta: mejor, CantidadVueltas: vueltas}
+	return nil
+}
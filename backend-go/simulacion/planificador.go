@@ -0,0 +1,385 @@
+package simulacion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"formula1-crud-go/registro"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+var registroSim = registro.Para(registro.Sim)
+
+var (
+	// ErrLimiteExcedido indica que la simulación pedida es demasiado grande.
+	ErrLimiteExcedido = errors.New("límite excedido")
+	// ErrColaLlena indica que no hay lugar para más simulaciones en espera.
+	ErrColaLlena = errors.New("la cola de simulaciones está llena")
+	// ErrLimiteCliente indica que el cliente ya tiene demasiadas simulaciones activas.
+	ErrLimiteCliente = errors.New("el cliente alcanzó su máximo de simulaciones activas")
+	// ErrApagando indica que el servidor se está apagando y no acepta simulaciones.
+	ErrApagando = errors.New("el servidor se está apagando")
+)
+
+// Solicitud describe una simulación pedida por un cliente.
+type Solicitud struct {
+	Cliente  string
+	Topico   string // "mpi", "openmp" o "telemetria"
+	Autos    int
+	Sectores int
+	Vueltas  int
+	// Repeticion es la telemetría a reproducir en el tópico "telemetria"
+	Repeticion *Repeticion
+	// Traza, si no es nil, captura la traza de runtime/trace de la corrida
+	Traza *CapturaTraza
+}
+
+// trabajo es una solicitud aceptada que espera o usa un trabajador.
+type trabajo struct {
+	sim       *Simulacion
+	solicitud Solicitud
+	encolado  time.Time
+	iniciado  time.Time
+	tomado    chan struct{} // se cierra cuando un trabajador lo saca de la cola
+}
+
+// Planificador reparte las simulaciones entre un número fijo de trabajadores
+// y aplica los límites globales y por cliente.
+type Planificador struct {
+	limites Limites
+	ritmo   Ritmo
+	hub     *Hub
+
+	mu         sync.Mutex
+	cola       []*trabajo
+	corriendo  map[string]*trabajo
+	porCliente map[string]int
+	aviso      chan struct{}
+	apagando   bool
+}
+
+// NuevoPlanificador crea el planificador y arranca sus trabajadores. Los
+// mensajes de cada simulación se publican en hub.
+func NuevoPlanificador(limites Limites, ritmo Ritmo, hub *Hub) *Planificador {
+	p := &Planificador{
+		limites:    limites,
+		ritmo:      ritmo,
+		hub:        hub,
+		corriendo:  make(map[string]*trabajo),
+		porCliente: make(map[string]int),
+		aviso:      make(chan struct{}, 1),
+	}
+	for i := 0; i < limites.Trabajadores; i++ {
+		go p.trabajador()
+	}
+	return p
+}
+
+// Limites devuelve los límites con los que se creó el planificador.
+func (p *Planificador) Limites() Limites {
+	return p.limites
+}
+
+// Buscar devuelve una simulación en cola o en ejecución.
+func (p *Planificador) Buscar(id string) (*Simulacion, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if t, ok := p.corriendo[id]; ok {
+		return t.sim, true
+	}
+	for _, t := range p.cola {
+		if t.sim.ID == id {
+			return t.sim, true
+		}
+	}
+	return nil, false
+}
+
+// Encolar valida la solicitud y la pone en espera de un trabajador. La
+// simulación devuelta se cancela junto con padre; si espectador no es nil,
+// queda suscripto antes de recibir el primer mensaje.
+func (p *Planificador) Encolar(padre context.Context, sol Solicitud, espectador *Suscripcion) (*Simulacion, error) {
+	if err := p.limites.validar(sol); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	if err := p.admitir(sol); err != nil {
+		p.mu.Unlock()
+		registroSim.InfoContext(padre, "simulación rechazada", "topico", sol.Topico, "cliente", sol.Cliente, "motivo", err)
+		return nil, err
+	}
+
+	t := &trabajo{
+		sim:       Nueva(padre, sol.Topico, p.hub),
+		solicitud: sol,
+		encolado:  time.Now(),
+		tomado:    make(chan struct{}),
+	}
+	t.sim.Repeticion = sol.Repeticion
+	p.cola = append(p.cola, t)
+	p.porCliente[sol.Cliente]++
+	simulacionesActivas.WithLabelValues(sol.Topico, estadoEnCola).Inc()
+	posicion := len(p.cola)
+	p.mu.Unlock()
+
+	registroSim.DebugContext(padre, "simulación encolada", "simulacion", t.sim.ID, "cliente", sol.Cliente, "posicion", posicion)
+	if espectador != nil {
+		p.hub.Suscribir(espectador, t.sim.ID)
+	}
+	t.sim.Avisar(MensajeWS{Tipo: TipoEnCola, Obj: map[string]any{"posicion": posicion}})
+	p.despertar()
+
+	// Una simulación cancelada mientras espera no llega a ningún trabajador
+	go func() {
+		select {
+		case <-t.tomado:
+		case <-t.sim.ctx.Done():
+			if p.quitarDeCola(t) {
+				registroSim.InfoContext(t.sim.ctx, "simulación cancelada en cola", "simulacion", t.sim.ID)
+				t.sim.descartar()
+			}
+		}
+	}()
+
+	return t.sim, nil
+}
+
+// Apagar deja de aceptar simulaciones y cancela las que esperan y las que
+// corren; cada una publica su "cancelado" con el código servidor_apagandose
+// para que los clientes sepan que no fue un error suyo. Espera a que todas
+// terminen o a que venza ctx.
+func (p *Planificador) Apagar(ctx context.Context) error {
+	p.mu.Lock()
+	p.apagando = true
+	sims := p.posiciones()
+	for _, t := range p.corriendo {
+		sims = append(sims, t.sim)
+	}
+	p.mu.Unlock()
+
+	for _, sim := range sims {
+		sim.cancelarPorApagado()
+	}
+	for _, sim := range sims {
+		select {
+		case <-sim.Terminada():
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (p *Planificador) trabajador() {
+	for {
+		t := p.tomar()
+		if t == nil {
+			<-p.aviso
+			continue
+		}
+
+		span := t.trazar()
+		registroSim.InfoContext(t.sim.traza, "simulación iniciada", "simulacion", t.sim.ID,
+			"cliente", t.solicitud.Cliente, "espera_ms", t.iniciado.Sub(t.encolado).Milliseconds())
+		esperaEnCola.WithLabelValues(t.sim.Topico).Observe(t.iniciado.Sub(t.encolado).Seconds())
+		listo := t.sim.goroutine()
+		err := t.sim.Ejecutar(func(s *Simulacion) error {
+			return t.observar(func() error {
+				switch t.solicitud.Topico {
+				case "mpi":
+					return CorrerMPI(s, t.solicitud.Sectores, t.solicitud.Vueltas, p.ritmo.PasoMPI)
+				case "telemetria":
+					return CorrerRepeticion(s, t.solicitud.Repeticion)
+				default:
+					return CorrerOpenMP(s, t.solicitud.Autos, t.solicitud.Vueltas, p.ritmo.PasoOpenMP)
+				}
+			})
+		})
+		listo()
+		registrarFin(t, err, span)
+
+		p.mu.Lock()
+		delete(p.corriendo, t.sim.ID)
+		simulacionesActivas.WithLabelValues(t.sim.Topico, estadoCorriendo).Dec()
+		p.liberarCliente(t.solicitud.Cliente)
+		p.mu.Unlock()
+	}
+}
+
+// admitir aplica los límites de la cola; debe llamarse con p.mu tomado.
+func (p *Planificador) admitir(sol Solicitud) error {
+	switch {
+	case p.apagando:
+		return ErrApagando
+	case len(p.cola) >= p.limites.MaxEnCola:
+		return ErrColaLlena
+	case p.porCliente[sol.Cliente] >= p.limites.MaxPorCliente:
+		return fmt.Errorf("%w (%d)", ErrLimiteCliente, p.limites.MaxPorCliente)
+	}
+	return nil
+}
+
+// registrarFin registra cómo terminó una simulación que llegó a correr, en
+// los logs, en las métricas y en su span, que cierra.
+func registrarFin(t *trabajo, err error, span trace.Span) {
+	defer terminarSpan(span, err)
+	transcurrido := time.Since(t.iniciado)
+	duracionSimulacion.WithLabelValues(t.sim.Topico).Observe(transcurrido.Seconds())
+	duracion := slog.Int64("duracion_ms", transcurrido.Milliseconds())
+	switch {
+	case err == nil:
+		simulacionesTerminadas.WithLabelValues(t.sim.Topico, "completada").Inc()
+		registroSim.InfoContext(t.sim.traza, "simulación completada", "simulacion", t.sim.ID, duracion)
+	case errors.Is(err, context.Canceled):
+		simulacionesTerminadas.WithLabelValues(t.sim.Topico, "cancelada").Inc()
+		registroSim.InfoContext(t.sim.traza, "simulación cancelada", "simulacion", t.sim.ID, duracion)
+	default:
+		simulacionesTerminadas.WithLabelValues(t.sim.Topico, "error").Inc()
+		registroSim.WarnContext(t.sim.traza, "simulación con error", "simulacion", t.sim.ID, duracion, "error", err)
+	}
+}
+
+// tomar saca el primer trabajo de la cola, o devuelve nil si está vacía.
+func (p *Planificador) tomar() *trabajo {
+	p.mu.Lock()
+	if len(p.cola) == 0 {
+		p.mu.Unlock()
+		return nil
+	}
+	t := p.cola[0]
+	p.cola = p.cola[1:]
+	t.iniciado = time.Now()
+	p.corriendo[t.sim.ID] = t
+	simulacionesActivas.WithLabelValues(t.sim.Topico, estadoEnCola).Dec()
+	simulacionesActivas.WithLabelValues(t.sim.Topico, estadoCorriendo).Inc()
+	pendientes := p.posiciones()
+	p.mu.Unlock()
+
+	close(t.tomado)
+	p.avisarPosiciones(pendientes)
+	if len(pendientes) > 0 {
+		// Quedan trabajos: otro trabajador libre puede tomarlos
+		p.despertar()
+	}
+	return t
+}
+
+func (p *Planificador) quitarDeCola(t *trabajo) bool {
+	p.mu.Lock()
+	for i, enCola := range p.cola {
+		if enCola == t {
+			p.cola = append(p.cola[:i], p.cola[i+1:]...)
+			p.liberarCliente(t.solicitud.Cliente)
+			simulacionesActivas.WithLabelValues(t.sim.Topico, estadoEnCola).Dec()
+			pendientes := p.posiciones()
+			p.mu.Unlock()
+			p.avisarPosiciones(pendientes)
+			return true
+		}
+	}
+	p.mu.Unlock()
+	return false
+}
+
+func (p *Planificador) despertar() {
+	select {
+	case p.aviso <- struct{}{}:
+	default:
+	}
+}
+
+// liberarCliente debe llamarse con p.mu tomado.
+func (p *Planificador) liberarCliente(cliente string) {
+	p.porCliente[cliente]--
+	if p.porCliente[cliente] <= 0 {
+		delete(p.porCliente, cliente)
+	}
+}
+
+// posiciones copia la cola actual; debe llamarse con p.mu tomado.
+func (p *Planificador) posiciones() []*Simulacion {
+	sims := make([]*Simulacion, len(p.cola))
+	for i, t := range p.cola {
+		sims[i] = t.sim
+	}
+	return sims
+}
+
+func (p *Planificador) avisarPosiciones(sims []*Simulacion) {
+	for i, sim := range sims {
+		sim.Avisar(MensajeWS{Tipo: TipoEnCola, Obj: map[string]any{"posicion": i + 1}})
+	}
+}
+
+// -------------------- Estado de la cola --------------------
+
+// EstadoTrabajo resume una simulación en cola o en ejecución.
+type EstadoTrabajo struct {
+	ID       string     `json:"id"`
+	Topico   string     `json:"topico"`
+	Cliente  string     `json:"cliente"`
+	Estado   string     `json:"estado"`
+	Posicion int        `json:"posicion,omitempty"`
+	Autos    int        `json:"autos,omitempty"`
+	Sectores int        `json:"sectores,omitempty"`
+	Vueltas  int        `json:"vueltas"`
+	Encolado time.Time  `json:"encolado"`
+	Iniciado *time.Time `json:"iniciado,omitempty"`
+}
+
+// EstadoCola es la foto del planificador que se expone por la API.
+type EstadoCola struct {
+	Limites   Limites         `json:"limites"`
+	Corriendo []EstadoTrabajo `json:"corriendo"`
+	EnCola    []EstadoTrabajo `json:"en_cola"`
+}
+
+// Estado devuelve las simulaciones en cola y en ejecución.
+func (p *Planificador) Estado() EstadoCola {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	estado := EstadoCola{
+		Limites:   p.limites,
+		Corriendo: make([]EstadoTrabajo, 0, len(p.corriendo)),
+		EnCola:    make([]EstadoTrabajo, 0, len(p.cola)),
+	}
+	for _, t := range p.corriendo {
+		e := t.estado("corriendo")
+		if t.sim.Pausada() {
+			e.Estado = "pausada"
+		}
+		iniciado := t.iniciado
+		e.Iniciado = &iniciado
+		estado.Corriendo = append(estado.Corriendo, e)
+	}
+	sort.Slice(estado.Corriendo, func(i, j int) bool {
+		return estado.Corriendo[i].Iniciado.Before(*estado.Corriendo[j].Iniciado)
+	})
+	for i, t := range p.cola {
+		e := t.estado("en_cola")
+		e.Posicion = i + 1
+		estado.EnCola = append(estado.EnCola, e)
+	}
+	return estado
+}
+
+func (t *trabajo) estado(estado string) EstadoTrabajo {
+	return EstadoTrabajo{
+		ID:       t.sim.ID,
+		Topico:   t.sim.Topico,
+		Cliente:  t.solicitud.Cliente,
+		Estado:   estado,
+		Autos:    t.solicitud.Autos,
+		Sectores: t.solicitud.Sectores,
+		Vueltas:  t.solicitud.Vueltas,
+		Encolado: t.encolado,
+	}
+}
@@ -0,0 +1,373 @@
+package simulacion
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// VersionProtocolo es la versión del protocolo WebSocket que habla el servidor.
+const VersionProtocolo = 1
+
+// VersionesSoportadas lista las versiones que un cliente puede pedir en hello.
+var VersionesSoportadas = []int{1}
+
+// SchemaProtocolo es el JSON Schema de los comandos y mensajes del protocolo.
+//
+//go:embed protocolo.schema.json
+var SchemaProtocolo []byte
+
+// Acciones que puede enviar el cliente
+const (
+	AccionHello         = "hello"
+	AccionIniciarMPI    = "iniciar_mpi"
+	AccionIniciarOpenMP = "iniciar_openmp"
+	AccionCancelar      = "cancelar"
+	AccionPausar        = "pausar"
+	AccionReanudar      = "reanudar"
+	AccionSuscribir     = "suscribir"
+	AccionDesuscribir   = "desuscribir"
+	AccionRetomar       = "retomar"
+	AccionReproducir    = "reproducir_telemetria"
+	AccionVelocidad     = "velocidad"
+	AccionSaltar        = "saltar"
+)
+
+// Códigos de los mensajes de error
+const (
+	CodigoJSONInvalido       = "json_invalido"
+	CodigoAccionDesconocida  = "accion_desconocida"
+	CodigoCampoInvalido      = "campo_invalido"
+	CodigoVersionNoSoportada = "version_no_soportada"
+	CodigoLimiteExcedido     = "limite_excedido"
+	CodigoColaLlena          = "cola_llena"
+	CodigoLimiteCliente      = "limite_cliente"
+	CodigoNoEncontrada       = "no_encontrada"
+	CodigoEstadoInvalido     = "estado_invalido"
+	CodigoTokenInvalido      = "token_invalido"
+	CodigoServidorApagandose = "servidor_apagandose"
+	// CodigoErrorInterno es una falla del servidor (la base no responde,
+	// ...), no del comando; el detalle queda en el log
+	CodigoErrorInterno = "error_interno"
+)
+
+// -------------------- Comandos --------------------
+
+// Sobre son los campos comunes a todos los comandos.
+type Sobre struct {
+	Action    string `json:"action"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+type ComandoHello struct {
+	Sobre
+	Version int    `json:"version"`
+	Cliente string `json:"cliente,omitempty"`
+}
+
+type ComandoIniciarMPI struct {
+	Sobre
+	Sectores int `json:"sectores"`
+	Vueltas  int `json:"vueltas"`
+}
+
+type ComandoIniciarOpenMP struct {
+	Sobre
+	Autos   int `json:"autos"`
+	Vueltas int `json:"vueltas"`
+}
+
+// ComandoControl sirve para cancelar, pausar y reanudar.
+type ComandoControl struct {
+	Sobre
+	SimulacionID string `json:"simulacion_id"`
+}
+
+// ComandoSuscripcion sirve para suscribir y desuscribir, por ID o por tópico.
+type ComandoSuscripcion struct {
+	Sobre
+	SimulacionID string `json:"simulacion_id,omitempty"`
+	Topico       string `json:"topico,omitempty"`
+}
+
+// ComandoRetomar vuelve a suscribir a un cliente reconectado a partir de la
+// última secuencia que vio; con el token recupera además el control.
+type ComandoRetomar struct {
+	Sobre
+	SimulacionID string `json:"simulacion_id"`
+	UltimoSeq    uint64 `json:"ultimo_seq"`
+	Token        string `json:"token,omitempty"`
+}
+
+// ComandoReproducir inicia la reproducción de una sesión de telemetría
+// guardada, a velocidad veces el tiempo real y desde el segundo desde.
+type ComandoReproducir struct {
+	Sobre
+	SesionID  uint    `json:"sesion_id"`
+	Velocidad float64 `json:"velocidad,omitempty"`
+	Desde     float64 `json:"desde,omitempty"`
+}
+
+// ComandoVelocidad cambia la velocidad de una reproducción en curso.
+type ComandoVelocidad struct {
+	Sobre
+	SimulacionID string  `json:"simulacion_id"`
+	Velocidad    float64 `json:"velocidad"`
+}
+
+// ComandoSaltar mueve una reproducción al segundo tiempo de la sesión.
+type ComandoSaltar struct {
+	Sobre
+	SimulacionID string  `json:"simulacion_id"`
+	Tiempo       float64 `json:"tiempo"`
+}
+
+// ErrorProtocolo describe un comando rechazado; se envía como mensaje "error".
+type ErrorProtocolo struct {
+	Codigo    string
+	Texto     string
+	Campo     string
+	RequestID string
+}
+
+func (e *ErrorProtocolo) Error() string {
+	return e.Codigo + ": " + e.Texto
+}
+
+// Mensaje convierte el error en el frame que recibe el cliente.
+func (e *ErrorProtocolo) Mensaje() MensajeWS {
+	msg := MensajeWS{Tipo: TipoError, Codigo: e.Codigo, Texto: e.Texto, RequestID: e.RequestID}
+	if e.Campo != "" {
+		msg.Obj = map[string]any{"campo": e.Campo}
+	}
+	return msg
+}
+
+// NuevoError arma un ErrorProtocolo para responder a un comando.
+func NuevoError(codigo string, requestID string, formato string, args ...any) *ErrorProtocolo {
+	return &ErrorProtocolo{Codigo: codigo, Texto: fmt.Sprintf(formato, args...), RequestID: requestID}
+}
+
+// ErrorDeEncolar traduce los errores del planificador a códigos del protocolo.
+func ErrorDeEncolar(err error, requestID string) *ErrorProtocolo {
+	codigo := CodigoCampoInvalido
+	switch {
+	case errors.Is(err, ErrLimiteExcedido):
+		codigo = CodigoLimiteExcedido
+	case errors.Is(err, ErrColaLlena):
+		codigo = CodigoColaLlena
+	case errors.Is(err, ErrLimiteCliente):
+		codigo = CodigoLimiteCliente
+	case errors.Is(err, ErrApagando):
+		codigo = CodigoServidorApagandose
+	}
+	return NuevoError(codigo, requestID, "Simulación rechazada: %v", err)
+}
+
+// DecodificarComando valida un frame del cliente y devuelve el comando tipado
+// correspondiente (ComandoHello, ComandoIniciarMPI, ...).
+func DecodificarComando(datos []byte) (any, *ErrorProtocolo) {
+	var sobre Sobre
+	if err := json.Unmarshal(datos, &sobre); err != nil {
+		return nil, NuevoError(CodigoJSONInvalido, "", "El mensaje no es un objeto JSON válido: %v", err)
+	}
+
+	switch sobre.Action {
+	case AccionHello:
+		var c ComandoHello
+		if err := decodificarEstricto(datos, &c); err != nil {
+			return nil, err
+		}
+		if !versionSoportada(c.Version) {
+			return nil, NuevoError(CodigoVersionNoSoportada, c.RequestID, "Versión %d no soportada; versiones disponibles: %v", c.Version, VersionesSoportadas)
+		}
+		return c, nil
+	case AccionIniciarMPI:
+		var c ComandoIniciarMPI
+		if err := decodificarEstricto(datos, &c); err != nil {
+			return nil, err
+		}
+		if err := positivo(c.Sobre, "sectores", c.Sectores); err != nil {
+			return nil, err
+		}
+		if err := positivo(c.Sobre, "vueltas", c.Vueltas); err != nil {
+			return nil, err
+		}
+		return c, nil
+	case AccionIniciarOpenMP:
+		var c ComandoIniciarOpenMP
+		if err := decodificarEstricto(datos, &c); err != nil {
+			return nil, err
+		}
+		if err := positivo(c.Sobre, "autos", c.Autos); err != nil {
+			return nil, err
+		}
+		if err := positivo(c.Sobre, "vueltas", c.Vueltas); err != nil {
+			return nil, err
+		}
+		return c, nil
+	case AccionCancelar, AccionPausar, AccionReanudar:
+		var c ComandoControl
+		if err := decodificarEstricto(datos, &c); err != nil {
+			return nil, err
+		}
+		if c.SimulacionID == "" {
+			return nil, errorDeCampo(c.Sobre, "simulacion_id", "es obligatorio")
+		}
+		return c, nil
+	case AccionSuscribir, AccionDesuscribir:
+		var c ComandoSuscripcion
+		if err := decodificarEstricto(datos, &c); err != nil {
+			return nil, err
+		}
+		if (c.SimulacionID == "") == (c.Topico == "") {
+			return nil, errorDeCampo(c.Sobre, "simulacion_id", "indicar simulacion_id o topico, pero no ambos")
+		}
+		if c.Topico != "" && c.Topico != "mpi" && c.Topico != "openmp" && c.Topico != "telemetria" {
+			return nil, errorDeCampo(c.Sobre, "topico", `debe ser "mpi", "openmp" o "telemetria"`)
+		}
+		return c, nil
+	case AccionRetomar:
+		var c ComandoRetomar
+		if err := decodificarEstricto(datos, &c); err != nil {
+			return nil, err
+		}
+		if c.SimulacionID == "" {
+			return nil, errorDeCampo(c.Sobre, "simulacion_id", "es obligatorio")
+		}
+		return c, nil
+	case AccionReproducir:
+		var c ComandoReproducir
+		if err := decodificarEstricto(datos, &c); err != nil {
+			return nil, err
+		}
+		if c.SesionID == 0 {
+			return nil, errorDeCampo(c.Sobre, "sesion_id", "es obligatorio")
+		}
+		if c.Velocidad == 0 {
+			c.Velocidad = 1
+		}
+		if err := velocidadValida(c.Sobre, c.Velocidad); err != nil {
+			return nil, err
+		}
+		if c.Desde < 0 {
+			return nil, errorDeCampo(c.Sobre, "desde", "no puede ser negativo")
+		}
+		return c, nil
+	case AccionVelocidad:
+		var c ComandoVelocidad
+		if err := decodificarEstricto(datos, &c); err != nil {
+			return nil, err
+		}
+		if c.SimulacionID == "" {
+			return nil, errorDeCampo(c.Sobre, "simulacion_id", "es obligatorio")
+		}
+		if err := velocidadValida(c.Sobre, c.Velocidad); err != nil {
+			return nil, err
+		}
+		return c, nil
+	case AccionSaltar:
+		var c ComandoSaltar
+		if err := decodificarEstricto(datos, &c); err != nil {
+			return nil, err
+		}
+		if c.SimulacionID == "" {
+			return nil, errorDeCampo(c.Sobre, "simulacion_id", "es obligatorio")
+		}
+		if c.Tiempo < 0 {
+			return nil, errorDeCampo(c.Sobre, "tiempo", "no puede ser negativo")
+		}
+		return c, nil
+	case "":
+		return nil, errorDeCampo(sobre, "action", "es obligatorio")
+	default:
+		return nil, NuevoError(CodigoAccionDesconocida, sobre.RequestID, "Comando no reconocido: %q", sobre.Action)
+	}
+}
+
+// decodificarEstricto rechaza campos desconocidos y tipos incorrectos en vez
+// de usar valores por defecto en silencio.
+func decodificarEstricto(datos []byte, destino any) *ErrorProtocolo {
+	dec := json.NewDecoder(bytes.NewReader(datos))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(destino)
+	if err == nil {
+		return nil
+	}
+
+	var sobre Sobre
+	json.Unmarshal(datos, &sobre)
+
+	var errTipo *json.UnmarshalTypeError
+	if errors.As(err, &errTipo) {
+		return errorDeCampo(sobre, errTipo.Field, fmt.Sprintf("se esperaba %s y se recibió %s", errTipo.Type, errTipo.Value))
+	}
+	if campo, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return errorDeCampo(sobre, strings.Trim(campo, `"`), "campo desconocido")
+	}
+	return NuevoError(CodigoJSONInvalido, sobre.RequestID, "El mensaje no es un objeto JSON válido: %v", err)
+}
+
+func positivo(sobre Sobre, campo string, valor int) *ErrorProtocolo {
+	if valor < 1 {
+		return errorDeCampo(sobre, campo, "debe ser un entero >= 1")
+	}
+	return nil
+}
+
+func velocidadValida(sobre Sobre, velocidad float64) *ErrorProtocolo {
+	if velocidad < VelocidadMinima || velocidad > VelocidadMaxima {
+		return errorDeCampo(sobre, "velocidad", fmt.Sprintf("debe estar entre %g y %g", VelocidadMinima, VelocidadMaxima))
+	}
+	return nil
+}
+
+func errorDeCampo(sobre Sobre, campo, detalle string) *ErrorProtocolo {
+	return &ErrorProtocolo{
+		Codigo:    CodigoCampoInvalido,
+		Texto:     campo + ": " + detalle,
+		Campo:     campo,
+		RequestID: sobre.RequestID,
+	}
+}
+
+func versionSoportada(version int) bool {
+	for _, v := range VersionesSoportadas {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// -------------------- Handshake --------------------
+
+// Capacidades es lo que el servidor anuncia en el mensaje "hello".
+type Capacidades struct {
+	Version             int      `json:"version"`
+	VersionesSoportadas []int    `json:"versiones_soportadas"`
+	Acciones            []string `json:"acciones"`
+	Topicos             []string `json:"topicos"`
+	Limites             Limites  `json:"limites"`
+	Schema              string   `json:"schema"`
+}
+
+// NuevasCapacidades describe el protocolo con los límites del planificador.
+func NuevasCapacidades(limites Limites, schema string) Capacidades {
+	return Capacidades{
+		Version:             VersionProtocolo,
+		VersionesSoportadas: VersionesSoportadas,
+		Acciones: []string{
+			AccionHello, AccionIniciarMPI, AccionIniciarOpenMP,
+			AccionCancelar, AccionPausar, AccionReanudar,
+			AccionSuscribir, AccionDesuscribir, AccionRetomar,
+			AccionReproducir, AccionVelocidad, AccionSaltar,
+		},
+		Topicos: []string{"mpi", "openmp", "telemetria"},
+		Limites: limites,
+		Schema:  schema,
+	}
+}
@@ -0,0 +1,170 @@
+package simulacion
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"formula1-crud-go/telemetria"
+)
+
+// Velocidades de reproducción admitidas (1 = tiempo real)
+const (
+	VelocidadMinima = 0.1
+	VelocidadMaxima = 50.0
+)
+
+// Atraso a partir del cual la reproducción deja de recuperar tiempo (tras una
+// pausa o un cliente lento) y vuelve a tomar el reloj como referencia
+const maxAtrasoRepeticion = 250 * time.Millisecond
+
+// ErrVelocidadInvalida se devuelve al pedir una velocidad fuera de rango.
+var ErrVelocidadInvalida = fmt.Errorf("la velocidad debe estar entre %g y %g", VelocidadMinima, VelocidadMaxima)
+
+// ErrNoEsRepeticion se devuelve al saltar o cambiar la velocidad de una
+// simulación que no reproduce telemetría.
+var ErrNoEsRepeticion = errors.New("la simulación no reproduce telemetría")
+
+// MuestraRepeticion es el contenido de cada mensaje "muestra".
+type MuestraRepeticion struct {
+	Indice       int     `json:"indice"`
+	Transcurrido float64 `json:"transcurrido"` // segundos desde la primera muestra
+	telemetria.Muestra
+}
+
+// Repeticion reproduce las muestras guardadas de una sesión respetando los
+// tiempos originales multiplicados por la velocidad. Velocidad y posición
+// pueden cambiarse mientras corre.
+type Repeticion struct {
+	SesionID uint
+	Canales  []telemetria.Canal
+	muestras []telemetria.Muestra
+
+	mu        sync.Mutex
+	velocidad float64
+	salto     int // índice pedido por Saltar, -1 si no hay ninguno
+	cambio    chan struct{}
+}
+
+// NuevaRepeticion prepara la reproducción desde el segundo desde (relativo a
+// la primera muestra).
+func NuevaRepeticion(sesionID uint, muestras []telemetria.Muestra, canales []telemetria.Canal, velocidad, desde float64) (*Repeticion, error) {
+	if len(muestras) == 0 {
+		return nil, errors.New("la sesión no tiene muestras")
+	}
+	r := &Repeticion{
+		SesionID: sesionID,
+		Canales:  canales,
+		muestras: muestras,
+		salto:    -1,
+		cambio:   make(chan struct{}, 1),
+	}
+	if err := r.CambiarVelocidad(velocidad); err != nil {
+		return nil, err
+	}
+	if desde > 0 {
+		r.Saltar(desde)
+	}
+	return r, nil
+}
+
+// Duracion es el tiempo de sesión que cubren las muestras, en segundos.
+func (r *Repeticion) Duracion() float64 {
+	return r.muestras[len(r.muestras)-1].Tiempo - r.muestras[0].Tiempo
+}
+
+// Muestras devuelve cuántas muestras tiene la reproducción.
+func (r *Repeticion) Muestras() int {
+	return len(r.muestras)
+}
+
+// CambiarVelocidad ajusta el multiplicador sobre el tiempo real.
+func (r *Repeticion) CambiarVelocidad(velocidad float64) error {
+	if velocidad < VelocidadMinima || velocidad > VelocidadMaxima {
+		return ErrVelocidadInvalida
+	}
+	r.mu.Lock()
+	r.velocidad = velocidad
+	r.mu.Unlock()
+	r.avisar()
+	return nil
+}
+
+// Saltar mueve la reproducción a la primera muestra en el segundo tiempo
+// (relativo a la primera muestra); fuera de rango se ajusta a los extremos.
+func (r *Repeticion) Saltar(tiempo float64) {
+	objetivo := r.muestras[0].Tiempo + tiempo
+	i := sort.Search(len(r.muestras), func(i int) bool { return r.muestras[i].Tiempo >= objetivo })
+	r.mu.Lock()
+	r.salto = min(i, len(r.muestras)-1)
+	r.mu.Unlock()
+	r.avisar()
+}
+
+func (r *Repeticion) avisar() {
+	select {
+	case r.cambio <- struct{}{}:
+	default:
+	}
+}
+
+// pendiente devuelve la velocidad actual y el salto pedido, si hay uno.
+func (r *Repeticion) pendiente() (float64, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	salto := r.salto
+	r.salto = -1
+	return r.velocidad, salto
+}
+
+// CorrerRepeticion emite cada muestra como un mensaje "muestra" en el momento
+// en que ocurrió. Las muestras son avisos efímeros: no se guardan en el
+// historial, así una sesión larga no ocupa memoria en el hub y quien se
+// suma a mitad de la reproducción la ve desde ese punto.
+func CorrerRepeticion(s *Simulacion, r *Repeticion) error {
+	velocidad, i := r.pendiente()
+	i = max(i, 0)
+	err := s.Enviar(MensajeWS{Tipo: TipoRegistro, Texto: fmt.Sprintf(
+		"Reproduciendo sesión %d: %d muestras, %.1f s a %gx", r.SesionID, len(r.muestras), r.Duracion(), velocidad)})
+	if err != nil {
+		return err
+	}
+
+	// Cada muestra se programa respecto de un ancla (reloj, tiempo de sesión)
+	// para que los errores de cada espera no se acumulen
+	ancla, base := time.Now(), r.muestras[i].Tiempo
+	for i < len(r.muestras) {
+		m := r.muestras[i]
+		espera := ancla.Add(time.Duration((m.Tiempo - base) / velocidad * float64(time.Second))).Sub(time.Now())
+		if espera < -maxAtrasoRepeticion {
+			ancla, base, espera = time.Now(), m.Tiempo, 0
+		}
+
+		interrumpida, err := s.dormir(espera, r.cambio)
+		if err != nil {
+			return err
+		}
+		if interrumpida {
+			var salto int
+			velocidad, salto = r.pendiente()
+			if salto >= 0 {
+				i = salto
+				s.Avisar(MensajeWS{Tipo: TipoSalto, Obj: map[string]any{
+					"indice": i, "tiempo": r.muestras[i].Tiempo - r.muestras[0].Tiempo, "velocidad": velocidad,
+				}})
+			}
+			ancla, base = time.Now(), r.muestras[i].Tiempo
+			continue
+		}
+
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
+		s.Avisar(MensajeWS{Tipo: TipoMuestra, Obj: MuestraRepeticion{Indice: i, Transcurrido: m.Tiempo - r.muestras[0].Tiempo, Muestra: m}})
+		i++
+	}
+
+	return s.Enviar(MensajeWS{Tipo: TipoFinalizado})
+}
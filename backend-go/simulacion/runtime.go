@@ -0,0 +1,62 @@
+package simulacion
+
+import (
+	"context"
+	"io"
+	"runtime/pprof"
+	rtrace "runtime/trace"
+)
+
+// CapturaTraza pide la traza de runtime/trace de una corrida, para abrirla
+// con go tool trace. La traza es de todo el proceso mientras la simulación
+// corre; sus goroutines se reconocen por la tarea "simulacion <tópico>" y
+// las regiones de cada auto.
+type CapturaTraza struct {
+	Salida io.Writer
+	// Err dice por qué no se capturó (por ejemplo, otra traza en curso).
+	// Salida y Err se pueden leer cuando la simulación está Terminada; si
+	// las dos quedaron vacías, la simulación se canceló antes de correr.
+	Err error
+}
+
+// observar corre f, la ejecución del trabajo, con las etiquetas de pprof
+// simulacion y topico, que heredan las goroutines que lance y aparecen en
+// los perfiles de CPU y de goroutines, y dentro de una tarea de
+// runtime/trace. Si la solicitud lo pidió, captura la traza de la corrida.
+// Va dentro de Ejecutar para que la traza esté completa al terminar.
+func (t *trabajo) observar(f func() error) (err error) {
+	if captura := t.solicitud.Traza; captura != nil {
+		if err := rtrace.Start(captura.Salida); err != nil {
+			captura.Err = err
+		} else {
+			defer rtrace.Stop()
+		}
+	}
+
+	ctx, tarea := rtrace.NewTask(t.sim.traza, "simulacion "+t.sim.Topico)
+	defer tarea.End()
+	rtrace.Log(ctx, "simulacion", t.sim.ID)
+	t.sim.traza = ctx
+
+	pprof.Do(ctx, pprof.Labels("simulacion", t.sim.ID, "topico", t.sim.Topico), func(context.Context) { err = f() })
+	return err
+}
+
+// region marca en la traza de runtime/trace el trabajo de una goroutine de
+// la simulación: defer s.region("auto 3")().
+func (s *Simulacion) region(nombre string) func() {
+	return rtrace.StartRegion(s.traza, nombre).End
+}
+
+// GoroutinesPorSimulacion cuenta las goroutines de cada simulación que
+// corre: la del trabajador más las que haya lanzado.
+func (p *Planificador) GoroutinesPorSimulacion() map[string]int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	goroutines := make(map[string]int64, len(p.corriendo))
+	for id, t := range p.corriendo {
+		goroutines[id] = t.sim.goroutines.Load()
+	}
+	return goroutines
+}
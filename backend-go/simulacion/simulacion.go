@@ -0,0 +1,276 @@
+package simulacion
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNoPausada se devuelve al reanudar una simulación que no estaba pausada.
+var ErrNoPausada = errors.New("la simulación no está pausada")
+
+// ErrYaPausada se devuelve al pausar una simulación que ya estaba pausada.
+var ErrYaPausada = errors.New("la simulación ya está pausada")
+
+// ErrTokenInvalido se devuelve al intentar adoptar una simulación sin su token.
+var ErrTokenInvalido = errors.New("token de simulación inválido")
+
+// Simulacion representa una corrida identificada por ID que puede
+// cancelarse, pausarse y reanudarse mientras sus goroutines trabajan.
+type Simulacion struct {
+	ID     string
+	Topico string
+	// Repeticion solo existe en las simulaciones del tópico "telemetria"
+	Repeticion *Repeticion
+	// Token permite que quien inició la simulación recupere su control al
+	// reconectarse; solo se le entrega a ese cliente.
+	Token string
+
+	ctx      context.Context
+	cancelar context.CancelFunc
+	hub      *Hub
+	// traza es ctx con el span y la tarea de runtime/trace de la corrida;
+	// lo fija el trabajador antes de Ejecutar y lo leen las goroutines que
+	// la corrida lance
+	traza      context.Context
+	goroutines atomic.Int64
+
+	mu        sync.Mutex
+	reanudar  chan struct{} // nil mientras no esté pausada
+	abandono  *time.Timer   // cancela la simulación si nadie la adopta
+	dueno     Dueno         // el último que la adoptó
+	apagado   bool          // cancelada porque el servidor se apaga
+	terminada chan struct{}
+}
+
+// Nueva crea una simulación cuyo contexto deriva de padre (normalmente la
+// conexión del cliente que la inició) y abre su transmisión en el hub.
+// Cancelar padre detiene la simulación.
+func Nueva(padre context.Context, topico string, hub *Hub) *Simulacion {
+	ctx, cancelar := context.WithCancel(padre)
+	s := &Simulacion{
+		ID:        topico + "-" + nuevoID(),
+		Topico:    topico,
+		Token:     nuevoID() + nuevoID(),
+		ctx:       ctx,
+		traza:     ctx,
+		cancelar:  cancelar,
+		hub:       hub,
+		terminada: make(chan struct{}),
+	}
+	hub.abrir(s.ID, topico)
+	return s
+}
+
+// Ejecutar corre la simulación en la goroutine actual y envía el mensaje
+// final de cancelación si la corrida fue interrumpida. Devuelve el error
+// de correr.
+func (s *Simulacion) Ejecutar(correr func(*Simulacion) error) error {
+	defer close(s.terminada)
+	defer s.hub.finalizar(s.ID)
+	defer s.cancelar()
+
+	s.Enviar(MensajeWS{Tipo: TipoIniciado})
+
+	err := correr(s)
+	if errors.Is(err, context.Canceled) {
+		s.enviarCancelado()
+	}
+	return err
+}
+
+// descartar termina una simulación que fue cancelada antes de empezar.
+func (s *Simulacion) descartar() {
+	defer close(s.terminada)
+	defer s.hub.finalizar(s.ID)
+	s.enviarCancelado()
+}
+
+func (s *Simulacion) enviarCancelado() {
+	msg := MensajeWS{Tipo: TipoCancelado, Texto: "Simulación cancelada"}
+	s.mu.Lock()
+	if s.apagado {
+		msg.Codigo, msg.Texto = CodigoServidorApagandose, "Simulación cancelada: el servidor se está apagando"
+	}
+	s.mu.Unlock()
+	// Se publica aunque el contexto ya esté cancelado: es el último mensaje
+	s.hub.publicar(s.completar(msg))
+}
+
+// Enviar publica un mensaje de la simulación para todos sus espectadores.
+// Devuelve el error del contexto si la simulación ya fue cancelada.
+func (s *Simulacion) Enviar(msg MensajeWS) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	s.hub.publicar(s.completar(msg))
+	return nil
+}
+
+// Avisar difunde un mensaje de estado que no se guarda en el historial, como
+// la posición en la cola.
+func (s *Simulacion) Avisar(msg MensajeWS) {
+	s.hub.difundir(s.completar(msg))
+}
+
+// Dormir reemplaza a time.Sleep dentro de las simulaciones: respeta la pausa
+// y retorna de inmediato si la simulación se cancela.
+func (s *Simulacion) Dormir(d time.Duration) error {
+	_, err := s.dormir(d, nil)
+	return err
+}
+
+// dormir es Dormir con un canal que corta la espera antes de tiempo; informa
+// si la espera fue interrumpida.
+func (s *Simulacion) dormir(d time.Duration, interrumpir <-chan struct{}) (bool, error) {
+	if err := s.esperarReanudacion(); err != nil {
+		return false, err
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return false, nil
+	case <-interrumpir:
+		return true, nil
+	case <-s.ctx.Done():
+		return false, s.ctx.Err()
+	}
+}
+
+// Cancelar detiene la simulación; es seguro llamarlo varias veces.
+func (s *Simulacion) Cancelar() {
+	s.cancelar()
+}
+
+func (s *Simulacion) cancelarPorApagado() {
+	s.mu.Lock()
+	s.apagado = true
+	s.mu.Unlock()
+	s.cancelar()
+}
+
+// Pausar suspende la simulación en su próximo Dormir.
+func (s *Simulacion) Pausar() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.reanudar != nil {
+		return ErrYaPausada
+	}
+	s.reanudar = make(chan struct{})
+	return nil
+}
+
+// Reanudar libera a las goroutines detenidas por Pausar.
+func (s *Simulacion) Reanudar() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.reanudar == nil {
+		return ErrNoPausada
+	}
+	close(s.reanudar)
+	s.reanudar = nil
+	return nil
+}
+
+// Dueno identifica una adopción de la simulación. Cada Adoptar entrega uno
+// nuevo y deja sin efecto los anteriores.
+type Dueno uint64
+
+// Abandonar se usa cuando se desconecta el cliente que controla la
+// simulación: si nadie la adopta dentro de gracia, se cancela. Solo cuenta
+// si dueno sigue siendo el último que la adoptó: una conexión vieja que se
+// cae tarde no cancela la simulación que ya retomó otra.
+func (s *Simulacion) Abandonar(dueno Dueno, gracia time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if dueno != s.dueno {
+		return
+	}
+	if gracia <= 0 {
+		s.cancelar()
+		return
+	}
+	if s.abandono == nil {
+		s.abandono = time.AfterFunc(gracia, s.cancelar)
+	}
+}
+
+// Adoptar devuelve el control de la simulación a quien presente su token,
+// incluido quien la creó, y cancela un abandono en curso. El Dueno devuelto
+// es el que se pasa a Abandonar.
+func (s *Simulacion) Adoptar(token string) (Dueno, error) {
+	if token != s.Token {
+		return 0, ErrTokenInvalido
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.abandono != nil {
+		s.abandono.Stop()
+		s.abandono = nil
+	}
+	s.dueno++
+	return s.dueno, nil
+}
+
+// Pausada informa si la simulación está pausada.
+func (s *Simulacion) Pausada() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reanudar != nil
+}
+
+// Terminada se cierra cuando Ejecutar retorna.
+func (s *Simulacion) Terminada() <-chan struct{} {
+	return s.terminada
+}
+
+// Finalizada informa si la simulación ya terminó, por cualquier motivo.
+func (s *Simulacion) Finalizada() bool {
+	select {
+	case <-s.terminada:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *Simulacion) esperarReanudacion() error {
+	s.mu.Lock()
+	reanudar := s.reanudar
+	s.mu.Unlock()
+
+	if reanudar == nil {
+		return s.ctx.Err()
+	}
+
+	select {
+	case <-reanudar:
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+func (s *Simulacion) completar(msg MensajeWS) MensajeWS {
+	if msg.Topico == "" {
+		msg.Topico = s.Topico
+	}
+	msg.SimulacionID = s.ID
+	return msg
+}
+
+func nuevoID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,75 @@
+package simulacion
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+const graciaDePrueba = 20 * time.Millisecond
+
+// cancelada espera un poco más que la gracia y dice si la simulación se canceló.
+func cancelada(s *Simulacion) bool {
+	select {
+	case <-s.ctx.Done():
+		return true
+	case <-time.After(5 * graciaDePrueba):
+		return false
+	}
+}
+
+func TestAbandonarSinAdopcionCancela(t *testing.T) {
+	s := Nueva(context.Background(), "openmp", NuevoHub())
+	dueno, err := s.Adoptar(s.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Abandonar(dueno, graciaDePrueba)
+	if !cancelada(s) {
+		t.Fatal("la simulación abandonada sigue corriendo")
+	}
+}
+
+func TestAdoptarAntesDeAbandonar(t *testing.T) {
+	s := Nueva(context.Background(), "openmp", NuevoHub())
+	viejo, err := s.Adoptar(s.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Otra conexión la retoma mientras la vieja, medio abierta, todavía no
+	// se enteró de que se cortó; después la vieja se cierra y la abandona
+	nuevo, err := s.Adoptar(s.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Abandonar(viejo, graciaDePrueba)
+	s.Abandonar(viejo, 0)
+	if cancelada(s) {
+		t.Fatal("el abandono de la conexión vieja canceló la simulación retomada")
+	}
+
+	// La nueva sigue pudiendo abandonarla
+	s.Abandonar(nuevo, graciaDePrueba)
+	if !cancelada(s) {
+		t.Fatal("el abandono del dueño actual no canceló la simulación")
+	}
+}
+
+func TestAdoptarConTokenInvalido(t *testing.T) {
+	s := Nueva(context.Background(), "openmp", NuevoHub())
+	dueno, err := s.Adoptar(s.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Adoptar("otro"); err != ErrTokenInvalido {
+		t.Fatalf("se esperaba ErrTokenInvalido: %v", err)
+	}
+
+	// Un intento fallido no le quita el control al dueño
+	s.Abandonar(dueno, graciaDePrueba)
+	if !cancelada(s) {
+		t.Fatal("el abandono del dueño no canceló la simulación")
+	}
+}
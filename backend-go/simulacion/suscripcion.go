@@ -0,0 +1,199 @@
+package simulacion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrSuscriptorLento se usa al cerrar una suscripción cuya cola se llenó
+// porque el cliente no lee los mensajes a tiempo.
+var ErrSuscriptorLento = errors.New("el cliente no consume los mensajes a tiempo")
+
+// ErrSuscripcionCerrada indica que la suscripción ya no recibirá mensajes.
+var ErrSuscripcionCerrada = errors.New("suscripción cerrada")
+
+// PoliticaDesborde decide qué hacer cuando la cola de un cliente se llena.
+type PoliticaDesborde string
+
+const (
+	// DesbordeDesconectar cierra la suscripción (y con ella la conexión).
+	DesbordeDesconectar PoliticaDesborde = "desconectar"
+	// DesbordeDescartarAntiguos descarta los mensajes más viejos de la cola.
+	DesbordeDescartarAntiguos PoliticaDesborde = "descartar_antiguos"
+	// DesbordeCoalescer deja solo el último aviso efímero de cada tipo por
+	// simulación (presencia, posición en la cola) y, si no alcanza, descarta
+	// los mensajes más viejos.
+	DesbordeCoalescer PoliticaDesborde = "coalescer"
+)
+
+// ParsePoliticaDesborde valida el nombre de una política.
+func ParsePoliticaDesborde(nombre string) (PoliticaDesborde, error) {
+	switch p := PoliticaDesborde(nombre); p {
+	case DesbordeDesconectar, DesbordeDescartarAntiguos, DesbordeCoalescer:
+		return p, nil
+	default:
+		return "", fmt.Errorf("política de desborde desconocida: %q", nombre)
+	}
+}
+
+// Suscripcion es la cola propia de un cliente. El hub nunca se bloquea
+// escribiendo en ella: si se llena, se aplica la política de desborde.
+type Suscripcion struct {
+	mu          sync.Mutex
+	cola        []MensajeWS
+	max         int
+	politica    PoliticaDesborde
+	descartados int
+	listo       chan struct{}
+	cerrada     chan struct{}
+	motivo      error
+
+	// Administrados por el hub bajo su propio mutex
+	ids     map[string]bool
+	topicos map[string]bool
+}
+
+func nuevaSuscripcion(max int, politica PoliticaDesborde) *Suscripcion {
+	return &Suscripcion{
+		max:      max,
+		politica: politica,
+		listo:    make(chan struct{}, 1),
+		cerrada:  make(chan struct{}),
+		ids:      make(map[string]bool),
+		topicos:  make(map[string]bool),
+	}
+}
+
+// Entregar encola un mensaje sin bloquear. Devuelve false si la suscripción
+// está cerrada o acaba de cerrarse por desborde.
+func (s *Suscripcion) Entregar(msg MensajeWS) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.motivo != nil {
+		return false
+	}
+	if len(s.cola) >= s.max && !s.hacerLugar() {
+		s.cerrarConMotivo(ErrSuscriptorLento)
+		return false
+	}
+	s.cola = append(s.cola, msg)
+
+	select {
+	case s.listo <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// Recibir espera hasta que haya mensajes y devuelve todos los pendientes.
+// Si se descartaron mensajes por desborde, el lote empieza con un aviso
+// "desborde". Devuelve el motivo de cierre una vez que la cola quedó vacía.
+func (s *Suscripcion) Recibir(ctx context.Context) ([]MensajeWS, error) {
+	for {
+		s.mu.Lock()
+		if len(s.cola) > 0 {
+			msgs := s.cola
+			if s.descartados > 0 {
+				aviso := MensajeWS{Tipo: TipoDesborde, Obj: map[string]any{"descartados": s.descartados}}
+				msgs = append([]MensajeWS{aviso}, msgs...)
+				s.descartados = 0
+			}
+			s.cola = nil
+			s.mu.Unlock()
+			return msgs, nil
+		}
+		motivo := s.motivo
+		s.mu.Unlock()
+
+		if motivo != nil {
+			return nil, motivo
+		}
+
+		select {
+		case <-s.listo:
+		case <-s.cerrada:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Cerrada se cierra cuando la suscripción deja de aceptar mensajes.
+func (s *Suscripcion) Cerrada() <-chan struct{} {
+	return s.cerrada
+}
+
+// Motivo devuelve por qué se cerró la suscripción, o nil si sigue abierta.
+func (s *Suscripcion) Motivo() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.motivo
+}
+
+func (s *Suscripcion) cerrar() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cerrarConMotivo(ErrSuscripcionCerrada)
+}
+
+// hacerLugar aplica la política de desborde con la cola llena. Devuelve
+// false si la suscripción debe cerrarse. Debe llamarse con s.mu tomado.
+func (s *Suscripcion) hacerLugar() bool {
+	switch s.politica {
+	case DesbordeCoalescer:
+		if s.coalescer() {
+			return true
+		}
+		fallthrough
+	case DesbordeDescartarAntiguos:
+		s.cola = s.cola[1:]
+		s.descartados++
+		return true
+	default:
+		return false
+	}
+}
+
+// coalescer quita los avisos efímeros que ya fueron reemplazados por uno
+// más nuevo del mismo tipo y simulación. Debe llamarse con s.mu tomado.
+func (s *Suscripcion) coalescer() bool {
+	type clave struct{ id, tipo string }
+	ultimos := make(map[clave]bool)
+	quedan := make([]MensajeWS, 0, len(s.cola))
+
+	// Se recorre desde el final para quedarse con el aviso más reciente
+	for i := len(s.cola) - 1; i >= 0; i-- {
+		msg := s.cola[i]
+		if msg.Seq == 0 && (msg.Tipo == TipoPresencia || msg.Tipo == TipoEnCola) {
+			k := clave{msg.SimulacionID, msg.Tipo}
+			if ultimos[k] {
+				continue
+			}
+			ultimos[k] = true
+		}
+		quedan = append(quedan, msg)
+	}
+	for i, j := 0, len(quedan)-1; i < j; i, j = i+1, j-1 {
+		quedan[i], quedan[j] = quedan[j], quedan[i]
+	}
+
+	liberados := len(s.cola) - len(quedan)
+	s.cola = quedan
+	return liberados > 0
+}
+
+// cerrarConMotivo debe llamarse con s.mu tomado.
+func (s *Suscripcion) cerrarConMotivo(motivo error) {
+	if s.motivo != nil {
+		return
+	}
+	s.motivo = motivo
+	if motivo == ErrSuscriptorLento {
+		// Lo que quedaba en la cola ya no tiene sentido entregarlo
+		s.cola = nil
+	}
+	close(s.cerrada)
+}
@@ -0,0 +1,48 @@
+package simulacion
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var trazador = otel.Tracer("formula1-crud-go/simulacion")
+
+// trazar abre el span de la corrida, hijo del pedido que la encoló, y lo
+// deja en s.traza para que cuelguen de él los spans de sus goroutines.
+func (t *trabajo) trazar() trace.Span {
+	ctx, span := trazador.Start(t.sim.ctx, "simulacion "+t.sim.Topico,
+		trace.WithTimestamp(t.iniciado),
+		trace.WithAttributes(
+			attribute.String("simulacion.id", t.sim.ID),
+			attribute.String("simulacion.topico", t.sim.Topico),
+			attribute.String("simulacion.cliente", t.solicitud.Cliente),
+			attribute.Int64("simulacion.espera_ms", t.iniciado.Sub(t.encolado).Milliseconds()),
+		))
+	t.sim.traza = ctx
+	return span
+}
+
+// trazarGoroutine abre el span de una goroutine de la simulación.
+func (s *Simulacion) trazarGoroutine(nombre string, attrs ...attribute.KeyValue) trace.Span {
+	_, span := trazador.Start(s.traza, nombre, trace.WithAttributes(attrs...))
+	return span
+}
+
+// terminarSpan cierra el span con el resultado de la corrida: cancelar no
+// es un error.
+func terminarSpan(span trace.Span, err error) {
+	switch {
+	case err == nil:
+	case errors.Is(err, context.Canceled):
+		span.SetAttributes(attribute.Bool("simulacion.cancelada", true))
+	default:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
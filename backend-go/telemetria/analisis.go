@@ -0,0 +1,171 @@
+package telemetria
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Agrupacion decide cómo se parte la tabla de resultados.
+type Agrupacion string
+
+const (
+	SinAgrupar       Agrupacion = ""
+	AgruparPorVuelta Agrupacion = "vuelta"
+	AgruparPorSector Agrupacion = "sector"
+)
+
+// OpcionesAnalisis configura las estadísticas de un archivo.
+type OpcionesAnalisis struct {
+	Agrupar Agrupacion
+	// Distancias (m, desde el inicio de la vuelta) donde empiezan los
+	// sectores 2, 3, ...; obligatorias para agrupar por sector
+	Limites []float64
+	// Longitud de la vuelta (m). Si la distancia del archivo es acumulada,
+	// se toma módulo la longitud para ubicar cada muestra en su sector
+	Longitud float64
+	// Intervalos de los histogramas de rango continuo
+	Bins int
+}
+
+// Analisis es el acumulador de estadísticas por canal, en total y por grupo.
+type Analisis struct {
+	opciones OpcionesAnalisis
+	canales  []Canal
+	General  map[Canal]*Estadistica
+	grupos   map[int]*Grupo
+}
+
+// Grupo son las estadísticas de una vuelta o sector.
+type Grupo struct {
+	Clave         int                    `json:"grupo"`
+	Muestras      int                    `json:"muestras"`
+	TiempoInicial float64                `json:"tiempo_inicial"`
+	TiempoFinal   float64                `json:"tiempo_final"`
+	Canales       map[Canal]*Estadistica `json:"canales"`
+}
+
+// ParseAgrupacion valida el nombre de una agrupación.
+func ParseAgrupacion(nombre string) (Agrupacion, error) {
+	switch a := Agrupacion(nombre); a {
+	case SinAgrupar, AgruparPorVuelta, AgruparPorSector:
+		return a, nil
+	default:
+		return "", fmt.Errorf("agrupación desconocida: %q (vuelta o sector)", nombre)
+	}
+}
+
+// NuevoAnalisis valida que el archivo traiga los canales que la agrupación
+// necesita. Se llama una vez por trabajador.
+func NuevoAnalisis(canales []Canal, opciones OpcionesAnalisis) (*Analisis, error) {
+	tiene := func(buscado Canal) bool {
+		for _, c := range canales {
+			if c == buscado {
+				return true
+			}
+		}
+		return false
+	}
+	switch opciones.Agrupar {
+	case AgruparPorVuelta:
+		if !tiene(CanalVuelta) {
+			return nil, errors.New("para agrupar por vuelta el archivo necesita el canal vuelta")
+		}
+	case AgruparPorSector:
+		if !tiene(CanalDistancia) {
+			return nil, errors.New("para agrupar por sector el archivo necesita el canal distancia")
+		}
+		if len(opciones.Limites) == 0 {
+			return nil, errors.New("para agrupar por sector hay que indicar los límites de los sectores")
+		}
+		if !sort.Float64sAreSorted(opciones.Limites) || opciones.Limites[0] <= 0 {
+			return nil, errors.New("los límites de los sectores deben ser positivos y crecientes")
+		}
+		if opciones.Longitud > 0 && opciones.Limites[len(opciones.Limites)-1] >= opciones.Longitud {
+			return nil, errors.New("los límites de los sectores deben ser menores que la longitud de la vuelta")
+		}
+	}
+
+	a := &Analisis{opciones: opciones, grupos: make(map[int]*Grupo)}
+	for _, c := range canales {
+		if c != CanalTiempo {
+			a.canales = append(a.canales, c)
+		}
+	}
+	a.General = a.nuevasEstadisticas()
+	return a, nil
+}
+
+func (a *Analisis) nuevasEstadisticas() map[Canal]*Estadistica {
+	estadisticas := make(map[Canal]*Estadistica, len(a.canales))
+	for _, c := range a.canales {
+		estadisticas[c] = NuevaEstadistica(c, a.opciones.Bins)
+	}
+	return estadisticas
+}
+
+func (a *Analisis) Acumular(lote Lote) {
+	for _, m := range lote.Muestras {
+		for _, c := range a.canales {
+			a.General[c].Agregar(m.Valor(c))
+		}
+		if a.opciones.Agrupar == SinAgrupar {
+			continue
+		}
+
+		clave := a.clave(m)
+		g, ok := a.grupos[clave]
+		if !ok {
+			g = &Grupo{Clave: clave, TiempoInicial: m.Tiempo, TiempoFinal: m.Tiempo, Canales: a.nuevasEstadisticas()}
+			a.grupos[clave] = g
+		}
+		g.Muestras++
+		g.TiempoInicial = math.Min(g.TiempoInicial, m.Tiempo)
+		g.TiempoFinal = math.Max(g.TiempoFinal, m.Tiempo)
+		for _, c := range a.canales {
+			g.Canales[c].Agregar(m.Valor(c))
+		}
+	}
+}
+
+func (a *Analisis) Combinar(otro *Analisis) {
+	for c, e := range a.General {
+		e.Combinar(otro.General[c])
+	}
+	for clave, g := range otro.grupos {
+		propio, ok := a.grupos[clave]
+		if !ok {
+			a.grupos[clave] = g
+			continue
+		}
+		propio.Muestras += g.Muestras
+		propio.TiempoInicial = math.Min(propio.TiempoInicial, g.TiempoInicial)
+		propio.TiempoFinal = math.Max(propio.TiempoFinal, g.TiempoFinal)
+		for c, e := range propio.Canales {
+			e.Combinar(g.Canales[c])
+		}
+	}
+}
+
+// Grupos devuelve la tabla por vuelta o sector, ordenada.
+func (a *Analisis) Grupos() []*Grupo {
+	grupos := make([]*Grupo, 0, len(a.grupos))
+	for _, g := range a.grupos {
+		grupos = append(grupos, g)
+	}
+	sort.Slice(grupos, func(i, j int) bool { return grupos[i].Clave < grupos[j].Clave })
+	return grupos
+}
+
+// clave es la vuelta de la muestra o su sector, numerado desde 1.
+func (a *Analisis) clave(m Muestra) int {
+	if a.opciones.Agrupar == AgruparPorVuelta {
+		return m.Vuelta
+	}
+	d := m.Distancia
+	if a.opciones.Longitud > 0 {
+		d = math.Mod(d, a.opciones.Longitud)
+	}
+	return 1 + sort.Search(len(a.opciones.Limites), func(i int) bool { return a.opciones.Limites[i] > d })
+}
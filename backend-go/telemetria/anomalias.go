@@ -0,0 +1,394 @@
+package telemetria
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// TipoAnomalia identifica el detector que marcó un tramo.
+type TipoAnomalia string
+
+const (
+	// Una muestra que se aleja de sus dos vecinas, que coinciden entre sí
+	AnomaliaPico TipoAnomalia = "pico"
+	// El valor cae de golpe a cero con el auto en movimiento
+	AnomaliaCaida TipoAnomalia = "caida"
+	// Faltan muestras: el intervalo de tiempo es mucho mayor que el habitual
+	AnomaliaHueco TipoAnomalia = "hueco"
+	// Puntaje z respecto de la media y el desvío de la ventana
+	AnomaliaZ TipoAnomalia = "zscore"
+	// Puntaje z robusto respecto de la mediana y la MAD de la ventana
+	AnomaliaMAD TipoAnomalia = "mad"
+	// Valor fuera de los límites físicos del canal
+	AnomaliaRango TipoAnomalia = "fuera_de_rango"
+	// Sensor trabado: el mismo valor exacto durante toda la ventana
+	AnomaliaEstancado TipoAnomalia = "estancado"
+)
+
+// Severidad resume el puntaje de una anomalía.
+type Severidad string
+
+const (
+	SeveridadBaja  Severidad = "baja"
+	SeveridadMedia Severidad = "media"
+	SeveridadAlta  Severidad = "alta"
+)
+
+// parametrosCanal son los umbrales propios de cada canal.
+type parametrosCanal struct {
+	pico      float64 // desvío mínimo de un pico
+	caida     float64 // mediana mínima para que un cero sea una caída; 0 = no aplica
+	piso      float64 // desvío y MAD mínimos: resolución del sensor
+	estancado bool    // si un valor fijo es sospechoso (una marcha fija no lo es)
+}
+
+var parametrosAnomalias = map[Canal]parametrosCanal{
+	CanalVelocidad:  {pico: 25, caida: 30, piso: 1, estancado: true},
+	CanalRPM:        {pico: 2500, caida: 2000, piso: 50, estancado: true},
+	CanalAcelerador: {pico: 40, piso: 1, estancado: true},
+	CanalFreno:      {pico: 40, piso: 1, estancado: true},
+	CanalMarcha:     {pico: 3, piso: 0.5},
+}
+
+// RangosFisicos son los límites por defecto de AnomaliaRango; más estrictos
+// que los del Esquema, que directamente descarta la fila.
+var RangosFisicos = map[Canal][2]float64{
+	CanalVelocidad:  {0, 370},
+	CanalRPM:        {0, 15000},
+	CanalMarcha:     {0, 8},
+	CanalAcelerador: {0, 100},
+	CanalFreno:      {0, 100},
+}
+
+// OpcionesAnomalias configura los detectores.
+type OpcionesAnomalias struct {
+	Ventana   int                  // muestras de la ventana centrada (impar)
+	UmbralZ   float64              // puntaje z a partir del cual se marca
+	UmbralMAD float64              // puntaje z robusto a partir del cual se marca
+	Rangos    map[Canal][2]float64 // reemplaza a RangosFisicos por canal
+}
+
+// OpcionesAnomaliasPorDefecto usa una ventana de 21 muestras (unos 2 s a 10 Hz).
+func OpcionesAnomaliasPorDefecto() OpcionesAnomalias {
+	return OpcionesAnomalias{Ventana: 21, UmbralZ: 4, UmbralMAD: 5}
+}
+
+// Solape es el contexto que los detectores necesitan en cada lote.
+func (o OpcionesAnomalias) Solape() int {
+	return o.Ventana + 1
+}
+
+// Anomalia es un tramo de muestras marcado por un detector. Desde y Hasta
+// son posiciones de muestra (inclusive); Valor es el más extremo del tramo.
+type Anomalia struct {
+	Canal         Canal        `json:"canal"`
+	Tipo          TipoAnomalia `json:"tipo"`
+	Desde         int          `json:"desde"`
+	Hasta         int          `json:"hasta"`
+	TiempoInicial float64      `json:"tiempo_inicial"`
+	TiempoFinal   float64      `json:"tiempo_final"`
+	Valor         float64      `json:"valor"`
+	Puntaje       float64      `json:"puntaje"`
+	Severidad     Severidad    `json:"severidad"`
+}
+
+// DetectorAnomalias es el acumulador del pipeline. Cada muestra se evalúa
+// con una ventana centrada, así que un lote evalúa las muestras desde media
+// ventana antes de su inicio (con el Contexto) hasta media ventana antes de
+// su final, salvo el último. El resultado no depende de cómo se cortaron
+// los lotes.
+type DetectorAnomalias struct {
+	opciones  OpcionesAnomalias
+	canales   []Canal
+	conTiempo bool
+	anomalias []Anomalia
+}
+
+// NuevoDetectorAnomalias valida las opciones contra los canales del archivo.
+func NuevoDetectorAnomalias(canales []Canal, opciones OpcionesAnomalias) (*DetectorAnomalias, error) {
+	if opciones.Ventana < 5 || opciones.Ventana%2 == 0 {
+		return nil, fmt.Errorf("la ventana debe ser un número impar de al menos 5 muestras")
+	}
+	if opciones.UmbralZ <= 0 || opciones.UmbralMAD <= 0 {
+		return nil, fmt.Errorf("los umbrales deben ser positivos")
+	}
+	d := &DetectorAnomalias{opciones: opciones}
+	for _, c := range canales {
+		if _, ok := parametrosAnomalias[c]; ok {
+			d.canales = append(d.canales, c)
+		}
+		d.conTiempo = d.conTiempo || c == CanalTiempo
+	}
+	if len(d.canales) == 0 {
+		return nil, fmt.Errorf("el archivo no tiene canales que se puedan analizar (velocidad, rpm, marcha, acelerador o freno)")
+	}
+	return d, nil
+}
+
+func (d *DetectorAnomalias) Acumular(lote Lote) {
+	datos := append(append(make([]Muestra, 0, len(lote.Contexto)+len(lote.Muestras)), lote.Contexto...), lote.Muestras...)
+	base := lote.Inicio - len(lote.Contexto) // posición en el archivo de datos[0]
+	h := d.opciones.Ventana / 2
+
+	// Posiciones (relativas a datos) que evalúa este lote
+	desde := max(0, len(lote.Contexto)-h)
+	hasta := len(datos) - h
+	if lote.Ultimo {
+		hasta = len(datos)
+	}
+	if hasta <= desde {
+		return
+	}
+
+	if d.conTiempo {
+		d.detectarHuecos(datos, base, desde, hasta)
+	}
+	valores := make([]float64, len(datos))
+	for _, c := range d.canales {
+		for i, m := range datos {
+			valores[i] = m.Valor(c)
+		}
+		d.detectarCanal(c, datos, valores, base, desde, hasta)
+	}
+}
+
+func (d *DetectorAnomalias) Combinar(otro *DetectorAnomalias) {
+	d.anomalias = append(d.anomalias, otro.anomalias...)
+}
+
+// detectarCanal evalúa cada posición de [desde, hasta) con la ventana
+// centrada, recortada a los datos disponibles.
+func (d *DetectorAnomalias) detectarCanal(c Canal, datos []Muestra, valores []float64, base, desde, hasta int) {
+	p := parametrosAnomalias[c]
+	rango, ok := d.opciones.Rangos[c]
+	if !ok {
+		rango = RangosFisicos[c]
+	}
+	definicion, _ := Definicion(c)
+	h := d.opciones.Ventana / 2
+	ventana := nuevaVentana(d.opciones.Ventana)
+
+	for i := desde; i < hasta; i++ {
+		ventana.mover(valores, max(0, i-h), min(len(valores), i+h+1))
+		x := valores[i]
+
+		if x < rango[0] || x > rango[1] {
+			exceso := math.Max(rango[0]-x, x-rango[1])
+			d.marcar(c, AnomaliaRango, datos, base, i, i, x, 1+10*exceso/(rango[1]-rango[0]))
+		}
+
+		// Picos, z y MAD solo marcan extremos locales: una transición rápida
+		// pero monótona (una frenada, un cambio de marcha) no es una anomalía
+		extremo := false
+		if i > 0 && i < len(valores)-1 {
+			anterior, siguiente := valores[i-1], valores[i+1]
+			extremo = x > math.Max(anterior, siguiente) || x < math.Min(anterior, siguiente)
+			desvio := math.Abs(x - (anterior+siguiente)/2)
+			if extremo && desvio > p.pico && math.Abs(siguiente-anterior) < p.pico/2 {
+				d.marcar(c, AnomaliaPico, datos, base, i, i, x, desvio/p.pico)
+			}
+		}
+
+		if p.caida > 0 && x == 0 && ventana.mediana() > p.caida {
+			d.marcar(c, AnomaliaCaida, datos, base, i, i, x, 3)
+		}
+
+		// Si varias muestras de la ventana se apartan igual que x se trata de
+		// un tramo real (una frenada corta), no de un valor aislado
+		if extremo && ventana.cantidad() > 2 && ventana.apartadas(x) <= max(1, d.opciones.Ventana/7) {
+			media, desvio := ventana.mediaSin(x)
+			if z := math.Abs(x-media) / math.Max(desvio, p.piso); z > d.opciones.UmbralZ {
+				d.marcar(c, AnomaliaZ, datos, base, i, i, x, z/d.opciones.UmbralZ)
+			}
+			mediana := ventana.mediana()
+			if z := 0.6745 * math.Abs(x-mediana) / math.Max(ventana.mad(mediana), p.piso); z > d.opciones.UmbralMAD {
+				d.marcar(c, AnomaliaMAD, datos, base, i, i, x, z/d.opciones.UmbralMAD)
+			}
+		}
+
+		// Sensor trabado: la ventana completa con un valor idéntico que no
+		// sea un tope del canal (acelerador a fondo o freno suelto son normales)
+		if p.estancado && ventana.cantidad() == d.opciones.Ventana && ventana.ordenados[0] == ventana.ordenados[len(ventana.ordenados)-1] &&
+			x > definicion.Min && x < definicion.Max {
+			d.marcar(c, AnomaliaEstancado, datos, base, i-h, i+h, x, 1)
+		}
+	}
+}
+
+// detectarHuecos marca los intervalos mayores a tres veces la mediana de
+// los intervalos de la ventana.
+func (d *DetectorAnomalias) detectarHuecos(datos []Muestra, base, desde, hasta int) {
+	// intervalos[i] es el tiempo entre las muestras i-1 e i
+	intervalos := make([]float64, len(datos))
+	for i := 1; i < len(datos); i++ {
+		intervalos[i] = datos[i].Tiempo - datos[i-1].Tiempo
+	}
+	h := d.opciones.Ventana / 2
+	ventana := nuevaVentana(d.opciones.Ventana)
+	for i := max(desde, 1); i < hasta; i++ {
+		ventana.mover(intervalos, max(1, i-h), min(len(intervalos), i+h+1))
+		limite := 3 * ventana.mediana()
+		if dt := intervalos[i]; limite > 0 && dt > limite {
+			d.agregar(Anomalia{Canal: CanalTiempo, Tipo: AnomaliaHueco, Desde: base + i - 1, Hasta: base + i, Valor: dt, Puntaje: dt / limite},
+				datos[i-1].Tiempo, datos[i].Tiempo)
+		}
+	}
+}
+
+// marcar registra una anomalía entre las posiciones desde y hasta de datos.
+func (d *DetectorAnomalias) marcar(c Canal, tipo TipoAnomalia, datos []Muestra, base, desde, hasta int, valor, puntaje float64) {
+	d.agregar(Anomalia{Canal: c, Tipo: tipo, Desde: base + desde, Hasta: base + hasta, Valor: valor, Puntaje: puntaje}, datos[desde].Tiempo, datos[hasta].Tiempo)
+}
+
+func (d *DetectorAnomalias) agregar(a Anomalia, tiempoInicial, tiempoFinal float64) {
+	a.TiempoInicial, a.TiempoFinal = tiempoInicial, tiempoFinal
+	d.anomalias = append(d.anomalias, a)
+}
+
+// Anomalias une los tramos del mismo canal y tipo que se tocan o se
+// superponen (por ejemplo, las ventanas de un sensor trabado) y los ordena
+// por tiempo.
+func (d *DetectorAnomalias) Anomalias() []Anomalia {
+	sort.Slice(d.anomalias, func(i, j int) bool {
+		a, b := d.anomalias[i], d.anomalias[j]
+		if a.Canal != b.Canal {
+			return a.Canal < b.Canal
+		}
+		if a.Tipo != b.Tipo {
+			return a.Tipo < b.Tipo
+		}
+		return a.Desde < b.Desde
+	})
+
+	unidas := []Anomalia{}
+	for _, a := range d.anomalias {
+		if n := len(unidas); n > 0 {
+			u := &unidas[n-1]
+			if u.Canal == a.Canal && u.Tipo == a.Tipo && a.Desde <= u.Hasta+1 {
+				if a.Hasta > u.Hasta {
+					u.Hasta, u.TiempoFinal = a.Hasta, a.TiempoFinal
+				}
+				if a.Puntaje > u.Puntaje {
+					u.Puntaje, u.Valor = a.Puntaje, a.Valor
+				}
+				continue
+			}
+		}
+		unidas = append(unidas, a)
+	}
+
+	for i := range unidas {
+		a := &unidas[i]
+		if a.Tipo == AnomaliaEstancado {
+			// Cuanto más dura, más grave: una ventana completa vale 1
+			a.Puntaje = float64(a.Hasta-a.Desde+1) / float64(d.opciones.Ventana)
+		}
+		a.Severidad = severidad(a.Puntaje)
+	}
+	sort.SliceStable(unidas, func(i, j int) bool { return unidas[i].TiempoInicial < unidas[j].TiempoInicial })
+	return unidas
+}
+
+func severidad(puntaje float64) Severidad {
+	switch {
+	case puntaje >= 3:
+		return SeveridadAlta
+	case puntaje >= 1.5:
+		return SeveridadMedia
+	default:
+		return SeveridadBaja
+	}
+}
+
+// -------------------- Ventana deslizante --------------------
+
+// ventana mantiene ordenados los valores de [inicio, fin) para obtener la
+// mediana y la MAD sin reordenar en cada muestra. No lleva sumas corridas:
+// así el resultado es idéntico sin importar por dónde empezó la ventana.
+type ventana struct {
+	inicio, fin int
+	ordenados   []float64
+}
+
+func nuevaVentana(capacidad int) *ventana {
+	return &ventana{ordenados: make([]float64, 0, capacidad)}
+}
+
+// mover avanza la ventana hasta [inicio, fin); ambos bordes solo crecen.
+func (v *ventana) mover(valores []float64, inicio, fin int) {
+	for ; v.fin < fin; v.fin++ {
+		x := valores[v.fin]
+		i := sort.SearchFloat64s(v.ordenados, x)
+		v.ordenados = append(v.ordenados, 0)
+		copy(v.ordenados[i+1:], v.ordenados[i:])
+		v.ordenados[i] = x
+	}
+	for ; v.inicio < inicio; v.inicio++ {
+		x := valores[v.inicio]
+		i := sort.SearchFloat64s(v.ordenados, x)
+		v.ordenados = append(v.ordenados[:i], v.ordenados[i+1:]...)
+	}
+}
+
+// mediaSin devuelve la media y el desvío de la ventana sin una aparición de x.
+func (v *ventana) mediaSin(x float64) (float64, float64) {
+	n := float64(len(v.ordenados) - 1)
+	suma := -x
+	for _, y := range v.ordenados {
+		suma += y
+	}
+	media := suma / n
+	cuadrados := -(x - media) * (x - media)
+	for _, y := range v.ordenados {
+		cuadrados += (y - media) * (y - media)
+	}
+	return media, math.Sqrt(math.Max(0, cuadrados/n))
+}
+
+// apartadas cuenta las muestras del mismo lado de la mediana que x que se
+// alejan de ella al menos la mitad que x, incluida x.
+func (v *ventana) apartadas(x float64) int {
+	mediana := v.mediana()
+	limite := mediana + (x-mediana)/2
+	if x > mediana {
+		return len(v.ordenados) - sort.SearchFloat64s(v.ordenados, limite)
+	}
+	return sort.Search(len(v.ordenados), func(i int) bool { return v.ordenados[i] > limite })
+}
+
+func (v *ventana) cantidad() int {
+	return len(v.ordenados)
+}
+
+func (v *ventana) mediana() float64 {
+	n := len(v.ordenados)
+	if n%2 == 1 {
+		return v.ordenados[n/2]
+	}
+	return (v.ordenados[n/2-1] + v.ordenados[n/2]) / 2
+}
+
+// mad es la mediana de los desvíos absolutos respecto de mediana. Como los
+// valores están ordenados, los desvíos se recorren de menor a mayor desde el
+// centro hacia afuera, como en una mezcla.
+func (v *ventana) mad(mediana float64) float64 {
+	n := len(v.ordenados)
+	izq := sort.SearchFloat64s(v.ordenados, mediana) - 1
+	der := izq + 1
+	var anterior, actual float64
+	for k := 0; k <= n/2; k++ {
+		anterior = actual
+		if izq < 0 || (der < n && v.ordenados[der]-mediana <= mediana-v.ordenados[izq]) {
+			actual = v.ordenados[der] - mediana
+			der++
+		} else {
+			actual = mediana - v.ordenados[izq]
+			izq--
+		}
+	}
+	if n%2 == 1 {
+		return actual
+	}
+	return (anterior + actual) / 2
+}
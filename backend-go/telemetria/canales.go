@@ -0,0 +1,155 @@
+package telemetria
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Canal identifica una columna de telemetría.
+type Canal string
+
+const (
+	CanalTiempo     Canal = "tiempo"
+	CanalDistancia  Canal = "distancia"
+	CanalVelocidad  Canal = "velocidad"
+	CanalRPM        Canal = "rpm"
+	CanalMarcha     Canal = "marcha"
+	CanalAcelerador Canal = "acelerador"
+	CanalFreno      Canal = "freno"
+	CanalDRS        Canal = "drs"
+	CanalVuelta     Canal = "vuelta"
+)
+
+// DefinicionCanal describe cómo reconocer y validar un canal.
+type DefinicionCanal struct {
+	Canal       Canal    `json:"canal"`
+	Unidad      string   `json:"unidad,omitempty"`
+	Alias       []string `json:"alias"`
+	Obligatorio bool     `json:"obligatorio"`
+	Min         float64  `json:"min"`
+	Max         float64  `json:"max"`
+}
+
+// Esquema lista los canales que entiende el parser, en el orden de Muestra.
+// Los encabezados se comparan sin mayúsculas, espacios ni unidades, así
+// "Speed [km/h]" y "speed" se reconocen como velocidad.
+var Esquema = []DefinicionCanal{
+	{Canal: CanalTiempo, Unidad: "s", Alias: []string{"tiempo", "time", "timestamp", "t", "sessiontime"}, Obligatorio: true, Min: 0, Max: 7 * 24 * 3600},
+	{Canal: CanalDistancia, Unidad: "m", Alias: []string{"distancia", "distance", "dist"}, Min: 0, Max: 1e7},
+	{Canal: CanalVelocidad, Unidad: "km/h", Alias: []string{"velocidad", "speed", "vel"}, Min: 0, Max: 420},
+	{Canal: CanalRPM, Unidad: "rpm", Alias: []string{"rpm", "revoluciones"}, Min: 0, Max: 20000},
+	{Canal: CanalMarcha, Alias: []string{"marcha", "gear", "ngear"}, Min: 0, Max: 8},
+	{Canal: CanalAcelerador, Unidad: "%", Alias: []string{"acelerador", "throttle"}, Min: 0, Max: 100},
+	{Canal: CanalFreno, Unidad: "%", Alias: []string{"freno", "brake"}, Min: 0, Max: 100},
+	{Canal: CanalDRS, Alias: []string{"drs"}, Min: 0, Max: 14},
+	{Canal: CanalVuelta, Alias: []string{"vuelta", "lap", "lapnumber"}, Min: 0, Max: 1000},
+}
+
+// Definicion devuelve la definición de un canal.
+func Definicion(c Canal) (DefinicionCanal, bool) {
+	for _, d := range Esquema {
+		if d.Canal == c {
+			return d, true
+		}
+	}
+	return DefinicionCanal{}, false
+}
+
+// CanalDeEncabezado reconoce el canal de un encabezado de columna o clave JSON.
+func CanalDeEncabezado(encabezado string) (Canal, bool) {
+	nombre := normalizarEncabezado(encabezado)
+	for _, d := range Esquema {
+		for _, alias := range d.Alias {
+			if nombre == alias {
+				return d.Canal, true
+			}
+		}
+	}
+	return "", false
+}
+
+// normalizarEncabezado pasa a minúsculas, descarta las unidades entre
+// corchetes o paréntesis y todo lo que no sea letra o número.
+func normalizarEncabezado(encabezado string) string {
+	var b strings.Builder
+	profundidad := 0
+	for _, r := range strings.ToLower(encabezado) {
+		switch {
+		case r == '(' || r == '[':
+			profundidad++
+		case r == ')' || r == ']':
+			if profundidad > 0 {
+				profundidad--
+			}
+		case profundidad == 0 && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Muestra es una fila de telemetría ya validada. Los canales que el archivo
+// no trae quedan en cero; cuáles están presentes se informa aparte.
+type Muestra struct {
+	Tiempo     float64 `json:"tiempo"`
+	Distancia  float64 `json:"distancia"`
+	Velocidad  float64 `json:"velocidad"`
+	RPM        float64 `json:"rpm"`
+	Marcha     int     `json:"marcha"`
+	Acelerador float64 `json:"acelerador"`
+	Freno      float64 `json:"freno"`
+	DRS        bool    `json:"drs"`
+	Vuelta     int     `json:"vuelta"`
+}
+
+// Valor devuelve un canal de la muestra como número; DRS abierto vale 1.
+func (m Muestra) Valor(c Canal) float64 {
+	switch c {
+	case CanalTiempo:
+		return m.Tiempo
+	case CanalDistancia:
+		return m.Distancia
+	case CanalVelocidad:
+		return m.Velocidad
+	case CanalRPM:
+		return m.RPM
+	case CanalMarcha:
+		return float64(m.Marcha)
+	case CanalAcelerador:
+		return m.Acelerador
+	case CanalFreno:
+		return m.Freno
+	case CanalDRS:
+		if m.DRS {
+			return 1
+		}
+		return 0
+	case CanalVuelta:
+		return float64(m.Vuelta)
+	}
+	return 0
+}
+
+// asignar guarda un valor ya validado en el campo del canal.
+func (m *Muestra) asignar(c Canal, v float64) {
+	switch c {
+	case CanalTiempo:
+		m.Tiempo = v
+	case CanalDistancia:
+		m.Distancia = v
+	case CanalVelocidad:
+		m.Velocidad = v
+	case CanalRPM:
+		m.RPM = v
+	case CanalMarcha:
+		m.Marcha = int(v)
+	case CanalAcelerador:
+		m.Acelerador = v
+	case CanalFreno:
+		m.Freno = v
+	case CanalDRS:
+		m.DRS = v != 0
+	case CanalVuelta:
+		m.Vuelta = int(v)
+	}
+}
@@ -0,0 +1,254 @@
+package telemetria
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// Paso por defecto de la grilla de distancia, en metros
+const pasoPorDefecto = 5.0
+
+// Caída mínima de velocidad (km/h) desde la recta para considerar una curva
+const caidaMinimaCurva = 15.0
+
+// TrazaVuelta son las muestras de una vuelta con su distancia y su tiempo
+// medidos desde la línea de meta.
+type TrazaVuelta struct {
+	Numero    int
+	Tiempo    float64
+	Longitud  float64
+	Canales   []Canal
+	distancia []float64
+	tiempo    []float64
+	muestras  []Muestra
+}
+
+// Traza devuelve una vuelta completa para compararla; con numero 0, la mejor.
+func (a *AnalisisVueltas) Traza(numero int) (*TrazaVuelta, error) {
+	var vuelta *Vuelta
+	for i := range a.Vueltas {
+		v := &a.Vueltas[i]
+		if (numero == 0 && a.MejorVuelta != nil && v.Numero == a.MejorVuelta.Numero) || (numero != 0 && v.Numero == numero) {
+			vuelta = v
+			break
+		}
+	}
+	if vuelta == nil {
+		return nil, fmt.Errorf("no existe la vuelta %d", numero)
+	}
+	if !vuelta.Completa {
+		return nil, fmt.Errorf("la vuelta %d está incompleta", vuelta.Numero)
+	}
+
+	t := &TrazaVuelta{Numero: vuelta.Numero, Tiempo: vuelta.Tiempo, Longitud: vuelta.fin - vuelta.inicio, Canales: a.canales}
+	inicio := a.traza.tiempoEn(vuelta.inicio)
+	for i := vuelta.Desde; i <= vuelta.Hasta; i++ {
+		t.distancia = append(t.distancia, a.traza.distancia[i]-vuelta.inicio)
+		t.tiempo = append(t.tiempo, a.muestras[i].Tiempo-inicio)
+		t.muestras = append(t.muestras, a.muestras[i])
+	}
+	return t, nil
+}
+
+// en interpola la traza a una distancia desde la meta. Devuelve el tiempo
+// transcurrido y la muestra interpolada; marcha y DRS toman el valor más
+// cercano porque no tiene sentido una marcha intermedia.
+func (t *TrazaVuelta) en(d float64) (float64, Muestra) {
+	n := len(t.distancia)
+	i := sort.SearchFloat64s(t.distancia, d)
+	if i == 0 {
+		return extrapolarTiempo(t, 0, d), t.muestras[0]
+	}
+	if i >= n {
+		return extrapolarTiempo(t, n-1, d), t.muestras[n-1]
+	}
+
+	d0, d1 := t.distancia[i-1], t.distancia[i]
+	f := 0.0
+	if d1 > d0 {
+		f = (d - d0) / (d1 - d0)
+	}
+	a, b := t.muestras[i-1], t.muestras[i]
+	m := Muestra{
+		Tiempo:     a.Tiempo + f*(b.Tiempo-a.Tiempo),
+		Distancia:  d,
+		Velocidad:  a.Velocidad + f*(b.Velocidad-a.Velocidad),
+		RPM:        a.RPM + f*(b.RPM-a.RPM),
+		Acelerador: a.Acelerador + f*(b.Acelerador-a.Acelerador),
+		Freno:      a.Freno + f*(b.Freno-a.Freno),
+		Marcha:     a.Marcha,
+		DRS:        a.DRS,
+		Vuelta:     a.Vuelta,
+	}
+	if f >= 0.5 {
+		m.Marcha, m.DRS = b.Marcha, b.DRS
+	}
+	return t.tiempo[i-1] + f*(t.tiempo[i]-t.tiempo[i-1]), m
+}
+
+// extrapolarTiempo estima el tiempo antes de la primera muestra o después de
+// la última con la velocidad de esa muestra (como mucho, medio paso de grilla).
+func extrapolarTiempo(t *TrazaVuelta, i int, d float64) float64 {
+	v := t.muestras[i].Velocidad / 3.6
+	if v <= 0 {
+		return t.tiempo[i]
+	}
+	return t.tiempo[i] + (d-t.distancia[i])/v
+}
+
+// Curva es una zona de frenado y aceleración detectada en la vuelta de
+// referencia, con el tiempo que la vuelta B ganó (negativo) o perdió en ella.
+type Curva struct {
+	Numero           int     `json:"curva"`
+	DistanciaInicio  float64 `json:"distancia_inicio"`
+	DistanciaVertice float64 `json:"distancia_vertice"`
+	DistanciaFin     float64 `json:"distancia_fin"`
+	VelocidadMinA    float64 `json:"velocidad_minima_a"`
+	VelocidadMinB    float64 `json:"velocidad_minima_b"`
+	Diferencia       float64 `json:"diferencia"`
+	Gana             string  `json:"gana"`
+}
+
+// Comparacion es el resultado de superponer dos vueltas.
+type Comparacion struct {
+	Paso       float64                        `json:"paso"`
+	Distancia  []float64                      `json:"distancia"`
+	Canales    map[Canal]map[string][]float64 `json:"canales"`
+	Delta      []float64                      `json:"delta"`
+	DeltaFinal float64                        `json:"delta_final"`
+	Curvas     []Curva                        `json:"curvas"`
+}
+
+// Comparar alinea la vuelta b con la a por distancia y calcula el delta de
+// tiempo acumulado (positivo: b va más lenta). La distancia de b se escala
+// a la longitud de a, así dos trazadas apenas distintas quedan alineadas.
+// La grilla se reparte en bloques entre trabajadores.
+func Comparar(a, b *TrazaVuelta, paso float64, trabajadores int) *Comparacion {
+	if paso <= 0 {
+		paso = pasoPorDefecto
+	}
+	if trabajadores < 1 {
+		trabajadores = runtime.NumCPU()
+	}
+
+	puntos := int(a.Longitud/paso) + 1
+	canales := canalesComunes(a.Canales, b.Canales)
+	c := &Comparacion{
+		Paso:      paso,
+		Distancia: make([]float64, puntos),
+		Canales:   make(map[Canal]map[string][]float64, len(canales)),
+		Delta:     make([]float64, puntos),
+	}
+	for _, canal := range canales {
+		c.Canales[canal] = map[string][]float64{"a": make([]float64, puntos), "b": make([]float64, puntos)}
+	}
+
+	escala := b.Longitud / a.Longitud
+	bloque := (puntos + trabajadores - 1) / trabajadores
+	var wg sync.WaitGroup
+	for desde := 0; desde < puntos; desde += bloque {
+		hasta := min(desde+bloque, puntos)
+		wg.Add(1)
+		go func(desde, hasta int) {
+			defer wg.Done()
+			// Cada trabajador escribe solo sus posiciones: no hace falta combinar
+			for i := desde; i < hasta; i++ {
+				d := math.Min(float64(i)*paso, a.Longitud)
+				tiempoA, ma := a.en(d)
+				tiempoB, mb := b.en(d * escala)
+				c.Distancia[i] = d
+				c.Delta[i] = tiempoB - tiempoA
+				for _, canal := range canales {
+					c.Canales[canal]["a"][i] = ma.Valor(canal)
+					c.Canales[canal]["b"][i] = mb.Valor(canal)
+				}
+			}
+		}(desde, hasta)
+	}
+	wg.Wait()
+
+	c.DeltaFinal = b.Tiempo - a.Tiempo
+	if velocidad, ok := c.Canales[CanalVelocidad]; ok {
+		c.Curvas = detectarCurvas(velocidad["a"], velocidad["b"], c.Distancia, c.Delta)
+	}
+	return c
+}
+
+// detectarCurvas busca en la velocidad de referencia cada mínimo que cae al
+// menos caidaMinimaCurva desde el máximo anterior y el siguiente. La curva va
+// del máximo previo (inicio del frenado) al siguiente (fin de la aceleración).
+func detectarCurvas(velocidadA, velocidadB, distancia, delta []float64) []Curva {
+	curvas := []Curva{}
+	n := len(velocidadA)
+	i := 0
+	for i < n-1 {
+		// Máximo previo
+		for i < n-1 && velocidadA[i+1] >= velocidadA[i] {
+			i++
+		}
+		inicio := i
+		// Vértice
+		for i < n-1 && velocidadA[i+1] <= velocidadA[i] {
+			i++
+		}
+		vertice := i
+		// Máximo siguiente: la curva termina al dejar de acelerar
+		for i < n-1 && velocidadA[i+1] > velocidadA[i] {
+			i++
+		}
+		fin := i
+
+		caida := velocidadA[inicio] - velocidadA[vertice]
+		subida := velocidadA[fin] - velocidadA[vertice]
+		if caida < caidaMinimaCurva || subida < caidaMinimaCurva {
+			// Ondulación de la recta: se sigue buscando desde el vértice
+			i = max(vertice, inicio+1)
+			continue
+		}
+
+		curva := Curva{
+			Numero:           len(curvas) + 1,
+			DistanciaInicio:  distancia[inicio],
+			DistanciaVertice: distancia[vertice],
+			DistanciaFin:     distancia[fin],
+			VelocidadMinA:    velocidadA[vertice],
+			VelocidadMinB:    minimo(velocidadB[inicio : fin+1]),
+			Diferencia:       delta[fin] - delta[inicio],
+			Gana:             "a",
+		}
+		if curva.Diferencia < 0 {
+			curva.Gana = "b"
+		}
+		curvas = append(curvas, curva)
+		i = fin
+	}
+	return curvas
+}
+
+func minimo(valores []float64) float64 {
+	m := math.Inf(1)
+	for _, v := range valores {
+		m = math.Min(m, v)
+	}
+	return m
+}
+
+// canalesComunes son los canales de datos presentes en las dos vueltas.
+func canalesComunes(a, b []Canal) []Canal {
+	var comunes []Canal
+	for _, c := range a {
+		if c == CanalTiempo || c == CanalDistancia || c == CanalVuelta {
+			continue
+		}
+		for _, otro := range b {
+			if c == otro {
+				comunes = append(comunes, c)
+				break
+			}
+		}
+	}
+	return comunes
+}
@@ -0,0 +1,68 @@
+package telemetria
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+)
+
+// Escritor vuelca muestras en CSV o JSON Lines con los nombres de canal del
+// Esquema, de modo que NuevoLector pueda volver a leerlas.
+type Escritor struct {
+	formato Formato
+	buffer  *bufio.Writer
+	csv     *csv.Writer
+	json    *json.Encoder
+	fila    []string
+}
+
+// NuevoEscritor prepara la salida; en CSV escribe el encabezado.
+func NuevoEscritor(w io.Writer, formato Formato) (*Escritor, error) {
+	e := &Escritor{formato: formato, buffer: bufio.NewWriter(w)}
+	if formato == FormatoJSONL {
+		e.json = json.NewEncoder(e.buffer)
+		return e, nil
+	}
+
+	e.csv = csv.NewWriter(e.buffer)
+	encabezado := make([]string, len(Esquema))
+	for i, d := range Esquema {
+		encabezado[i] = string(d.Canal)
+	}
+	e.fila = make([]string, len(Esquema))
+	return e, e.csv.Write(encabezado)
+}
+
+// Escribir agrega una muestra a la salida.
+func (e *Escritor) Escribir(m Muestra) error {
+	if e.json != nil {
+		return e.json.Encode(m)
+	}
+	for i, d := range Esquema {
+		e.fila[i] = formatearValor(d.Canal, m.Valor(d.Canal))
+	}
+	return e.csv.Write(e.fila)
+}
+
+// Cerrar vacía los buffers; no cierra el io.Writer.
+func (e *Escritor) Cerrar() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	}
+	return e.buffer.Flush()
+}
+
+// formatearValor escribe los canales enteros sin decimales.
+func formatearValor(c Canal, v float64) string {
+	switch c {
+	case CanalMarcha, CanalDRS, CanalVuelta, CanalRPM:
+		return strconv.FormatFloat(math.Round(v), 'f', 0, 64)
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,167 @@
+package telemetria
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+)
+
+// Percentiles que se informan de cada canal
+var percentilesInformados = []float64{1, 5, 25, 50, 75, 95, 99}
+
+// Estadistica resume un canal y se puede combinar con la de otro trabajador:
+// cantidad, suma, extremos, media y varianza por Welford, percentiles
+// aproximados con un t-digest y, si el canal tiene rango físico, histograma.
+type Estadistica struct {
+	cantidad   int
+	suma       float64
+	min, max   float64
+	media      float64
+	m2         float64 // suma de cuadrados de las desviaciones a la media
+	digest     *TDigest
+	histograma *Histograma
+}
+
+func NuevaEstadistica(c Canal, bins int) *Estadistica {
+	return &Estadistica{digest: NuevoTDigest(0), histograma: nuevoHistograma(c, bins)}
+}
+
+func (e *Estadistica) Agregar(v float64) {
+	if e.cantidad == 0 || v < e.min {
+		e.min = v
+	}
+	if e.cantidad == 0 || v > e.max {
+		e.max = v
+	}
+	e.cantidad++
+	e.suma += v
+	delta := v - e.media
+	e.media += delta / float64(e.cantidad)
+	e.m2 += delta * (v - e.media)
+	e.digest.Agregar(v)
+	if e.histograma != nil {
+		e.histograma.agregar(v)
+	}
+}
+
+// Combinar suma otra estadística con la fórmula de Chan para la varianza.
+// Una estadística vacía es neutra.
+func (e *Estadistica) Combinar(otra *Estadistica) {
+	if otra.cantidad == 0 {
+		return
+	}
+	if e.cantidad == 0 {
+		e.min, e.max = otra.min, otra.max
+	}
+	e.min = math.Min(e.min, otra.min)
+	e.max = math.Max(e.max, otra.max)
+
+	n := e.cantidad + otra.cantidad
+	delta := otra.media - e.media
+	e.m2 += otra.m2 + delta*delta*float64(e.cantidad)*float64(otra.cantidad)/float64(n)
+	e.media += delta * float64(otra.cantidad) / float64(n)
+	e.cantidad = n
+	e.suma += otra.suma
+	e.digest.Combinar(otra.digest)
+	if e.histograma != nil && otra.histograma != nil {
+		e.histograma.combinar(otra.histograma)
+	}
+}
+
+func (e *Estadistica) Cantidad() int  { return e.cantidad }
+func (e *Estadistica) Suma() float64  { return e.suma }
+func (e *Estadistica) Min() float64   { return e.min }
+func (e *Estadistica) Max() float64   { return e.max }
+func (e *Estadistica) Media() float64 { return e.media }
+func (e *Estadistica) Percentil(p float64) float64 {
+	return e.digest.Percentil(p / 100)
+}
+
+// Varianza es la varianza muestral (dividida por n - 1).
+func (e *Estadistica) Varianza() float64 {
+	if e.cantidad < 2 {
+		return 0
+	}
+	return e.m2 / float64(e.cantidad-1)
+}
+
+func (e *Estadistica) Desviacion() float64 {
+	return math.Sqrt(e.Varianza())
+}
+
+func (e *Estadistica) MarshalJSON() ([]byte, error) {
+	salida := struct {
+		Cantidad    int                `json:"cantidad"`
+		Suma        float64            `json:"suma"`
+		Min         float64            `json:"min"`
+		Max         float64            `json:"max"`
+		Media       float64            `json:"media"`
+		Varianza    float64            `json:"varianza"`
+		Desviacion  float64            `json:"desviacion"`
+		Percentiles map[string]float64 `json:"percentiles,omitempty"`
+		Histograma  *Histograma        `json:"histograma,omitempty"`
+	}{
+		Cantidad:   e.cantidad,
+		Suma:       e.suma,
+		Min:        e.min,
+		Max:        e.max,
+		Media:      e.media,
+		Varianza:   e.Varianza(),
+		Desviacion: e.Desviacion(),
+		Histograma: e.histograma,
+	}
+	if e.cantidad > 0 {
+		salida.Percentiles = make(map[string]float64, len(percentilesInformados))
+		for _, p := range percentilesInformados {
+			salida.Percentiles["p"+strconv.FormatFloat(p, 'f', -1, 64)] = e.Percentil(p)
+		}
+	}
+	return json.Marshal(salida)
+}
+
+// -------------------- Histograma --------------------
+
+// Histograma cuenta valores en intervalos iguales entre Desde y Hasta. El
+// rango es fijo por canal para que los de distintos trabajadores coincidan.
+type Histograma struct {
+	Desde   float64 `json:"desde"`
+	Hasta   float64 `json:"hasta"`
+	Ancho   float64 `json:"ancho"`
+	Conteos []int   `json:"conteos"`
+}
+
+// nuevoHistograma usa el rango físico del canal; los canales sin un rango
+// acotado (tiempo, distancia, vuelta) no tienen histograma.
+func nuevoHistograma(c Canal, bins int) *Histograma {
+	var desde, hasta float64
+	switch c {
+	case CanalMarcha, CanalDRS:
+		// Un intervalo por valor entero
+		d, _ := Definicion(c)
+		if c == CanalDRS {
+			d.Max = 1
+		}
+		desde, hasta, bins = d.Min-0.5, d.Max+0.5, int(d.Max-d.Min)+1
+	case CanalVelocidad, CanalRPM, CanalAcelerador, CanalFreno:
+		d, _ := Definicion(c)
+		desde, hasta = d.Min, d.Max
+	default:
+		return nil
+	}
+	if bins < 1 {
+		bins = 20
+	}
+	return &Histograma{Desde: desde, Hasta: hasta, Ancho: (hasta - desde) / float64(bins), Conteos: make([]int, bins)}
+}
+
+func (h *Histograma) agregar(v float64) {
+	i := int((v - h.Desde) / h.Ancho)
+	i = max(0, min(i, len(h.Conteos)-1))
+	h.Conteos[i]++
+}
+
+func (h *Histograma) combinar(otro *Histograma) {
+	for i, n := range otro.Conteos {
+		h.Conteos[i] += n
+	}
+}
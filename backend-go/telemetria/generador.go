@@ -0,0 +1,290 @@
+package telemetria
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// -------------------- Trazados y perfiles --------------------
+
+// CurvaTrazado es una curva del trazado: dónde está su vértice y con qué
+// radio se recorre, que fija la velocidad máxima de paso.
+type CurvaTrazado struct {
+	Nombre    string  `json:"nombre"`
+	Distancia float64 `json:"distancia"` // m desde la meta hasta el vértice
+	Radio     float64 `json:"radio"`     // m
+}
+
+// Trazado describe un circuito para generar telemetría sintética. Las
+// posiciones son aproximadas: alcanzan para que la traza tenga la forma del
+// circuito, no para compararla con datos reales.
+type Trazado struct {
+	Nombre   string         `json:"nombre"`
+	Longitud float64        `json:"longitud"`
+	Curvas   []CurvaTrazado `json:"curvas"`
+	ZonasDRS [][2]float64   `json:"zonas_drs"`
+}
+
+// PerfilAuto reúne lo que distingue a un auto en la simulación de la vuelta.
+type PerfilAuto struct {
+	Nombre          string  `json:"nombre"`
+	VelocidadMaxima float64 `json:"velocidad_maxima"` // km/h
+	Aceleracion     float64 `json:"aceleracion"`      // m/s² a baja velocidad
+	Frenada         float64 `json:"frenada"`          // m/s², sin contar el arrastre
+	Agarre          float64 `json:"agarre"`           // aceleración lateral máxima, m/s²
+	RPMMaximas      float64 `json:"rpm_maximas"`
+	RPMMinimas      float64 `json:"rpm_minimas"`
+	Marchas         int     `json:"marchas"`
+}
+
+// Trazados disponibles para el generador
+var Trazados = map[string]Trazado{
+	"monza": {
+		Nombre:   "Monza",
+		Longitud: 5793,
+		Curvas: []CurvaTrazado{
+			{"Variante del Rettifilo", 880, 14},
+			{"Curva Grande", 1500, 320},
+			{"Variante della Roggia", 2180, 22},
+			{"Lesmo 1", 2650, 85},
+			{"Lesmo 2", 2950, 60},
+			{"Variante Ascari", 3950, 70},
+			{"Curva Parabolica", 5250, 110},
+		},
+		ZonasDRS: [][2]float64{{5550, 5793}, {0, 700}, {3100, 3750}},
+	},
+	"monaco": {
+		Nombre:   "Mónaco",
+		Longitud: 3337,
+		Curvas: []CurvaTrazado{
+			{"Sainte Dévote", 260, 18},
+			{"Massenet", 880, 55},
+			{"Casino", 1040, 35},
+			{"Mirabeau", 1330, 14},
+			{"Horquilla", 1480, 8},
+			{"Portier", 1750, 16},
+			{"Túnel", 2050, 180},
+			{"Chicane", 2330, 14},
+			{"Tabac", 2560, 38},
+			{"Piscina", 2760, 32},
+			{"Rascasse", 3050, 11},
+			{"Anthony Noghès", 3220, 14},
+		},
+		ZonasDRS: [][2]float64{{3300, 3337}, {0, 180}},
+	},
+	"silverstone": {
+		Nombre:   "Silverstone",
+		Longitud: 5891,
+		Curvas: []CurvaTrazado{
+			{"Abbey", 350, 200},
+			{"Village", 780, 25},
+			{"The Loop", 900, 14},
+			{"Brooklands", 1780, 30},
+			{"Luffield", 2000, 35},
+			{"Copse", 2600, 160},
+			{"Maggotts-Becketts", 3150, 90},
+			{"Chapel", 3450, 120},
+			{"Stowe", 4250, 75},
+			{"Vale", 4650, 18},
+			{"Club", 4850, 40},
+		},
+		ZonasDRS: [][2]float64{{1050, 1700}, {3500, 4150}},
+	},
+}
+
+// Perfiles de auto disponibles para el generador
+var Perfiles = map[string]PerfilAuto{
+	"f1":  {Nombre: "Fórmula 1", VelocidadMaxima: 345, Aceleracion: 14, Frenada: 38, Agarre: 32, RPMMaximas: 12000, RPMMinimas: 4500, Marchas: 8},
+	"f2":  {Nombre: "Fórmula 2", VelocidadMaxima: 300, Aceleracion: 11, Frenada: 28, Agarre: 24, RPMMaximas: 10000, RPMMinimas: 4000, Marchas: 6},
+	"gt3": {Nombre: "GT3", VelocidadMaxima: 280, Aceleracion: 8, Frenada: 15, Agarre: 15, RPMMaximas: 8500, RPMMinimas: 3000, Marchas: 6},
+}
+
+// -------------------- Generador --------------------
+
+// Paso de la grilla de distancia sobre la que se calcula la velocidad
+const pasoGenerador = 1.0
+
+// OpcionesGenerador configura una corrida del generador. La misma semilla
+// con las mismas opciones produce exactamente las mismas muestras.
+type OpcionesGenerador struct {
+	Trazado    string
+	Perfil     string
+	Vueltas    int
+	Frecuencia float64 // muestras por segundo
+	Ruido      float64 // 0 sin ruido, 1 ruido típico de sensor
+	Semilla    int64
+}
+
+// Generar simula las vueltas pedidas y entrega cada muestra a emitir en
+// orden, sin guardarlas, así se pueden generar archivos grandes.
+func Generar(opciones OpcionesGenerador, emitir func(Muestra) error) error {
+	trazado, ok := Trazados[opciones.Trazado]
+	if !ok {
+		return fmt.Errorf("trazado desconocido %q (disponibles: %s)", opciones.Trazado, nombres(Trazados))
+	}
+	perfil, ok := Perfiles[opciones.Perfil]
+	if !ok {
+		return fmt.Errorf("perfil desconocido %q (disponibles: %s)", opciones.Perfil, nombres(Perfiles))
+	}
+	if opciones.Vueltas < 1 || opciones.Frecuencia <= 0 || opciones.Ruido < 0 {
+		return fmt.Errorf("vueltas y frecuencia deben ser positivas y el ruido no negativo")
+	}
+
+	azar := rand.New(rand.NewSource(opciones.Semilla))
+	dt := 1 / opciones.Frecuencia
+	tiempo := 0.0
+	s := 0.0
+
+	for vuelta := 1; vuelta <= opciones.Vueltas; vuelta++ {
+		// Cada vuelta sale un poco distinta, como la de un piloto real
+		variacion := 1 + azar.NormFloat64()*0.01*opciones.Ruido
+		perfilVuelta := perfil
+		perfilVuelta.Agarre *= variacion
+		perfilVuelta.Frenada *= 1 + azar.NormFloat64()*0.02*opciones.Ruido
+		v := perfilVelocidad(trazado, perfilVuelta)
+
+		for s < trazado.Longitud {
+			m := muestraEn(trazado, perfil, v, s)
+			m.Tiempo = tiempo
+			m.Vuelta = vuelta
+			agregarRuido(&m, perfil, azar, opciones.Ruido)
+			if err := emitir(redondear(m)); err != nil {
+				return err
+			}
+
+			tiempo += dt
+			s += velocidadEn(v, s) * dt
+		}
+		s -= trazado.Longitud
+	}
+	return nil
+}
+
+// perfilVelocidad calcula la velocidad máxima (m/s) en cada metro de la
+// vuelta: el límite de cada curva, limitado hacia atrás por la frenada y
+// hacia adelante por la aceleración, que cae con el arrastre.
+func perfilVelocidad(t Trazado, p PerfilAuto) []float64 {
+	n := int(t.Longitud/pasoGenerador) + 1
+	vmax := p.VelocidadMaxima / 3.6
+	v := make([]float64, n)
+	for i := range v {
+		v[i] = vmax
+	}
+
+	for _, c := range t.Curvas {
+		limite := math.Min(math.Sqrt(p.Agarre*c.Radio), vmax)
+		// La velocidad mínima se sostiene en un arco proporcional al radio
+		arco := math.Max(10, math.Min(c.Radio*0.8, 150))
+		for d := c.Distancia - arco/2; d <= c.Distancia+arco/2; d += pasoGenerador {
+			i := indiceCircular(d, t.Longitud, n)
+			v[i] = math.Min(v[i], limite)
+		}
+	}
+
+	// Dos pasadas por lado para que la vuelta cierre sobre sí misma
+	for pasada := 0; pasada < 2; pasada++ {
+		for i := 0; i < n; i++ {
+			anterior := v[(i-1+n)%n]
+			a := p.Aceleracion * (1 - math.Pow(anterior/vmax, 2))
+			v[i] = math.Min(v[i], math.Sqrt(anterior*anterior+2*a*pasoGenerador))
+		}
+		for i := n - 1; i >= 0; i-- {
+			siguiente := v[(i+1)%n]
+			a := p.Frenada + p.Aceleracion*math.Pow(siguiente/vmax, 2)
+			v[i] = math.Min(v[i], math.Sqrt(siguiente*siguiente+2*a*pasoGenerador))
+		}
+	}
+	return v
+}
+
+func indiceCircular(d, longitud float64, n int) int {
+	d = math.Mod(d, longitud)
+	if d < 0 {
+		d += longitud
+	}
+	return min(int(d/pasoGenerador), n-1)
+}
+
+// velocidadEn interpola el perfil de velocidad (m/s) en la distancia s.
+func velocidadEn(v []float64, s float64) float64 {
+	i := int(s / pasoGenerador)
+	if i >= len(v)-1 {
+		return v[len(v)-1]
+	}
+	f := s/pasoGenerador - float64(i)
+	return v[i] + f*(v[i+1]-v[i])
+}
+
+// muestraEn deriva los canales de la pendiente del perfil: si el auto
+// acelera o mantiene velocidad, el acelerador compensa el arrastre; si
+// desacelera más que el arrastre, frena.
+func muestraEn(t Trazado, p PerfilAuto, v []float64, s float64) Muestra {
+	vmax := p.VelocidadMaxima / 3.6
+	actual := velocidadEn(v, s)
+	siguiente := velocidadEn(v, math.Min(s+pasoGenerador, t.Longitud))
+	aceleracion := (siguiente*siguiente - actual*actual) / (2 * pasoGenerador)
+	arrastre := p.Aceleracion * math.Pow(actual/vmax, 2)
+
+	m := Muestra{Distancia: s, Velocidad: actual * 3.6}
+	if aceleracion >= -arrastre {
+		m.Acelerador = 100 * math.Min(1, (aceleracion+arrastre)/p.Aceleracion)
+	} else {
+		m.Freno = 100 * math.Min(1, (-aceleracion-arrastre)/p.Frenada)
+	}
+
+	m.Marcha, m.RPM = marchaYRPM(p, m.Velocidad)
+	for _, zona := range t.ZonasDRS {
+		if s >= zona[0] && s <= zona[1] && m.Acelerador > 99 {
+			m.DRS = true
+		}
+	}
+	return m
+}
+
+// marchaYRPM elige la marcha más baja que no pase el 97% del límite de
+// revoluciones. Las marchas altas son más cortas entre sí, como en un auto real.
+func marchaYRPM(p PerfilAuto, velocidad float64) (int, float64) {
+	for marcha := 1; marcha <= p.Marchas; marcha++ {
+		tope := p.VelocidadMaxima * math.Pow(float64(marcha+1)/float64(p.Marchas+1), 0.75)
+		if velocidad <= tope*0.97 || marcha == p.Marchas {
+			return marcha, math.Max(p.RPMMinimas, p.RPMMaximas*velocidad/tope)
+		}
+	}
+	return p.Marchas, p.RPMMaximas
+}
+
+// agregarRuido suma ruido gaussiano de sensor escalado por ruido.
+func agregarRuido(m *Muestra, p PerfilAuto, azar *rand.Rand, ruido float64) {
+	if ruido == 0 {
+		return
+	}
+	m.Velocidad = math.Max(0, m.Velocidad+azar.NormFloat64()*0.5*ruido)
+	m.RPM = math.Min(p.RPMMaximas*1.02, math.Max(0, m.RPM+azar.NormFloat64()*40*ruido))
+	m.Acelerador = math.Min(100, math.Max(0, m.Acelerador+azar.NormFloat64()*0.8*ruido))
+	if m.Freno > 0 {
+		m.Freno = math.Min(100, math.Max(0, m.Freno+azar.NormFloat64()*0.8*ruido))
+	}
+}
+
+func redondear(m Muestra) Muestra {
+	r := func(v, factor float64) float64 { return math.Round(v*factor) / factor }
+	m.Tiempo = r(m.Tiempo, 1000)
+	m.Distancia = r(m.Distancia, 100)
+	m.Velocidad = r(m.Velocidad, 10)
+	m.RPM = math.Round(m.RPM)
+	m.Acelerador = r(m.Acelerador, 10)
+	m.Freno = r(m.Freno, 10)
+	return m
+}
+
+func nombres[T any](opciones map[string]T) string {
+	lista := make([]string, 0, len(opciones))
+	for nombre := range opciones {
+		lista = append(lista, nombre)
+	}
+	sort.Strings(lista)
+	return strings.Join(lista, ", ")
+}
@@ -0,0 +1,494 @@
+package telemetria
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formato de un archivo de telemetría.
+type Formato string
+
+const (
+	FormatoCSV   Formato = "csv"
+	FormatoJSONL Formato = "jsonl"
+)
+
+// ErrArchivoVacio indica que el archivo no tiene encabezado ni filas.
+var ErrArchivoVacio = errors.New("el archivo de telemetría está vacío")
+
+// ErrEncabezado agrupa los problemas del encabezado que impiden leer el
+// archivo: falta el tiempo, no hay canales conocidos, columnas repetidas.
+var ErrEncabezado = errors.New("encabezado de telemetría inválido")
+
+// Tamaño máximo de una línea JSON
+const maxLineaJSONL = 1 << 20
+
+// ParseFormato valida el nombre de un formato.
+func ParseFormato(nombre string) (Formato, error) {
+	switch f := Formato(strings.ToLower(nombre)); f {
+	case FormatoCSV, FormatoJSONL:
+		return f, nil
+	case "ndjson":
+		return FormatoJSONL, nil
+	default:
+		return "", fmt.Errorf("formato de telemetría desconocido: %q (csv o jsonl)", nombre)
+	}
+}
+
+// DetectarFormato elige el formato por la extensión del archivo; ante la duda, CSV.
+func DetectarFormato(nombreArchivo string) Formato {
+	nombre := strings.ToLower(nombreArchivo)
+	if strings.HasSuffix(nombre, ".jsonl") || strings.HasSuffix(nombre, ".ndjson") {
+		return FormatoJSONL
+	}
+	return FormatoCSV
+}
+
+// ErrorFila describe una fila descartada. La lectura puede seguir después.
+type ErrorFila struct {
+	Fila    int    `json:"fila"`
+	Canal   Canal  `json:"canal,omitempty"`
+	Mensaje string `json:"mensaje"`
+}
+
+func (e *ErrorFila) Error() string {
+	if e.Canal != "" {
+		return fmt.Sprintf("fila %d, %s: %s", e.Fila, e.Canal, e.Mensaje)
+	}
+	return fmt.Sprintf("fila %d: %s", e.Fila, e.Mensaje)
+}
+
+// InformeErrores junta los errores por fila, guardando solo los primeros
+// max para que un archivo muy roto no genere una respuesta enorme.
+type InformeErrores struct {
+	Errores []ErrorFila `json:"errores"`
+	Total   int         `json:"total"`
+	max     int
+}
+
+func NuevoInformeErrores(max int) *InformeErrores {
+	return &InformeErrores{Errores: []ErrorFila{}, max: max}
+}
+
+func (i *InformeErrores) Agregar(e ErrorFila) {
+	i.Total++
+	if len(i.Errores) < i.max {
+		i.Errores = append(i.Errores, e)
+	}
+}
+
+// Lector recorre un archivo de telemetría fila por fila, sin cargarlo entero.
+type Lector struct {
+	formato   Formato
+	csv       *csv.Reader
+	lineas    *bufio.Scanner
+	columnas  []Canal // canal de cada columna CSV; "" si se ignora
+	canales   []Canal
+	ignoradas []string
+
+	linea     int
+	pendiente []byte // primera línea JSONL, ya leída para descubrir los canales
+	origen    time.Time
+	anterior  float64
+	hayPrevio bool
+}
+
+// NuevoLector lee el encabezado (o la primera línea JSON) y valida que estén
+// el tiempo y al menos otro canal conocido.
+func NuevoLector(r io.Reader, formato Formato) (*Lector, error) {
+	l := &Lector{formato: formato, ignoradas: []string{}}
+	var err error
+	switch formato {
+	case FormatoCSV:
+		err = l.leerEncabezadoCSV(r)
+	case FormatoJSONL:
+		err = l.leerPrimeraLineaJSONL(r)
+	default:
+		_, err = ParseFormato(string(formato))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !l.Tiene(CanalTiempo) {
+		return nil, fmt.Errorf("%w: falta el canal %s (alias: %s)", ErrEncabezado, CanalTiempo, strings.Join(Esquema[0].Alias, ", "))
+	}
+	if len(l.canales) < 2 {
+		return nil, fmt.Errorf("%w: no hay ningún canal de datos reconocido además del tiempo", ErrEncabezado)
+	}
+	return l, nil
+}
+
+// Formato devuelve el formato del archivo.
+func (l *Lector) Formato() Formato {
+	return l.formato
+}
+
+// Canales devuelve los canales presentes, en el orden del Esquema.
+func (l *Lector) Canales() []Canal {
+	return l.canales
+}
+
+// Ignoradas devuelve las columnas que no corresponden a ningún canal.
+func (l *Lector) Ignoradas() []string {
+	return l.ignoradas
+}
+
+// Tiene informa si el archivo trae un canal.
+func (l *Lector) Tiene(c Canal) bool {
+	for _, presente := range l.canales {
+		if presente == c {
+			return true
+		}
+	}
+	return false
+}
+
+// Leer devuelve la siguiente muestra. Una fila inválida se informa con un
+// *ErrorFila y la lectura puede continuar; al terminar devuelve io.EOF.
+// Cualquier otro error es fatal.
+func (l *Lector) Leer() (Muestra, error) {
+	var m Muestra
+	var err error
+	if l.formato == FormatoCSV {
+		m, err = l.leerCSV()
+	} else {
+		m, err = l.leerJSONL()
+	}
+	if err != nil {
+		return Muestra{}, err
+	}
+
+	if l.hayPrevio && m.Tiempo < l.anterior {
+		return Muestra{}, &ErrorFila{Fila: l.linea, Canal: CanalTiempo, Mensaje: fmt.Sprintf("el tiempo retrocede (%g < %g)", m.Tiempo, l.anterior)}
+	}
+	l.anterior, l.hayPrevio = m.Tiempo, true
+	return m, nil
+}
+
+// -------------------- CSV --------------------
+
+func (l *Lector) leerEncabezadoCSV(r io.Reader) error {
+	br := bufio.NewReader(r)
+	l.csv = csv.NewReader(br)
+	l.csv.Comma = detectarSeparador(br)
+	l.csv.FieldsPerRecord = -1
+	l.csv.LazyQuotes = true
+	l.csv.TrimLeadingSpace = true
+	l.csv.ReuseRecord = true
+
+	encabezado, err := l.csv.Read()
+	if err == io.EOF {
+		return ErrArchivoVacio
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrEncabezado, err)
+	}
+
+	l.columnas = make([]Canal, len(encabezado))
+	vistos := make(map[Canal]string)
+	for i, nombre := range encabezado {
+		if i == 0 {
+			nombre = strings.TrimPrefix(nombre, "\ufeff")
+		}
+		c, ok := CanalDeEncabezado(nombre)
+		if !ok {
+			l.ignoradas = append(l.ignoradas, nombre)
+			continue
+		}
+		if anterior, repetido := vistos[c]; repetido {
+			return fmt.Errorf("%w: las columnas %q y %q son ambas %s", ErrEncabezado, anterior, nombre, c)
+		}
+		vistos[c] = nombre
+		l.columnas[i] = c
+	}
+	l.canales = ordenarCanales(vistos)
+	return nil
+}
+
+// detectarSeparador mira la primera línea: las planillas en español suelen
+// exportar con punto y coma.
+func detectarSeparador(br *bufio.Reader) rune {
+	inicio, _ := br.Peek(4096)
+	if i := bytes.IndexByte(inicio, '\n'); i >= 0 {
+		inicio = inicio[:i]
+	}
+	mejor, cantidad := ',', bytes.Count(inicio, []byte{','})
+	for _, sep := range []rune{';', '\t'} {
+		if n := bytes.Count(inicio, []byte(string(sep))); n > cantidad {
+			mejor, cantidad = sep, n
+		}
+	}
+	return mejor
+}
+
+func (l *Lector) leerCSV() (Muestra, error) {
+	registro, err := l.csv.Read()
+	if err == io.EOF {
+		return Muestra{}, io.EOF
+	}
+	var errParseo *csv.ParseError
+	if errors.As(err, &errParseo) {
+		l.linea = errParseo.Line
+		return Muestra{}, &ErrorFila{Fila: errParseo.Line, Mensaje: errParseo.Err.Error()}
+	}
+	if err != nil {
+		return Muestra{}, err
+	}
+	l.linea, _ = l.csv.FieldPos(0)
+
+	if len(registro) != len(l.columnas) {
+		return Muestra{}, &ErrorFila{Fila: l.linea, Mensaje: fmt.Sprintf("la fila tiene %d columnas y el encabezado %d", len(registro), len(l.columnas))}
+	}
+
+	var m Muestra
+	for i, c := range l.columnas {
+		if c == "" {
+			continue
+		}
+		if err := l.cargar(&m, c, registro[i]); err != nil {
+			return Muestra{}, err
+		}
+	}
+	return m, nil
+}
+
+// -------------------- JSON Lines --------------------
+
+func (l *Lector) leerPrimeraLineaJSONL(r io.Reader) error {
+	l.lineas = bufio.NewScanner(r)
+	l.lineas.Buffer(make([]byte, 64*1024), maxLineaJSONL)
+
+	for l.lineas.Scan() {
+		l.linea++
+		linea := bytes.TrimSpace(l.lineas.Bytes())
+		if len(linea) == 0 {
+			continue
+		}
+		objeto, err := decodificarLinea(linea)
+		if err != nil {
+			return fmt.Errorf("%w: línea %d: %v", ErrEncabezado, l.linea, err)
+		}
+
+		vistos := make(map[Canal]string)
+		for clave := range objeto {
+			c, ok := CanalDeEncabezado(clave)
+			if !ok {
+				l.ignoradas = append(l.ignoradas, clave)
+				continue
+			}
+			if anterior, repetido := vistos[c]; repetido {
+				return fmt.Errorf("%w: las claves %q y %q son ambas %s", ErrEncabezado, anterior, clave, c)
+			}
+			vistos[c] = clave
+		}
+		sort.Strings(l.ignoradas)
+		l.canales = ordenarCanales(vistos)
+		l.pendiente = append([]byte(nil), linea...)
+		l.linea--
+		return nil
+	}
+	if err := l.lineas.Err(); err != nil {
+		return err
+	}
+	return ErrArchivoVacio
+}
+
+func (l *Lector) leerJSONL() (Muestra, error) {
+	var linea []byte
+	if l.pendiente != nil {
+		linea, l.pendiente = l.pendiente, nil
+		l.linea++
+	} else {
+		for len(linea) == 0 {
+			if !l.lineas.Scan() {
+				if err := l.lineas.Err(); err != nil {
+					return Muestra{}, err
+				}
+				return Muestra{}, io.EOF
+			}
+			l.linea++
+			linea = bytes.TrimSpace(l.lineas.Bytes())
+		}
+	}
+
+	objeto, err := decodificarLinea(linea)
+	if err != nil {
+		return Muestra{}, &ErrorFila{Fila: l.linea, Mensaje: err.Error()}
+	}
+	valores := make(map[Canal]any, len(objeto))
+	for clave, valor := range objeto {
+		if c, ok := CanalDeEncabezado(clave); ok {
+			valores[c] = valor
+		}
+	}
+
+	var m Muestra
+	for _, c := range l.canales {
+		valor, ok := valores[c]
+		if !ok {
+			return Muestra{}, &ErrorFila{Fila: l.linea, Canal: c, Mensaje: "falta el canal"}
+		}
+		texto := ""
+		switch v := valor.(type) {
+		case json.Number:
+			texto = v.String()
+		case string:
+			texto = v
+		case bool:
+			texto = strconv.FormatBool(v)
+		case nil:
+		default:
+			return Muestra{}, &ErrorFila{Fila: l.linea, Canal: c, Mensaje: "se esperaba un número, texto o booleano"}
+		}
+		if err := l.cargar(&m, c, texto); err != nil {
+			return Muestra{}, err
+		}
+	}
+	return m, nil
+}
+
+func decodificarLinea(linea []byte) (map[string]any, error) {
+	dec := json.NewDecoder(bytes.NewReader(linea))
+	dec.UseNumber()
+	var objeto map[string]any
+	if err := dec.Decode(&objeto); err != nil {
+		return nil, fmt.Errorf("JSON inválido: %v", err)
+	}
+	if objeto == nil {
+		return nil, errors.New("se esperaba un objeto JSON")
+	}
+	return objeto, nil
+}
+
+// -------------------- Valores --------------------
+
+// cargar interpreta y valida el valor de un canal y lo guarda en la muestra.
+func (l *Lector) cargar(m *Muestra, c Canal, texto string) error {
+	v, err := l.interpretar(c, strings.TrimSpace(texto))
+	if err != nil {
+		return &ErrorFila{Fila: l.linea, Canal: c, Mensaje: err.Error()}
+	}
+	m.asignar(c, v)
+	return nil
+}
+
+func (l *Lector) interpretar(c Canal, texto string) (float64, error) {
+	if texto == "" {
+		return 0, errors.New("valor vacío")
+	}
+
+	var v float64
+	var err error
+	switch c {
+	case CanalTiempo:
+		v, err = l.interpretarTiempo(texto)
+	case CanalFreno, CanalDRS:
+		// Aceptan booleanos: el freno pasa a 0 o 100 %, el DRS a 0 o 1
+		if b, ok := interpretarBooleano(texto); ok {
+			v = b
+			if c == CanalFreno {
+				v *= 100
+			}
+			return v, nil
+		}
+		v, err = interpretarNumero(texto)
+	default:
+		v, err = interpretarNumero(texto)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if (c == CanalMarcha || c == CanalVuelta) && v != math.Trunc(v) {
+		return 0, fmt.Errorf("%q no es un entero", texto)
+	}
+	d, _ := Definicion(c)
+	if v < d.Min || v > d.Max {
+		return 0, fmt.Errorf("%g fuera de rango [%g, %g]", v, d.Min, d.Max)
+	}
+	if c == CanalDRS {
+		// 0/1 como booleano, o los códigos de FastF1 (10, 12, 14 = abierto)
+		if v == 1 || v >= 10 {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return v, nil
+}
+
+func interpretarNumero(texto string) (float64, error) {
+	v, err := strconv.ParseFloat(texto, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("%q no es un número", texto)
+	}
+	return v, nil
+}
+
+func interpretarBooleano(texto string) (float64, bool) {
+	switch strings.ToLower(texto) {
+	case "true", "si", "sí", "yes", "on":
+		return 1, true
+	case "false", "no", "off":
+		return 0, true
+	}
+	return 0, false
+}
+
+// interpretarTiempo acepta segundos ("83.456"), duraciones ("1:23.456",
+// "0:01:23.456", "0 days 00:01:23.456" como exporta pandas) o instantes
+// RFC 3339, que se pasan a segundos desde el primero del archivo.
+func (l *Lector) interpretarTiempo(texto string) (float64, error) {
+	if v, err := strconv.ParseFloat(texto, 64); err == nil && !math.IsNaN(v) && !math.IsInf(v, 0) {
+		return v, nil
+	}
+	if instante, err := time.Parse(time.RFC3339Nano, texto); err == nil {
+		if l.origen.IsZero() {
+			l.origen = instante
+		}
+		return instante.Sub(l.origen).Seconds(), nil
+	}
+
+	total := 0.0
+	if dias, resto, ok := strings.Cut(texto, " days "); ok {
+		n, err := strconv.Atoi(dias)
+		if err != nil {
+			return 0, fmt.Errorf("tiempo %q inválido", texto)
+		}
+		total, texto = float64(n)*86400, resto
+	}
+	partes := strings.Split(texto, ":")
+	if len(partes) < 2 || len(partes) > 3 {
+		return 0, fmt.Errorf("tiempo %q inválido", texto)
+	}
+	segundos := 0.0
+	for _, parte := range partes {
+		v, err := strconv.ParseFloat(parte, 64)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("tiempo %q inválido", texto)
+		}
+		segundos = segundos*60 + v
+	}
+	return total + segundos, nil
+}
+
+// ordenarCanales devuelve los canales presentes en el orden del Esquema.
+func ordenarCanales(presentes map[Canal]string) []Canal {
+	canales := make([]Canal, 0, len(presentes))
+	for _, d := range Esquema {
+		if _, ok := presentes[d.Canal]; ok {
+			canales = append(canales, d.Canal)
+		}
+	}
+	return canales
+}
@@ -0,0 +1,179 @@
+package telemetria
+
+import (
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// Lote es un bloque de muestras consecutivas. Inicio es la posición de la
+// primera muestra válida del archivo, para los análisis que dependen del orden.
+// Contexto son las muestras inmediatamente anteriores (ver Solape), para los
+// análisis con ventanas que cruzan el borde del lote; Ultimo marca el final
+// del archivo.
+type Lote struct {
+	Inicio   int
+	Muestras []Muestra
+	Contexto []Muestra
+	Ultimo   bool
+}
+
+// Acumulador es el resultado parcial de un trabajador: procesa lotes en
+// cualquier orden y al final se combina con los de los demás trabajadores.
+type Acumulador[A any] interface {
+	Acumular(lote Lote)
+	Combinar(otro A)
+}
+
+// OpcionesPipeline controla el paralelismo y la memoria del procesamiento.
+// La memoria usada es proporcional a TamanoLote × (LotesEnVuelo + Trabajadores),
+// sin importar el tamaño del archivo. Con Solape > 0 cada lote trae como
+// Contexto esa cantidad de muestras previas y el último lote llega siempre
+// marcado, aunque esté vacío.
+type OpcionesPipeline struct {
+	Trabajadores int
+	TamanoLote   int
+	LotesEnVuelo int
+	Solape       int
+}
+
+// OpcionesPorDefecto usa un trabajador por CPU.
+func OpcionesPorDefecto() OpcionesPipeline {
+	return OpcionesPipeline{Trabajadores: runtime.NumCPU(), TamanoLote: 4096, LotesEnVuelo: 8}
+}
+
+func (o OpcionesPipeline) normalizar() OpcionesPipeline {
+	def := OpcionesPorDefecto()
+	if o.Trabajadores < 1 {
+		o.Trabajadores = def.Trabajadores
+	}
+	if o.TamanoLote < 1 {
+		o.TamanoLote = def.TamanoLote
+	}
+	if o.LotesEnVuelo < 1 {
+		o.LotesEnVuelo = def.LotesEnVuelo
+	}
+	return o
+}
+
+// Estadisticas describe cómo se repartió el trabajo.
+type Estadisticas struct {
+	Trabajadores    int   `json:"trabajadores"`
+	Lotes           int   `json:"lotes"`
+	Muestras        int   `json:"muestras"`
+	LotesPorTrabajo []int `json:"lotes_por_trabajador"`
+}
+
+// Procesar recorre el archivo con una goroutine lectora que arma lotes, un
+// canal acotado de lotes y un grupo de trabajadores, cada uno con su propio
+// acumulador creado con nuevo. Los errores por fila van a informe. Devuelve
+// la combinación de todos los acumuladores.
+func Procesar[A Acumulador[A]](ctx context.Context, lector *Lector, opciones OpcionesPipeline, informe *InformeErrores, nuevo func() A) (A, Estadisticas, error) {
+	opciones = opciones.normalizar()
+	ctx, cancelar := context.WithCancel(ctx)
+	defer cancelar()
+
+	lotes := make(chan Lote, opciones.LotesEnVuelo)
+	libres := sync.Pool{New: func() any {
+		return make([]Muestra, 0, opciones.TamanoLote)
+	}}
+
+	estadisticas := Estadisticas{Trabajadores: opciones.Trabajadores, LotesPorTrabajo: make([]int, opciones.Trabajadores)}
+	var errLectura error
+
+	// Lector: el único que toca el archivo y el informe de errores
+	go func() {
+		defer close(lotes)
+		errLectura = leerLotes(ctx, lector, opciones, informe, &libres, lotes, &estadisticas)
+	}()
+
+	parciales := make([]A, opciones.Trabajadores)
+	var wg sync.WaitGroup
+	for i := range parciales {
+		parciales[i] = nuevo()
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for lote := range lotes {
+				parciales[i].Acumular(lote)
+				estadisticas.LotesPorTrabajo[i]++
+				libres.Put(lote.Muestras[:0])
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if errLectura != nil {
+		var cero A
+		return cero, estadisticas, errLectura
+	}
+	if err := ctx.Err(); err != nil {
+		var cero A
+		return cero, estadisticas, err
+	}
+
+	total := parciales[0]
+	for _, parcial := range parciales[1:] {
+		total.Combinar(parcial)
+	}
+	return total, estadisticas, nil
+}
+
+func leerLotes(ctx context.Context, lector *Lector, opciones OpcionesPipeline, informe *InformeErrores, libres *sync.Pool, lotes chan<- Lote, estadisticas *Estadisticas) error {
+	tamano := opciones.TamanoLote
+	actual := Lote{Muestras: libres.Get().([]Muestra)}
+	enviar := func() error {
+		// El contexto del lote siguiente se copia: el lote vuelve al pool
+		var contexto []Muestra
+		if opciones.Solape > 0 {
+			contexto = ultimasMuestras(actual.Contexto, actual.Muestras, opciones.Solape)
+		}
+		select {
+		case lotes <- actual:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		estadisticas.Lotes++
+		actual = Lote{Inicio: estadisticas.Muestras, Muestras: libres.Get().([]Muestra), Contexto: contexto}
+		return nil
+	}
+
+	for {
+		muestra, err := lector.Leer()
+		if err == io.EOF {
+			break
+		}
+		var errFila *ErrorFila
+		if errors.As(err, &errFila) {
+			informe.Agregar(*errFila)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		actual.Muestras = append(actual.Muestras, muestra)
+		estadisticas.Muestras++
+		if len(actual.Muestras) == tamano {
+			if err := enviar(); err != nil {
+				return err
+			}
+		}
+	}
+	if len(actual.Muestras) > 0 || opciones.Solape > 0 {
+		actual.Ultimo = true
+		return enviar()
+	}
+	return nil
+}
+
+// ultimasMuestras copia las últimas n muestras de previas seguidas de muestras.
+func ultimasMuestras(previas, muestras []Muestra, n int) []Muestra {
+	copia := make([]Muestra, 0, n)
+	if faltan := n - len(muestras); faltan > 0 {
+		copia = append(copia, previas[max(0, len(previas)-faltan):]...)
+	}
+	return append(copia, muestras[max(0, len(muestras)-n):]...)
+}
@@ -0,0 +1,143 @@
+package telemetria
+
+import (
+	"math"
+	"sort"
+)
+
+// Compresión por defecto del t-digest: ~100 centroides, error típico menor
+// al 1 % en los percentiles centrales y mucho menor en los extremos.
+const compresionPorDefecto = 100
+
+type centroide struct {
+	media float64
+	peso  float64
+}
+
+// TDigest estima percentiles con memoria acotada y se puede combinar con
+// otros, así cada trabajador arma el suyo (Dunning, "Computing extremely
+// accurate quantiles using t-digests", variante con fusión).
+type TDigest struct {
+	compresion float64
+	centroides []centroide
+	buffer     []centroide
+	total      float64
+	min, max   float64
+}
+
+func NuevoTDigest(compresion float64) *TDigest {
+	if compresion <= 0 {
+		compresion = compresionPorDefecto
+	}
+	return &TDigest{compresion: compresion, min: math.Inf(1), max: math.Inf(-1)}
+}
+
+// Agregar suma un valor; los valores se acumulan y se comprimen por tandas.
+func (t *TDigest) Agregar(v float64) {
+	t.buffer = append(t.buffer, centroide{media: v, peso: 1})
+	t.min = math.Min(t.min, v)
+	t.max = math.Max(t.max, v)
+	if len(t.buffer) >= int(5*t.compresion) {
+		t.comprimir()
+	}
+}
+
+// Combinar suma los valores de otro digest.
+func (t *TDigest) Combinar(otro *TDigest) {
+	if otro.Cantidad() == 0 {
+		return
+	}
+	t.buffer = append(t.buffer, otro.centroides...)
+	t.buffer = append(t.buffer, otro.buffer...)
+	t.min = math.Min(t.min, otro.min)
+	t.max = math.Max(t.max, otro.max)
+	t.comprimir()
+}
+
+// Cantidad devuelve cuántos valores se agregaron.
+func (t *TDigest) Cantidad() float64 {
+	cantidad := t.total
+	for _, c := range t.buffer {
+		cantidad += c.peso
+	}
+	return cantidad
+}
+
+// Percentil estima el valor por debajo del cual queda la fracción q (0 a 1).
+func (t *TDigest) Percentil(q float64) float64 {
+	t.comprimir()
+	switch {
+	case len(t.centroides) == 0:
+		return math.NaN()
+	case q <= 0:
+		return t.min
+	case q >= 1:
+		return t.max
+	case len(t.centroides) == 1:
+		return t.centroides[0].media
+	}
+
+	// Se interpola entre los centros de los centroides vecinos; en las
+	// puntas, entre el mínimo o el máximo y el primer o último centro
+	objetivo := q * t.total
+	acumulado := 0.0
+	for i, c := range t.centroides {
+		centro := acumulado + c.peso/2
+		if objetivo < centro {
+			if i == 0 {
+				return t.min + (c.media-t.min)*objetivo/centro
+			}
+			previo := t.centroides[i-1]
+			centroPrevio := acumulado - previo.peso/2
+			return previo.media + (c.media-previo.media)*(objetivo-centroPrevio)/(centro-centroPrevio)
+		}
+		acumulado += c.peso
+	}
+	ultimo := t.centroides[len(t.centroides)-1]
+	centro := t.total - ultimo.peso/2
+	return ultimo.media + (t.max-ultimo.media)*(objetivo-centro)/(t.total-centro)
+}
+
+// comprimir fusiona el buffer con los centroides respetando el límite de
+// tamaño que impone la función de escala k1.
+func (t *TDigest) comprimir() {
+	if len(t.buffer) == 0 {
+		return
+	}
+	todos := append(t.centroides, t.buffer...)
+	t.buffer = t.buffer[:0]
+	sort.Slice(todos, func(i, j int) bool { return todos[i].media < todos[j].media })
+
+	total := 0.0
+	for _, c := range todos {
+		total += c.peso
+	}
+
+	nuevos := make([]centroide, 0, int(2*t.compresion))
+	actual := todos[0]
+	pesoPrevio := 0.0
+	limite := t.cuantilLimite(0)
+	for _, c := range todos[1:] {
+		if (pesoPrevio+actual.peso+c.peso)/total <= limite {
+			actual.peso += c.peso
+			actual.media += (c.media - actual.media) * c.peso / actual.peso
+			continue
+		}
+		pesoPrevio += actual.peso
+		nuevos = append(nuevos, actual)
+		limite = t.cuantilLimite(pesoPrevio / total)
+		actual = c
+	}
+	t.centroides = append(nuevos, actual)
+	t.total = total
+}
+
+// cuantilLimite es hasta qué cuantil puede crecer un centroide que empieza
+// en q: k⁻¹(k(q) + 1), con k(q) = δ/2π · asin(2q - 1).
+func (t *TDigest) cuantilLimite(q float64) float64 {
+	k := t.compresion/(2*math.Pi)*math.Asin(2*q-1) + 1
+	if k >= t.compresion/4 {
+		return 1
+	}
+	return (math.Sin(k*2*math.Pi/t.compresion) + 1) / 2
+}
@@ -0,0 +1,365 @@
+package telemetria
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+// Minisectores por vuelta para calcular la vuelta ideal
+const minisectoresPorDefecto = 25
+
+// Circuito describe dónde empiezan los sectores. Los límites son distancias
+// en metros desde la línea de meta donde empiezan los sectores 2, 3, ...
+// Sin longitud se estima con la mediana de las vueltas completas; sin
+// límites se usan tres sectores iguales.
+type Circuito struct {
+	Longitud     float64   `json:"longitud"`
+	Limites      []float64 `json:"limites"`
+	Minisectores int       `json:"minisectores"`
+}
+
+// TiempoSector es el tiempo de un sector, numerado desde 1 como en la vista
+// del circuito de /simulacion.
+type TiempoSector struct {
+	Sector int     `json:"sector"`
+	Tiempo float64 `json:"tiempo"`
+	Vuelta int     `json:"vuelta,omitempty"`
+}
+
+// Vuelta es una vuelta detectada en la traza.
+type Vuelta struct {
+	Numero        int            `json:"vuelta"`
+	Completa      bool           `json:"completa"`
+	TiempoInicial float64        `json:"tiempo_inicial"`
+	Tiempo        float64        `json:"tiempo,omitempty"`
+	Distancia     float64        `json:"distancia"`
+	Sectores      []TiempoSector `json:"sectores,omitempty"`
+
+	// Índices de la primera y última muestra (para comparar vueltas)
+	Desde int `json:"desde"`
+	Hasta int `json:"hasta"`
+
+	minisectores []float64
+	inicio, fin  float64 // distancia acumulada
+}
+
+// AnalisisVueltas es el resultado de segmentar una traza.
+type AnalisisVueltas struct {
+	Circuito        Circuito       `json:"circuito"`
+	Sectores        int            `json:"sectores"`
+	Deteccion       string         `json:"deteccion"`
+	Vueltas         []Vuelta       `json:"vueltas"`
+	MejorVuelta     *Vuelta        `json:"mejor_vuelta,omitempty"`
+	MejoresSectores []TiempoSector `json:"mejores_sectores,omitempty"`
+	VueltaTeorica   float64        `json:"vuelta_teorica,omitempty"`
+	VueltaIdeal     float64        `json:"vuelta_ideal,omitempty"`
+
+	muestras []Muestra
+	canales  []Canal
+	traza    *traza
+}
+
+// ErrSinVueltas indica que la traza no alcanza para detectar una vuelta completa.
+var ErrSinVueltas = errors.New("la telemetría no tiene ninguna vuelta completa")
+
+// DetectarVueltas segmenta una traza ordenada por tiempo en vueltas y
+// sectores. Usa el canal vuelta si está; si no, los reinicios de la
+// distancia o, con una longitud dada, cada vez que la distancia acumulada
+// pasa por la meta. Sin canal distancia, la integra desde la velocidad.
+// El tiempo de cada límite se interpola entre las muestras vecinas.
+func DetectarVueltas(muestras []Muestra, canales []Canal, circuito Circuito) (*AnalisisVueltas, error) {
+	tiene := func(buscado Canal) bool {
+		for _, c := range canales {
+			if c == buscado {
+				return true
+			}
+		}
+		return false
+	}
+	if !tiene(CanalDistancia) && !tiene(CanalVelocidad) {
+		return nil, errors.New("para detectar vueltas hace falta el canal distancia o velocidad")
+	}
+	if !sort.Float64sAreSorted(circuito.Limites) || (len(circuito.Limites) > 0 && circuito.Limites[0] <= 0) {
+		return nil, errors.New("los límites de los sectores deben ser positivos y crecientes")
+	}
+	if circuito.Longitud > 0 && len(circuito.Limites) > 0 && circuito.Limites[len(circuito.Limites)-1] >= circuito.Longitud {
+		return nil, errors.New("los límites de los sectores deben ser menores que la longitud de la vuelta")
+	}
+	if circuito.Minisectores <= 0 {
+		circuito.Minisectores = minisectoresPorDefecto
+	}
+	if len(muestras) < 2 {
+		return nil, ErrSinVueltas
+	}
+
+	traza := nuevaTraza(muestras, tiene(CanalDistancia))
+	resultado := &AnalisisVueltas{muestras: muestras, canales: canales, traza: traza}
+	var inicios []int
+	switch {
+	case tiene(CanalVuelta):
+		resultado.Deteccion = "canal vuelta"
+		inicios = traza.cambiosDeVuelta(muestras)
+	case len(traza.reinicios) > 0:
+		resultado.Deteccion = "reinicio de distancia"
+		inicios = append([]int{0}, traza.reinicios...)
+	case circuito.Longitud > 0:
+		resultado.Deteccion = "longitud del circuito"
+	default:
+		resultado.Deteccion = "una sola vuelta"
+		inicios = []int{0}
+	}
+
+	// Cada vuelta va de una distancia acumulada de inicio a la siguiente
+	var cortes []float64
+	if inicios == nil {
+		// Lo recorrido antes de la primera pasada por la meta no es una vuelta
+		primera := math.Ceil(traza.distancia[0]/circuito.Longitud) * circuito.Longitud
+		for d := primera; d <= traza.distancia[len(muestras)-1]; d += circuito.Longitud {
+			cortes = append(cortes, d)
+		}
+	} else {
+		for _, i := range inicios {
+			cortes = append(cortes, traza.inicioDeVuelta(i))
+		}
+	}
+	if len(cortes) == 0 {
+		return nil, ErrSinVueltas
+	}
+	final := traza.distancia[len(muestras)-1]
+
+	for k, inicio := range cortes {
+		fin, completa := final, k+1 < len(cortes)
+		if completa {
+			fin = cortes[k+1]
+		}
+		v := Vuelta{
+			Numero:        k + 1,
+			Completa:      completa,
+			TiempoInicial: traza.tiempoEn(inicio),
+			Distancia:     fin - inicio,
+			inicio:        inicio,
+			fin:           fin,
+		}
+		if tiene(CanalVuelta) {
+			v.Numero = muestras[inicios[k]].Vuelta
+		}
+		v.Desde, v.Hasta = traza.indices(inicio, fin)
+		if completa {
+			v.Tiempo = traza.tiempoEn(fin) - v.TiempoInicial
+		}
+		resultado.Vueltas = append(resultado.Vueltas, v)
+	}
+
+	// Sin longitud, la de referencia es la mediana de las vueltas completas;
+	// una vuelta final que la alcanza también cuenta como completa
+	if circuito.Longitud <= 0 {
+		circuito.Longitud = medianaDistancia(resultado.Vueltas)
+	}
+	if circuito.Longitud <= 0 {
+		return nil, ErrSinVueltas
+	}
+	if ultima := &resultado.Vueltas[len(resultado.Vueltas)-1]; !ultima.Completa && ultima.Distancia >= 0.98*circuito.Longitud {
+		ultima.Completa = true
+		ultima.fin = ultima.inicio + circuito.Longitud
+		ultima.Tiempo = traza.tiempoEn(ultima.fin) - ultima.TiempoInicial
+	}
+	if len(circuito.Limites) == 0 {
+		circuito.Limites = []float64{circuito.Longitud / 3, 2 * circuito.Longitud / 3}
+	}
+	resultado.Circuito = circuito
+	resultado.Sectores = len(circuito.Limites) + 1
+
+	for i := range resultado.Vueltas {
+		v := &resultado.Vueltas[i]
+		if !v.Completa {
+			continue
+		}
+		v.Sectores = traza.tiemposSectores(v, circuito.Limites)
+		v.minisectores = traza.tiemposMinisectores(v, circuito.Minisectores)
+	}
+	resultado.resumir()
+	if resultado.MejorVuelta == nil {
+		return nil, ErrSinVueltas
+	}
+	return resultado, nil
+}
+
+// resumir calcula la mejor vuelta, los mejores sectores, la vuelta teórica
+// (suma de los mejores sectores) y la ideal (suma de los mejores minisectores).
+func (a *AnalisisVueltas) resumir() {
+	a.MejoresSectores = nil
+	var mejoresMini []float64
+	for i := range a.Vueltas {
+		v := &a.Vueltas[i]
+		if !v.Completa {
+			continue
+		}
+		if a.MejorVuelta == nil || v.Tiempo < a.MejorVuelta.Tiempo {
+			a.MejorVuelta = v
+		}
+		for j, s := range v.Sectores {
+			if j >= len(a.MejoresSectores) {
+				a.MejoresSectores = append(a.MejoresSectores, TiempoSector{Sector: s.Sector, Tiempo: s.Tiempo, Vuelta: v.Numero})
+			} else if s.Tiempo < a.MejoresSectores[j].Tiempo {
+				a.MejoresSectores[j] = TiempoSector{Sector: s.Sector, Tiempo: s.Tiempo, Vuelta: v.Numero}
+			}
+		}
+		for j, t := range v.minisectores {
+			if j >= len(mejoresMini) {
+				mejoresMini = append(mejoresMini, t)
+			} else {
+				mejoresMini[j] = math.Min(mejoresMini[j], t)
+			}
+		}
+	}
+	if a.MejorVuelta != nil {
+		mejor := *a.MejorVuelta
+		a.MejorVuelta = &mejor
+	}
+
+	a.VueltaTeorica = 0
+	for _, s := range a.MejoresSectores {
+		a.VueltaTeorica += s.Tiempo
+	}
+	a.VueltaIdeal = 0
+	for _, t := range mejoresMini {
+		a.VueltaIdeal += t
+	}
+}
+
+// -------------------- Traza --------------------
+
+// traza guarda la distancia acumulada (sin reinicios) de cada muestra, que
+// junto con el tiempo permite interpolar cuándo se pasó por cada punto.
+type traza struct {
+	tiempo    []float64
+	distancia []float64
+	reinicios []int // índices donde la distancia del archivo vuelve a empezar
+	propia    []float64
+}
+
+func nuevaTraza(muestras []Muestra, conDistancia bool) *traza {
+	t := &traza{
+		tiempo:    make([]float64, len(muestras)),
+		distancia: make([]float64, len(muestras)),
+		propia:    make([]float64, len(muestras)),
+	}
+	for i, m := range muestras {
+		t.tiempo[i] = m.Tiempo
+		if !conDistancia {
+			// Trapecios sobre la velocidad en km/h
+			if i > 0 {
+				dt := m.Tiempo - muestras[i-1].Tiempo
+				t.propia[i] = t.propia[i-1] + (m.Velocidad+muestras[i-1].Velocidad)/2/3.6*dt
+			}
+			t.distancia[i] = t.propia[i]
+			continue
+		}
+
+		t.propia[i] = m.Distancia
+		if i == 0 {
+			t.distancia[i] = m.Distancia
+			continue
+		}
+		delta := m.Distancia - muestras[i-1].Distancia
+		if delta < 0 {
+			// La distancia volvió a cero: se cruzó la meta
+			t.reinicios = append(t.reinicios, i)
+			delta = m.Distancia
+		}
+		t.distancia[i] = t.distancia[i-1] + delta
+	}
+	return t
+}
+
+// cambiosDeVuelta devuelve el índice de la primera muestra de cada vuelta.
+func (t *traza) cambiosDeVuelta(muestras []Muestra) []int {
+	inicios := []int{0}
+	for i := 1; i < len(muestras); i++ {
+		if muestras[i].Vuelta != muestras[i-1].Vuelta {
+			inicios = append(inicios, i)
+		}
+	}
+	return inicios
+}
+
+// inicioDeVuelta estima la distancia acumulada de la meta para una vuelta
+// que empieza en la muestra i: si la distancia del archivo se reinicia en
+// cada vuelta, la meta quedó esa distancia atrás.
+func (t *traza) inicioDeVuelta(i int) float64 {
+	if i > 0 && t.propia[i] < t.propia[i-1] {
+		return t.distancia[i] - t.propia[i]
+	}
+	return t.distancia[i]
+}
+
+// tiempoEn interpola el instante en que se alcanzó una distancia acumulada.
+func (t *traza) tiempoEn(d float64) float64 {
+	n := len(t.distancia)
+	i := sort.SearchFloat64s(t.distancia, d)
+	switch {
+	case i == 0:
+		return t.tiempo[0]
+	case i >= n:
+		return t.tiempo[n-1]
+	}
+	d0, d1 := t.distancia[i-1], t.distancia[i]
+	if d1 == d0 {
+		return t.tiempo[i]
+	}
+	return t.tiempo[i-1] + (t.tiempo[i]-t.tiempo[i-1])*(d-d0)/(d1-d0)
+}
+
+// indices devuelve la primera y la última muestra dentro de [inicio, fin].
+func (t *traza) indices(inicio, fin float64) (int, int) {
+	desde := sort.SearchFloat64s(t.distancia, inicio)
+	hasta := sort.Search(len(t.distancia), func(i int) bool { return t.distancia[i] > fin }) - 1
+	return desde, max(desde, hasta)
+}
+
+func (t *traza) tiemposSectores(v *Vuelta, limites []float64) []TiempoSector {
+	puntos := []float64{v.inicio}
+	for _, l := range limites {
+		puntos = append(puntos, v.inicio+l)
+	}
+	puntos = append(puntos, v.fin)
+
+	sectores := make([]TiempoSector, 0, len(puntos)-1)
+	for i := 1; i < len(puntos); i++ {
+		sectores = append(sectores, TiempoSector{Sector: i, Tiempo: t.tiempoEn(puntos[i]) - t.tiempoEn(puntos[i-1])})
+	}
+	return sectores
+}
+
+// tiemposMinisectores divide la vuelta en partes iguales de su propia
+// longitud, así pequeñas diferencias de trazada no corren los límites.
+func (t *traza) tiemposMinisectores(v *Vuelta, cantidad int) []float64 {
+	tiempos := make([]float64, cantidad)
+	largo := (v.fin - v.inicio) / float64(cantidad)
+	anterior := t.tiempoEn(v.inicio)
+	for i := range tiempos {
+		siguiente := t.tiempoEn(v.inicio + float64(i+1)*largo)
+		tiempos[i] = siguiente - anterior
+		anterior = siguiente
+	}
+	return tiempos
+}
+
+func medianaDistancia(vueltas []Vuelta) float64 {
+	var distancias []float64
+	for _, v := range vueltas {
+		if v.Completa {
+			distancias = append(distancias, v.Distancia)
+		}
+	}
+	if len(distancias) == 0 {
+		// Una sola vuelta: se toma entera
+		if len(vueltas) == 1 {
+			return vueltas[0].Distancia
+		}
+		return 0
+	}
+	sort.Float64s(distancias)
+	return distancias[len(distancias)/2]
+}
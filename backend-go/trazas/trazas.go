@@ -0,0 +1,104 @@
+// Package trazas configura las trazas OpenTelemetry del backend. Cada
+// paquete crea sus spans con otel.Tracer; Iniciar decide adónde van: a un
+// colector OTLP, a un archivo para verlas sin servicios externos o a
+// ninguna parte.
+package trazas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+// Exportadores
+const (
+	Ninguno = "ninguno"
+	OTLP    = "otlp"
+	Archivo = "archivo"
+)
+
+// Opciones elige el exportador y cuántas trazas se registran.
+type Opciones struct {
+	Exportador string  // ninguno, otlp o archivo
+	Endpoint   string  // URL del colector OTLP/HTTP, como http://localhost:4318
+	Archivo    string  // con el exportador archivo: un span JSON por línea
+	Muestreo   float64 // fracción de las trazas nuevas, de 0 a 1
+	Servicio   string  // service.name de los spans
+}
+
+// Iniciar instala el proveedor global de trazas y el propagador W3C
+// (traceparent), para continuar las trazas que empiece un proxy o el
+// frontend. Devuelve la función que envía los spans pendientes y cierra el
+// exportador; hay que llamarla al apagar. Con el exportador ninguno los
+// spans no se registran y no cuestan casi nada.
+func Iniciar(ctx context.Context, o Opciones) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if o.Exportador == Ninguno {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exportador, err := nuevoExportador(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+	recurso, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithAttributes(semconv.ServiceName(o.Servicio)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("recurso de las trazas: %w", err)
+	}
+
+	proveedor := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exportador),
+		sdktrace.WithResource(recurso),
+		// Si el pedido llega con una traza, se respeta su decisión
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(o.Muestreo))),
+	)
+	otel.SetTracerProvider(proveedor)
+	return proveedor.Shutdown, nil
+}
+
+func nuevoExportador(ctx context.Context, o Opciones) (sdktrace.SpanExporter, error) {
+	switch o.Exportador {
+	case OTLP:
+		exportador, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(o.Endpoint))
+		if err != nil {
+			return nil, fmt.Errorf("exportador OTLP: %w", err)
+		}
+		return exportador, nil
+	case Archivo:
+		archivo, err := os.OpenFile(o.Archivo, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("archivo de trazas: %w", err)
+		}
+		exportador, err := stdouttrace.New(stdouttrace.WithWriter(archivo))
+		if err != nil {
+			archivo.Close()
+			return nil, fmt.Errorf("exportador a archivo: %w", err)
+		}
+		return exportadorArchivo{exportador, archivo}, nil
+	default:
+		return nil, fmt.Errorf("exportador de trazas %q desconocido (ninguno, otlp o archivo)", o.Exportador)
+	}
+}
+
+// exportadorArchivo cierra el archivo después de escribir los últimos spans.
+type exportadorArchivo struct {
+	*stdouttrace.Exporter
+	archivo *os.File
+}
+
+func (e exportadorArchivo) Shutdown(ctx context.Context) error {
+	return errors.Join(e.Exporter.Shutdown(ctx), e.archivo.Close())
+}